@@ -0,0 +1,261 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"blockchain-visualizer/miner"
+
+	"github.com/gorilla/mux"
+)
+
+// RecoveryPolicyRequest selects a deadlock recovery policy
+type RecoveryPolicyRequest struct {
+	Policy string `json:"policy"`
+}
+
+// RecoveryLogResponse is the current recovery policy and the audit log of recovery actions
+type RecoveryLogResponse struct {
+	Policy     miner.RecoveryPolicy `json:"policy"`
+	Recoveries []miner.Recovery     `json:"recoveries"`
+}
+
+// InjectRequest stages units of a resource held, waited for or released by
+// a process. Units defaults to 1.
+type InjectRequest struct {
+	Process  int `json:"process"`
+	Resource int `json:"resource"`
+	Units    int `json:"units"`
+}
+
+// maxInjectedUnits bounds the units staged by one request
+const maxInjectedUnits = 100
+
+// BankerDemoRequest sets how many processes the banker demo runs. It
+// defaults to defaultBankerDemoProcesses.
+type BankerDemoRequest struct {
+	Processes int `json:"processes"`
+}
+
+// BankerDemoResponse compares the banker demo run with avoidance and with detection only
+type BankerDemoResponse struct {
+	Avoidance miner.BankerDemoResult `json:"avoidance"`
+	Detection miner.BankerDemoResult `json:"detection"`
+}
+
+// Bounds on the processes in a banker demo
+const (
+	defaultBankerDemoProcesses = 5
+	maxBankerDemoProcesses     = 10
+)
+
+// Sources of the state the detection and graph endpoints report on
+const (
+	SourceLive     = "live"     // The detector tracking the server's own locks
+	SourceScenario = "scenario" // The detector holding the staged scenario
+)
+
+// SetupDeadlockRoutes configures the deadlock detection, graph, scenario,
+// banker and recovery endpoints. detector tracks the server's locks and
+// scenarios holds the scenarios staged by hand, so staging and resetting a
+// scenario never touches a real hold or wait.
+func SetupDeadlockRoutes(router *mux.Router, detector, scenarios *miner.DeadlockDetector) {
+	router.HandleFunc("/deadlocks", DeadlocksHandler(detector, scenarios)).Methods("GET")
+	router.HandleFunc("/deadlocks/graph", DeadlockGraphHandler(detector, scenarios)).Methods("GET")
+	router.HandleFunc("/deadlocks/scenario/resources", AddResourceHandler(scenarios)).Methods("POST")
+	router.HandleFunc("/deadlocks/scenario/allocations", InjectHandler(scenarios, scenarios.AddAllocation)).Methods("POST")
+	router.HandleFunc("/deadlocks/scenario/waits", InjectHandler(scenarios, scenarios.AddWaitFor)).Methods("POST")
+	router.HandleFunc("/deadlocks/scenario/releases", InjectHandler(scenarios, scenarios.Released)).Methods("POST")
+	router.HandleFunc("/deadlocks/scenario/reset", ResetDeadlocksHandler(scenarios)).Methods("POST")
+	router.HandleFunc("/deadlocks/banker/demo", BankerDemoHandler()).Methods("POST")
+	router.HandleFunc("/deadlocks/recoveries", RecoveryLogHandler(detector)).Methods("GET")
+	router.HandleFunc("/deadlocks/policy", SetRecoveryPolicyHandler(detector)).Methods("POST")
+}
+
+// detectorFor returns the detector selected by the request's ?source,
+// which is the live one unless it is scenario
+func detectorFor(r *http.Request, live, scenarios *miner.DeadlockDetector) (*miner.DeadlockDetector, error) {
+	switch source := r.URL.Query().Get("source"); source {
+	case "", SourceLive:
+		return live, nil
+	case SourceScenario:
+		return scenarios, nil
+	default:
+		return nil, fmt.Errorf("unknown source %q, want %s or %s", source, SourceLive, SourceScenario)
+	}
+}
+
+// DeadlocksHandler runs detection and returns the cycles, deadlocked sets
+// and the matrices behind them, without waiting for the periodic check.
+// ?source=scenario analyzes the staged scenario instead of the live locks.
+func DeadlocksHandler(live, scenarios *miner.DeadlockDetector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		detector, err := detectorFor(r, live, scenarios)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(detector.Analyze())
+	}
+}
+
+// DeadlockGraphHandler returns the resource-allocation and wait-for graphs
+// as JSON, or one of them as Graphviz DOT with ?format=dot. The DOT graph
+// is the resource-allocation graph unless ?graph=waitfor. The graphs are
+// of the live locks unless ?source=scenario.
+func DeadlockGraphHandler(live, scenarios *miner.DeadlockDetector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		detector, err := detectorFor(r, live, scenarios)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		graphs := detector.Graphs()
+
+		switch format := r.URL.Query().Get("format"); format {
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(graphs)
+		case "dot":
+			var dot string
+			switch graph := r.URL.Query().Get("graph"); graph {
+			case "", "allocation":
+				dot = graphs.ResourceAllocation.DOT("resource_allocation")
+			case "waitfor":
+				dot = graphs.WaitFor.DOT("wait_for")
+			default:
+				http.Error(w, fmt.Sprintf("unknown graph %q, want allocation or waitfor", graph), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text/vnd.graphviz")
+			w.Write([]byte(dot))
+		default:
+			http.Error(w, fmt.Sprintf("unknown format %q, want json or dot", format), http.StatusBadRequest)
+		}
+	}
+}
+
+// AddResourceHandler sets the instances and name of a resource in a scenario
+func AddResourceHandler(detector *miner.DeadlockDetector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var resource miner.Resource
+		if err := json.NewDecoder(r.Body).Decode(&resource); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if resource.ID <= 0 || resource.Instances <= 0 {
+			http.Error(w, "resource id and instances must be positive", http.StatusBadRequest)
+			return
+		}
+		detector.AddResource(resource)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(detector.Graphs())
+	}
+}
+
+// InjectHandler applies stage to each unit of an InjectRequest and returns
+// the resulting graphs of the scenario. It serves the allocation, wait and
+// release endpoints used to build classroom scenarios.
+func InjectHandler(detector *miner.DeadlockDetector, stage func(process, resource int)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request InjectRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Units == 0 {
+			request.Units = 1
+		}
+		if request.Process <= 0 || request.Resource <= 0 {
+			http.Error(w, "process and resource must be positive", http.StatusBadRequest)
+			return
+		}
+		if request.Units < 0 || request.Units > maxInjectedUnits {
+			http.Error(w, fmt.Sprintf("units must be between 1 and %d", maxInjectedUnits), http.StatusBadRequest)
+			return
+		}
+		for i := 0; i < request.Units; i++ {
+			stage(request.Process, request.Resource)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(detector.Graphs())
+	}
+}
+
+// ResetDeadlocksHandler clears every hold and wait of a staged scenario
+func ResetDeadlocksHandler(detector *miner.DeadlockDetector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		detector.Reset()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(detector.Graphs())
+	}
+}
+
+// BankerDemoHandler runs the banker demo workload with avoidance and then
+// with detection only, and returns both outcomes
+func BankerDemoHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// An empty body asks for the defaults
+		var request BankerDemoRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Processes == 0 {
+			request.Processes = defaultBankerDemoProcesses
+		}
+		if request.Processes < 2 || request.Processes > maxBankerDemoProcesses {
+			http.Error(w, fmt.Sprintf("processes must be between 2 and %d", maxBankerDemoProcesses), http.StatusBadRequest)
+			return
+		}
+
+		response := BankerDemoResponse{
+			Avoidance: miner.RunBankerDemo(request.Processes, true),
+			Detection: miner.RunBankerDemo(request.Processes, false),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// RecoveryLogHandler returns the recovery policy and every recovery action taken, oldest first
+func RecoveryLogHandler(detector *miner.DeadlockDetector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := RecoveryLogResponse{
+			Policy:     detector.RecoveryPolicy(),
+			Recoveries: detector.Recoveries(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// SetRecoveryPolicyHandler changes what the detector does about the deadlocks it finds
+func SetRecoveryPolicyHandler(detector *miner.DeadlockDetector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request RecoveryPolicyRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		policy, err := miner.ParseRecoveryPolicy(request.Policy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		detector.SetRecoveryPolicy(policy)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(RecoveryLogResponse{Policy: policy, Recoveries: detector.Recoveries()})
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"blockchain-visualizer/events"
+)
+
+// eventBufferSize is how many events a slow stream client may fall behind by before events are dropped
+const eventBufferSize = 256
+
+// keepAliveInterval is how often an idle stream sends a comment to keep proxies from closing it
+const keepAliveInterval = 15 * time.Second
+
+// EventStreamHandler streams bus events to the client as Server-Sent Events.
+// An optional types query parameter (comma separated) filters the stream.
+func EventStreamHandler(bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		filter := make(map[events.Type]bool)
+		if types := r.URL.Query().Get("types"); types != "" {
+			for _, t := range strings.Split(types, ",") {
+				filter[events.Type(strings.TrimSpace(t))] = true
+			}
+		}
+
+		stream, unsubscribe := bus.Subscribe(eventBufferSize)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case event, ok := <-stream:
+				if !ok {
+					return
+				}
+				if len(filter) > 0 && !filter[event.Type] {
+					continue
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+				flusher.Flush()
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
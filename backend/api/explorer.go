@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"blockchain-visualizer/blockchain"
+
+	"github.com/gorilla/mux"
+)
+
+// GetBlockHandler returns the main chain block at an index with its confirmation count
+func GetBlockHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		index, err := strconv.Atoi(mux.Vars(r)["index"])
+		if err != nil {
+			http.Error(w, "block index must be a number", http.StatusBadRequest)
+			return
+		}
+		info, ok := bc.GetBlockInfo(index)
+		if !ok {
+			http.Error(w, "Block not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+// GetBlockByHashHandler returns any known block by hash, including side branches
+func GetBlockByHashHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, ok := bc.GetBlockInfoByHash(mux.Vars(r)["hash"])
+		if !ok {
+			http.Error(w, "Block not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+// GetTransactionHandler returns a confirmed or pending transaction with its
+// confirming block and confirmation count
+func GetTransactionHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, ok := bc.GetTransaction(mux.Vars(r)["id"])
+		if !ok {
+			http.Error(w, "Transaction not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+// GetMempoolHandler lists the pending transactions, most profitable first
+func GetMempoolHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bc.MempoolInfo())
+	}
+}
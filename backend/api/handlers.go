@@ -2,17 +2,31 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"blockchain-visualizer/blockchain"
 	"blockchain-visualizer/miner"
+	"blockchain-visualizer/wallet"
+
+	"github.com/gorilla/mux"
 )
 
+// TransactionRequest either carries a client-signed transaction (timestamp,
+// publicKey and signature set) or names a sender whose wallet is held by the
+// server's keystore, in which case the server signs it if the request
+// carries the wallet token as "Authorization: Bearer <token>"
 type TransactionRequest struct {
 	Sender    string  `json:"sender"`
 	Recipient string  `json:"recipient"`
 	Amount    float64 `json:"amount"`
+	Fee       float64 `json:"fee,omitempty"`
+	Timestamp int64   `json:"timestamp,omitempty"`
+	PublicKey string  `json:"publicKey,omitempty"`
+	Signature string  `json:"signature,omitempty"`
 }
 
 type BlockResponse struct {
@@ -22,16 +36,59 @@ type BlockResponse struct {
 }
 
 type TransactionResponse struct {
-	Message string            `json:"message"`
-	Block   *blockchain.Block `json:"block"`
+	Message     string                  `json:"message"`
+	Block       *blockchain.Block       `json:"block"`
+	Transaction *blockchain.Transaction `json:"transaction,omitempty"`
+}
+
+type ProofResponse struct {
+	BlockIndex int                     `json:"blockIndex"`
+	BlockHash  string                  `json:"blockHash"`
+	Proof      *blockchain.MerkleProof `json:"proof"`
+	Verified   bool                    `json:"verified"`
+}
+
+type MerkleTreeResponse struct {
+	BlockIndex int        `json:"blockIndex"`
+	MerkleRoot string     `json:"merkleRoot"`
+	Levels     [][]string `json:"levels"`
+}
+
+type WalletResponse struct {
+	Address   string  `json:"address"`
+	PublicKey string  `json:"publicKey"`
+	Balance   float64 `json:"balance"`
+}
+
+type BalanceResponse struct {
+	Address   string  `json:"address"`
+	Balance   float64 `json:"balance"`
+	Spendable float64 `json:"spendable"`
 }
 
 type BlockchainResponse struct {
-	Chain  []*blockchain.Block `json:"chain"`
-	Length int                 `json:"length"`
+	Chain          []*blockchain.Block    `json:"chain"`
+	From           int                    `json:"from"`   // Index of the first block in Chain
+	Length         int                    `json:"length"` // Length of the whole main chain
+	NextDifficulty int                    `json:"nextDifficulty"`
+	Params         blockchain.ChainParams `json:"params"`
+}
+
+type MiningJobResponse struct {
+	Message string    `json:"message"`
+	Job     miner.Job `json:"job"`
 }
 
-func CreateTransactionHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+// maxMinersPerRequest caps the miners query parameter on /mine
+const maxMinersPerRequest = 64
+
+// defaultChainPage is how many blocks /chain returns without a limit
+const defaultChainPage = 100
+
+// maxChainPage caps the limit query parameter on /chain
+const maxChainPage = 1000
+
+func CreateTransactionHandler(bc *blockchain.Blockchain, ks *wallet.Keystore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req TransactionRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -39,12 +96,46 @@ func CreateTransactionHandler(bc *blockchain.Blockchain) http.HandlerFunc {
 			return
 		}
 
-		transaction := blockchain.NewTransaction(req.Sender, req.Recipient, req.Amount)
-		bc.AddTransaction(transaction) // Add to pending pool instead of creating a block
+		var transaction blockchain.Transaction
+		if req.Signature != "" {
+			// Rebuild the transaction exactly as the client signed it
+			transaction = blockchain.Transaction{
+				Sender:    req.Sender,
+				Recipient: req.Recipient,
+				Amount:    req.Amount,
+				Fee:       req.Fee,
+				Timestamp: req.Timestamp,
+				PublicKey: req.PublicKey,
+				Signature: req.Signature,
+			}
+			transaction.ID = transaction.CalculateHash()
+		} else if senderWallet, ok := ks.Get(req.Sender); ok {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if err := ks.Authorize(token); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			tx, err := senderWallet.NewTransaction(req.Recipient, req.Amount, req.Fee)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			transaction = tx
+		} else {
+			// Unsigned and not ours to sign, so AddTransaction will reject it
+			transaction = blockchain.NewTransactionWithFee(req.Sender, req.Recipient, req.Amount, req.Fee)
+		}
+
+		// Add to pending pool instead of creating a block
+		if err := bc.AddTransaction(transaction); err != nil {
+			http.Error(w, err.Error(), transactionErrorStatus(err))
+			return
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(TransactionResponse{
-			Message: "Transaction added to pending transactions",
+			Message:     "Transaction added to pending transactions",
+			Transaction: &transaction,
 		})
 	}
 }
@@ -53,7 +144,11 @@ func MineBlockHandler(bc *blockchain.Blockchain) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// In a real implementation, this would mine pending transactions
 		// For this example, we'll just create a new block with a dummy transaction
-		newBlock := bc.MinePendingTransactions("miner") // Mine all pending transactions
+		newBlock, err := bc.MinePendingTransactions("miner") // Mine all pending transactions
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(BlockResponse{
@@ -64,51 +159,275 @@ func MineBlockHandler(bc *blockchain.Blockchain) http.HandlerFunc {
 	}
 }
 
-func MineBlockHandlerWithConcurrency(bc *blockchain.Blockchain, numMiners int) http.HandlerFunc {
+// MineBlockHandlerWithConcurrency submits a mining job and answers right
+// away with its ID; GET /mine/jobs/{id} reports the outcome
+func MineBlockHandlerWithConcurrency(jobs *miner.Jobs, ks *wallet.Keystore, numMiners int) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("")
-		fmt.Println("Starting concurrent mining with spanning tree termination...")
+		rewardAddress, miners, status, err := miningRequest(r, ks, numMiners)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
 
-		// Get pending transactions
-		pendingTransactions := bc.GetPendingTransactions()
+		// Start concurrent mining with spanning tree termination detection.
+		// Only one job runs at a time, so a request made while one is
+		// running is answered with 409 Conflict.
+		job, err := jobs.Submit(rewardAddress, miners)
+		if err != nil {
+			http.Error(w, err.Error(), miningErrorStatus(err))
+			return
+		}
 
-		// Add reward transaction
-		rewardTx := blockchain.NewTransaction("system", "miner", 1.0)
-		allTransactions := append(pendingTransactions, rewardTx)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/mine/jobs/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(MiningJobResponse{
+			Message: "Mining job submitted",
+			Job:     job,
+		})
+	}
+}
 
-		// Start concurrent mining with spanning tree termination detection
-		newBlock := miner.StartMining(bc, allTransactions, 4, numMiners)
+// GetBlockchainHandler returns a page of the main chain: limit blocks
+// (defaultChainPage unless given, at most maxChainPage) starting at index
+// from, or the latest limit blocks without from, so explorers can page
+// through long chains
+func GetBlockchainHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		from, limit := -1, defaultChainPage
+		if value := query.Get("from"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				http.Error(w, "from must be a block index", http.StatusBadRequest)
+				return
+			}
+			from = n
+		}
+		if value := query.Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxChainPage {
+				http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxChainPage), http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
 
-		if newBlock == nil {
-			http.Error(w, "Mining timed out or failed", http.StatusInternalServerError)
-			return
+		page := bc.GetChainPage(from, limit)
+		response := BlockchainResponse{
+			Chain:          page.Blocks,
+			From:           page.From,
+			Length:         page.Length,
+			NextDifficulty: page.NextDifficulty,
+			Params:         bc.Params,
 		}
 
-		// Add the mined block to the blockchain
-		bc.AddMinedBlock(newBlock)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
 
-		// Clear the pending transactions
-		bc.ClearPendingTransactions()
+// SubmitBlockHandler accepts a block solved outside this node. It goes
+// through the same validation as locally mined blocks.
+func SubmitBlockHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var block blockchain.Block
+		if err := json.NewDecoder(r.Body).Decode(&block); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		fmt.Println("Mining complete. Block added to blockchain.")
+		if err := bc.AddMinedBlock(&block); err != nil {
+			http.Error(w, err.Error(), blockErrorStatus(err))
+			return
+		}
+
+		message := "Block accepted onto the main chain"
+		if !bc.IsOnMainChain(block.Hash) {
+			message = "Block accepted onto a side branch"
+		}
 
 		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(BlockResponse{
-			Message:    "New block mined with spanning tree termination",
-			BlockIndex: newBlock.Index,
-			Block:      newBlock,
+			Message:    message,
+			BlockIndex: block.Index,
+			Block:      &block,
 		})
 	}
 }
 
-func GetBlockchainHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+func GetChainTipsHandler(bc *blockchain.Blockchain) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		response := BlockchainResponse{
-			Chain:  bc.Blocks,
-			Length: len(bc.Blocks),
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bc.Tips())
+	}
+}
+
+func GetBalanceHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		address := mux.Vars(r)["address"]
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(BalanceResponse{
+			Address:   address,
+			Balance:   bc.Balance(address),
+			Spendable: bc.SpendableBalance(address),
+		})
+	}
+}
+
+func GetMerkleProofHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		block, ok := blockFromPath(w, r, bc)
+		if !ok {
+			return
+		}
+
+		proof, err := block.InclusionProof(mux.Vars(r)["txid"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ProofResponse{
+			BlockIndex: block.Index,
+			BlockHash:  block.Hash,
+			Proof:      proof,
+			Verified:   proof.Verify() && proof.Root == block.MerkleRoot,
+		})
+	}
+}
+
+func GetMerkleTreeHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		block, ok := blockFromPath(w, r, bc)
+		if !ok {
+			return
+		}
+
+		levels, err := blockchain.MerkleTree(block.Transactions)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MerkleTreeResponse{
+			BlockIndex: block.Index,
+			MerkleRoot: block.MerkleRoot,
+			Levels:     levels,
+		})
+	}
+}
+
+// blockFromPath looks up the block named by the index path variable,
+// writing an error response if there is none
+func blockFromPath(w http.ResponseWriter, r *http.Request, bc *blockchain.Blockchain) (*blockchain.Block, bool) {
+	index, err := strconv.Atoi(mux.Vars(r)["index"])
+	if err != nil {
+		http.Error(w, "block index must be a number", http.StatusBadRequest)
+		return nil, false
+	}
+	block, ok := bc.GetBlock(index)
+	if !ok {
+		http.Error(w, fmt.Sprintf("block %d not found", index), http.StatusNotFound)
+		return nil, false
+	}
+	return block, true
+}
+
+func CreateWalletHandler(bc *blockchain.Blockchain, ks *wallet.Keystore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		newWallet, err := ks.Create()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response, err := walletResponse(bc, newWallet)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(response)
 	}
 }
+
+func ListWalletsHandler(bc *blockchain.Blockchain, ks *wallet.Keystore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		wallets := []WalletResponse{}
+		for _, address := range ks.Addresses() {
+			stored, _ := ks.Get(address)
+			response, err := walletResponse(bc, stored)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			wallets = append(wallets, response)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(wallets)
+	}
+}
+
+// walletResponse describes a wallet without exposing its private key
+func walletResponse(bc *blockchain.Blockchain, stored wallet.Wallet) (WalletResponse, error) {
+	publicKey, err := blockchain.EncodePublicKey(stored.PublicKey)
+	if err != nil {
+		return WalletResponse{}, err
+	}
+	return WalletResponse{
+		Address:   stored.Address(),
+		PublicKey: publicKey,
+		Balance:   bc.Balance(stored.Address()),
+	}, nil
+}
+
+// blockErrorStatus maps a rejected block to an HTTP status code
+func blockErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, blockchain.ErrKnownBlock):
+		return http.StatusConflict
+	case errors.Is(err, blockchain.ErrBadPrevHash),
+		errors.Is(err, blockchain.ErrBadIndex),
+		errors.Is(err, blockchain.ErrBadHash),
+		errors.Is(err, blockchain.ErrBadHeader),
+		errors.Is(err, blockchain.ErrBadDifficulty),
+		errors.Is(err, blockchain.ErrInsufficientWork),
+		errors.Is(err, blockchain.ErrBadSlot),
+		errors.Is(err, blockchain.ErrNotSlotLeader),
+		errors.Is(err, blockchain.ErrBadSeal),
+		errors.Is(err, blockchain.ErrBadTimestamp),
+		errors.Is(err, blockchain.ErrBadMerkleRoot),
+		errors.Is(err, blockchain.ErrBadCoinbase),
+		errors.Is(err, blockchain.ErrBlockTooLarge),
+		errors.Is(err, blockchain.ErrInvalidTx):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// transactionErrorStatus maps a rejected transaction to an HTTP status code
+func transactionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, blockchain.ErrInsufficientFunds),
+		errors.Is(err, blockchain.ErrDuplicateTransaction):
+		return http.StatusConflict
+	case errors.Is(err, blockchain.ErrInvalidAmount),
+		errors.Is(err, blockchain.ErrInvalidFee),
+		errors.Is(err, blockchain.ErrInvalidAddress),
+		errors.Is(err, blockchain.ErrCoinbaseNotAllowed),
+		errors.Is(err, blockchain.ErrMissingSignature),
+		errors.Is(err, blockchain.ErrInvalidSignature):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"blockchain-visualizer/blockchain"
+	"blockchain-visualizer/miner"
+	"blockchain-visualizer/wallet"
+
+	"github.com/gorilla/mux"
+)
+
+// miningRequest reads the miners and miner query parameters shared by /mine
+// and /miner/start. The miner count can be overridden per request to show how
+// difficulty reacts, and the reward and fees go to the miner parameter if
+// given and to the server's primary wallet otherwise. On failure it also
+// returns the HTTP status to answer with.
+func miningRequest(r *http.Request, ks *wallet.Keystore, numMiners int) (string, int, int, error) {
+	miners := numMiners
+	if value := r.URL.Query().Get("miners"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxMinersPerRequest {
+			return "", 0, http.StatusBadRequest, fmt.Errorf("miners must be between 1 and %d", maxMinersPerRequest)
+		}
+		miners = n
+	}
+
+	rewardAddress := r.URL.Query().Get("miner")
+	if rewardAddress == "" {
+		primary, err := ks.Primary()
+		if err != nil {
+			return "", 0, http.StatusInternalServerError, err
+		}
+		rewardAddress = primary
+	}
+	return rewardAddress, miners, http.StatusOK, nil
+}
+
+// GetMiningJobHandler reports the state of a job submitted through /mine
+func GetMiningJobHandler(jobs *miner.Jobs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := jobs.Get(mux.Vars(r)["id"])
+		if !ok {
+			http.Error(w, "Mining job not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+// miningErrorStatus maps an error starting or resuming mining to an HTTP
+// status code
+func miningErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, blockchain.ErrWrongConsensus),
+		errors.Is(err, miner.ErrServiceStopped),
+		errors.Is(err, miner.ErrJobRunning):
+		return http.StatusConflict
+	case errors.Is(err, miner.ErrNoMiners):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// StartMinerHandler starts the background miner, or resumes it if paused
+func StartMinerHandler(service *miner.Service, ks *wallet.Keystore, numMiners int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rewardAddress, miners, status, err := miningRequest(r, ks, numMiners)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		started, err := service.Start(rewardAddress, miners)
+		if err != nil {
+			http.Error(w, err.Error(), miningErrorStatus(err))
+			return
+		}
+		writeMinerStatus(w, started)
+	}
+}
+
+// PauseMinerHandler pauses the background miner
+func PauseMinerHandler(service *miner.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeMinerStatus(w, service.Pause())
+	}
+}
+
+// ResumeMinerHandler resumes a paused background miner
+func ResumeMinerHandler(service *miner.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := service.Resume()
+		if err != nil {
+			http.Error(w, err.Error(), miningErrorStatus(err))
+			return
+		}
+		writeMinerStatus(w, status)
+	}
+}
+
+// StopMinerHandler stops the background miner
+func StopMinerHandler(service *miner.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeMinerStatus(w, service.Stop())
+	}
+}
+
+// MinerStatusHandler reports what the background miner is doing
+func MinerStatusHandler(service *miner.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeMinerStatus(w, service.Status())
+	}
+}
+
+// writeMinerStatus encodes a miner service status as the response
+func writeMinerStatus(w http.ResponseWriter, status miner.ServiceStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// MinerStatsHandler reports per-worker and per-round hash-rate telemetry
+func MinerStatsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(miner.Stats())
+	}
+}
+
+// MetricsHandler serves the mining telemetry and chain gauges in the
+// Prometheus text exposition format
+func MetricsHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		miner.WriteMetrics(w)
+
+		fmt.Fprintf(w, "# HELP blockchain_height Index of the tip block.\n# TYPE blockchain_height gauge\n")
+		fmt.Fprintf(w, "blockchain_height %d\n", bc.Height())
+		fmt.Fprintf(w, "# HELP blockchain_difficulty Difficulty required of the next block.\n# TYPE blockchain_difficulty gauge\n")
+		fmt.Fprintf(w, "blockchain_difficulty %d\n", bc.NextDifficulty())
+		fmt.Fprintf(w, "# HELP blockchain_pending_transactions Transactions waiting in the mempool.\n# TYPE blockchain_pending_transactions gauge\n")
+		fmt.Fprintf(w, "blockchain_pending_transactions %d\n", len(bc.GetPendingTransactions()))
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"blockchain-visualizer/blockchain"
+	"blockchain-visualizer/network"
+
+	"github.com/gorilla/mux"
+)
+
+type ConnectPeerRequest struct {
+	URL string `json:"url"`
+}
+
+// SetupNetworkRoutes configures the peer-to-peer endpoints other nodes call
+func SetupNetworkRoutes(router *mux.Router, n *network.Network) {
+	router.HandleFunc("/p2p/handshake", HandshakeHandler(n)).Methods("POST")
+	router.HandleFunc("/p2p/blocks", ReceiveBlockHandler(n)).Methods("POST")
+	router.HandleFunc("/p2p/transactions", ReceiveTransactionHandler(n)).Methods("POST")
+	router.HandleFunc("/p2p/peers", ListPeersHandler(n)).Methods("GET")
+	router.HandleFunc("/p2p/peers", ConnectPeerHandler(n)).Methods("POST")
+}
+
+func HandshakeHandler(n *network.Network) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var remote network.Handshake
+		if err := json.NewDecoder(r.Body).Decode(&remote); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		local, err := n.HandleHandshake(remote)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(local)
+	}
+}
+
+func ReceiveBlockHandler(n *network.Network) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var block blockchain.Block
+		if err := json.NewDecoder(r.Body).Decode(&block); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := n.ReceiveBlock(&block)
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusOK)
+		case errors.Is(err, blockchain.ErrBadPrevHash):
+			// We are missing blocks, so catch up from the node that sent this one
+			if sender := r.Header.Get(network.NodeHeader); sender != "" {
+				go n.Sync(sender)
+			}
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, err.Error(), blockErrorStatus(err))
+		}
+	}
+}
+
+func ReceiveTransactionHandler(n *network.Network) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var tx blockchain.Transaction
+		if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := n.ReceiveTransaction(tx)
+		if err != nil && !errors.Is(err, blockchain.ErrDuplicateTransaction) {
+			http.Error(w, err.Error(), transactionErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func ListPeersHandler(n *network.Network) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(n.Peers())
+	}
+}
+
+func ConnectPeerHandler(n *network.Network) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ConnectPeerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+			http.Error(w, "url is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := n.Connect(req.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(n.Peers())
+	}
+}
@@ -2,18 +2,52 @@ package api
 
 import (
 	"blockchain-visualizer/blockchain"
+	"blockchain-visualizer/events"
+	"blockchain-visualizer/miner"
+	"blockchain-visualizer/validator"
+	"blockchain-visualizer/wallet"
 
 	"github.com/gorilla/mux"
 )
 
 // SetupRoutesWithMining configures all the routes for our blockchain API
-func SetupRoutesWithMining(router *mux.Router, bc *blockchain.Blockchain, numMiners int) {
-	router.HandleFunc("/transactions/new", CreateTransactionHandler(bc)).Methods("POST")
-	router.HandleFunc("/mine", MineBlockHandlerWithConcurrency(bc, numMiners)).Methods("GET")
+func SetupRoutesWithMining(router *mux.Router, bc *blockchain.Blockchain, ks *wallet.Keystore, numMiners int) {
+	jobs := miner.NewJobs(bc)
+	service := miner.NewService(bc, numMiners)
+	validators := validator.NewService(bc, ks)
+
+	router.HandleFunc("/transactions/new", CreateTransactionHandler(bc, ks)).Methods("POST")
+	router.HandleFunc("/mine", MineBlockHandlerWithConcurrency(jobs, ks, numMiners)).Methods("GET")
+	router.HandleFunc("/mine/jobs/{id}", GetMiningJobHandler(jobs)).Methods("GET")
+	router.HandleFunc("/miner/start", StartMinerHandler(service, ks, numMiners)).Methods("POST")
+	router.HandleFunc("/miner/pause", PauseMinerHandler(service)).Methods("POST")
+	router.HandleFunc("/miner/resume", ResumeMinerHandler(service)).Methods("POST")
+	router.HandleFunc("/miner/stop", StopMinerHandler(service)).Methods("POST")
+	router.HandleFunc("/miner/status", MinerStatusHandler(service)).Methods("GET")
+	router.HandleFunc("/miner/stats", MinerStatsHandler()).Methods("GET")
+	router.HandleFunc("/metrics", MetricsHandler(bc)).Methods("GET")
+	router.HandleFunc("/validator/start", StartValidatorHandler(validators)).Methods("POST")
+	router.HandleFunc("/validator/stop", StopValidatorHandler(validators)).Methods("POST")
+	router.HandleFunc("/validator/status", ValidatorStatusHandler(validators)).Methods("GET")
+	router.HandleFunc("/stakes", GetStakesHandler(bc)).Methods("GET")
 	router.HandleFunc("/chain", GetBlockchainHandler(bc)).Methods("GET")
+	router.HandleFunc("/chain/tips", GetChainTipsHandler(bc)).Methods("GET")
+	router.HandleFunc("/chain/validate", ValidateChainHandler(bc)).Methods("GET")
+	router.HandleFunc("/chain/tamper", TamperChainHandler(bc)).Methods("POST")
+	router.HandleFunc("/blocks", SubmitBlockHandler(bc)).Methods("POST")
+	router.HandleFunc("/blocks/{index:[0-9]+}", GetBlockHandler(bc)).Methods("GET")
+	router.HandleFunc("/blocks/hash/{hash}", GetBlockByHashHandler(bc)).Methods("GET")
+	router.HandleFunc("/transactions/{id}", GetTransactionHandler(bc)).Methods("GET")
+	router.HandleFunc("/mempool", GetMempoolHandler(bc)).Methods("GET")
+	router.HandleFunc("/balance/{address}", GetBalanceHandler(bc)).Methods("GET")
+	router.HandleFunc("/blocks/{index}/merkle", GetMerkleTreeHandler(bc)).Methods("GET")
+	router.HandleFunc("/blocks/{index}/proof/{txid}", GetMerkleProofHandler(bc)).Methods("GET")
+	router.HandleFunc("/wallets", ListWalletsHandler(bc, ks)).Methods("GET")
+	router.HandleFunc("/wallets/new", CreateWalletHandler(bc, ks)).Methods("POST")
+	router.HandleFunc("/events", EventStreamHandler(events.Default)).Methods("GET")
 }
 
 // Keep the original SetupRoutes for backward compatibility
 func SetupRoutes(router *mux.Router, bc *blockchain.Blockchain) {
-	SetupRoutesWithMining(router, bc, 1) // Default to 1 miner if not specified
+	SetupRoutesWithMining(router, bc, wallet.NewKeystore(), 1) // Default to 1 miner if not specified
 }
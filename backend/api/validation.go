@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"blockchain-visualizer/blockchain"
+)
+
+// ValidateChainHandler validates the main chain and lists every block that
+// fails, with the rule it breaks and where the chain first goes wrong
+func ValidateChainHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bc.ValidationReport())
+	}
+}
+
+// TamperChainHandler edits one block in a copy of the main chain and returns
+// the validation report for the copy. The real chain is not changed.
+func TamperChainHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var tamper blockchain.Tamper
+		if err := json.NewDecoder(r.Body).Decode(&tamper); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := bc.SimulateTamper(tamper)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
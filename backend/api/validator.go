@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"blockchain-visualizer/blockchain"
+	"blockchain-visualizer/validator"
+)
+
+// StakesResponse describes the proof-of-stake validator set on the main chain
+type StakesResponse struct {
+	Consensus  string             `json:"consensus"`
+	Slot       int                `json:"slot"`
+	Leader     string             `json:"leader,omitempty"` // Empty while nobody has staked
+	TotalStake float64            `json:"totalStake"`
+	Stakes     map[string]float64 `json:"stakes"`
+}
+
+// StartValidatorHandler starts proposing proof-of-stake blocks
+func StartValidatorHandler(service *validator.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := service.Start()
+		if errors.Is(err, blockchain.ErrWrongConsensus) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeValidatorStatus(w, status)
+	}
+}
+
+// StopValidatorHandler stops proposing blocks
+func StopValidatorHandler(service *validator.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeValidatorStatus(w, service.Stop())
+	}
+}
+
+// ValidatorStatusHandler reports what the validator service is doing
+func ValidatorStatusHandler(service *validator.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeValidatorStatus(w, service.Status())
+	}
+}
+
+// writeValidatorStatus encodes a validator service status as the response
+func writeValidatorStatus(w http.ResponseWriter, status validator.Status) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// GetStakesHandler lists every validator's stake and the leader of the current slot
+func GetStakesHandler(bc *blockchain.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slot := bc.CurrentSlot()
+		leader, _ := bc.SlotLeader(slot)
+		stakes := bc.Stakes()
+		total := 0.0
+		for _, stake := range stakes {
+			total += stake
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(StakesResponse{
+			Consensus:  bc.Params.Engine().Name(),
+			Slot:       slot,
+			Leader:     leader,
+			TotalStake: total,
+			Stakes:     stakes,
+		})
+	}
+}
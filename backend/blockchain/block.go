@@ -1,54 +1,76 @@
 package blockchain
 
 import (
-	"crypto/sha256"
 	"encoding/hex"
-	"strconv"
-	"strings"
 	"time"
 )
 
 type Block struct {
+	Version      uint32        `json:"Version,omitempty"` // Header encoding, see HeaderVersionBinary
 	Index        int           `json:"Index"`
 	Timestamp    int64         `json:"Timestamp"`
 	Transactions []Transaction `json:"Transactions"`
 	PreviousHash string        `json:"PreviousHash"`
+	MerkleRoot   string        `json:"MerkleRoot"`
 	Hash         string        `json:"Hash"`
 	Nonce        int           `json:"Nonce"`
+	ExtraNonce   int           `json:"ExtraNonce"`
 	Difficulty   int           `json:"Difficulty"`
+	Bits         uint32        `json:"Bits,omitempty"`      // Compact target, for proofs of work that carry one
+	Slot         int           `json:"Slot,omitempty"`      // Proof-of-stake slot the block was proposed in
+	Validator    string        `json:"Validator,omitempty"` // Address of the proof-of-stake proposer
+	PublicKey    string        `json:"PublicKey,omitempty"` // Hex encoded PKIX public key of the validator
+	Signature    string        `json:"Signature,omitempty"` // Hex encoded PKCS#1 v1.5 signature over the hash
 }
 
-func NewBlock(index int, previousHash string, transactions []Transaction) *Block {
-	block := &Block{
+// NewBlock builds an unmined block template. Callers are responsible for
+// solving the proof of work, either with MineBlock or with concurrent miners.
+// The transactions must have well-formed IDs, as every transaction accepted
+// into the mempool and every coinbase has; NewBlock panics otherwise.
+func NewBlock(index int, previousHash string, transactions []Transaction, difficulty int) *Block {
+	merkleRoot, err := ComputeMerkleRoot(transactions)
+	if err != nil {
+		panic(err)
+	}
+	return &Block{
+		Version:      CurrentHeaderVersion,
 		Index:        index,
 		Timestamp:    time.Now().Unix(),
 		Transactions: transactions,
 		PreviousHash: previousHash,
+		MerkleRoot:   merkleRoot,
 		Nonce:        0,
-		Difficulty:   4, // Difficulty can be adjusted
+		Difficulty:   difficulty,
 	}
-	block.MineBlock()
-	return block
 }
 
-func (b *Block) CalculateHash() string {
-	data := strconv.Itoa(b.Index) + strconv.FormatInt(b.Timestamp, 10) + b.PreviousHash + strconv.Itoa(b.Nonce)
-	for _, tx := range b.Transactions {
-		data += tx.ID
-	}
-	hash := sha256.Sum256([]byte(data))
-	return hex.EncodeToString(hash[:])
+// CalculateHash hashes the block header with pow
+func (b *Block) CalculateHash(pow ProofOfWork) string {
+	return hex.EncodeToString(pow.Sum(b.Header()))
 }
 
-func (b *Block) IsValidHash() bool {
-	return strings.HasPrefix(b.Hash, strings.Repeat("0", b.Difficulty))
+// InclusionProof returns the Merkle proof that txID is one of the block's transactions
+func (b *Block) InclusionProof(txID string) (*MerkleProof, error) {
+	return BuildMerkleProof(b.Transactions, txID)
+}
+
+// IsValidHash reports whether the block's hash meets the target pow sets for its difficulty
+func (b *Block) IsValidHash(pow ProofOfWork) bool {
+	sum, err := hex.DecodeString(b.Hash)
+	if err != nil || len(sum) != 32 {
+		return false
+	}
+	return MeetsTarget(sum, TargetBytes(pow.Target(b.Difficulty)))
 }
 
-func (b *Block) MineBlock() {
-	target := strings.Repeat("0", b.Difficulty)
+// MineBlock searches nonces until the block's hash meets the target pow sets for its difficulty
+func (b *Block) MineBlock(pow ProofOfWork) {
+	target := TargetBytes(pow.Target(b.Difficulty))
+	hasher := NewNonceHasher(pow, b)
 	for {
-		b.Hash = b.CalculateHash()
-		if strings.HasPrefix(b.Hash, target) {
+		sum := hasher.Sum(b.Nonce)
+		if MeetsTarget(sum, target) {
+			b.Hash = hex.EncodeToString(sum)
 			break
 		}
 		b.Nonce++
@@ -1,64 +1,175 @@
 package blockchain
 
 import (
-	"sync"
+	"context"
+	"fmt"
+	"math/big"
+
+	"blockchain-visualizer/events"
+	"blockchain-visualizer/locks"
 )
 
 type Blockchain struct {
-	Blocks              []*Block
-	PendingTransactions []Transaction
-	mutex               sync.RWMutex // Add mutex for thread safety
+	Blocks     []*Block
+	mempool    *Mempool
+	Params     ChainParams
+	store      Store
+	balances   map[string]float64 // Confirmed balance per address
+	txIndex    map[string]int     // Confirmed transaction ID to block index
+	hashIndex  map[string]int     // Main chain block hash to block index
+	tree       map[string]*Block  // Every known block, including side branches
+	work       map[string]*big.Int
+	replaying  bool           // Set while loading stored blocks, which are not persisted or announced again
+	tipChanged chan struct{}  // Closed and replaced whenever the tip moves
+	mutex      *locks.RWMutex // Guards the chain, its state and the mempool
 }
 
 func NewBlockchain() *Blockchain {
-	genesisBlock := NewBlock(0, "", []Transaction{})
-	bc := &Blockchain{
-		Blocks:              []*Block{genesisBlock},
-		PendingTransactions: []Transaction{},
+	return NewBlockchainWithParams(DefaultChainParams())
+}
+
+// NewBlockchainWithParams creates an in-memory chain whose difficulty follows the given parameters
+func NewBlockchainWithParams(params ChainParams) *Blockchain {
+	bc, err := OpenBlockchain(NewMemoryStore(), params)
+	if err != nil {
+		// An empty memory store cannot fail to load, so only bad parameters get here
+		panic(err)
 	}
 	return bc
 }
 
-func (bc *Blockchain) AddTransaction(tx Transaction) {
+// OpenBlockchain loads the block tree and mempool from store, replaying every
+// stored block to rebuild the branches and pick the main chain, then
+// revalidates it. An empty store is initialized with a fresh genesis block.
+func OpenBlockchain(store Store, params ChainParams) (*Blockchain, error) {
+	if err := params.checkConsensus(); err != nil {
+		return nil, err
+	}
+
+	bc := &Blockchain{
+		mempool:    NewMempool(),
+		Params:     params,
+		store:      store,
+		tree:       make(map[string]*Block),
+		work:       make(map[string]*big.Int),
+		tipChanged: make(chan struct{}),
+		mutex:      locks.NewRWMutex("blockchain"),
+	}
+
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("load blocks: %w", err)
+	}
+
+	if len(blocks) == 0 {
+		genesisBlock := NewGenesisBlock(params)
+		if err := store.AppendBlock(genesisBlock); err != nil {
+			return nil, fmt.Errorf("store genesis block: %w", err)
+		}
+		blocks = []*Block{genesisBlock}
+	}
+
+	genesis := blocks[0]
+	if err := validateGenesis(genesis, params); err != nil {
+		return nil, fmt.Errorf("stored genesis block: %w", err)
+	}
+	bc.Blocks = []*Block{genesis}
+	bc.tree[genesis.Hash] = genesis
+	bc.work[genesis.Hash] = params.Engine().Weight(genesis)
+	bc.rebuildState()
+
+	bc.replaying = true
+	for _, block := range blocks[1:] {
+		if err := bc.acceptBlock(block); err != nil {
+			return nil, fmt.Errorf("replay block %d (%s): %w", block.Index, block.Hash, err)
+		}
+	}
+	bc.replaying = false
+
+	if err := bc.Validate(); err != nil {
+		return nil, fmt.Errorf("stored chain failed validation: %w", err)
+	}
+
+	pending, err := store.LoadMempool()
+	if err != nil {
+		return nil, fmt.Errorf("load mempool: %w", err)
+	}
+
+	// A crash between appending a block and saving the mempool can leave
+	// already mined transactions behind, so drop those
+	for _, tx := range pending {
+		if _, confirmed := bc.txIndex[tx.ID]; !confirmed {
+			bc.mempool.Add(tx)
+		}
+	}
+
+	return bc, nil
+}
+
+// NewGenesisBlock mines the genesis block with the chain's proof of work. Its
+// timestamp is fixed so every node started with the same parameters agrees on it.
+func NewGenesisBlock(params ChainParams) *Block {
+	genesisBlock := NewBlock(0, "", []Transaction{}, params.InitialDifficulty)
+	genesisBlock.Bits = params.PoW().Bits(params.InitialDifficulty)
+	genesisBlock.Timestamp = GenesisTimestamp
+	genesisBlock.MineBlock(params.PoW())
+	return genesisBlock
+}
+
+// Close releases the underlying store
+func (bc *Blockchain) Close() error {
+	return bc.store.Close()
+}
+
+// AddTransaction validates tx and adds it to the pending pool
+func (bc *Blockchain) AddTransaction(tx Transaction) error {
 	bc.mutex.Lock()         // Lock before modifying transactions
 	defer bc.mutex.Unlock() // Ensure unlock happens
 
-	bc.PendingTransactions = append(bc.PendingTransactions, tx)
+	if err := bc.validateTransaction(tx); err != nil {
+		return err
+	}
+
+	if err := bc.store.SaveMempool(append(bc.mempool.All(), tx)); err != nil {
+		return fmt.Errorf("persist mempool: %w", err)
+	}
+	bc.mempool.Add(tx)
+	events.Publish(events.TransactionAdded, fmt.Sprintf("■ Transaction %s added to the mempool", tx.ID), tx)
+	return nil
 }
 
-func (bc *Blockchain) AddBlock(transactions []Transaction) *Block {
+func (bc *Blockchain) AddBlock(transactions []Transaction) (*Block, error) {
 	bc.mutex.Lock()
 	defer bc.mutex.Unlock()
 
+	if bc.Params.Engine().Name() != ConsensusPoW {
+		return nil, ErrWrongConsensus
+	}
 	prevBlock := bc.Blocks[len(bc.Blocks)-1]
-	newBlock := NewBlock(prevBlock.Index+1, prevBlock.Hash, transactions)
-	bc.Blocks = append(bc.Blocks, newBlock)
-	return newBlock
+	newBlock := NewBlock(prevBlock.Index+1, prevBlock.Hash, transactions, 0)
+	bc.Params.Engine().Prepare(newBlock, bc.Blocks, "")
+	newBlock.MineBlock(bc.Params.PoW())
+	if err := bc.acceptBlock(newBlock); err != nil {
+		return nil, err
+	}
+	return newBlock, nil
 }
 
-func (bc *Blockchain) MinePendingTransactions(minerReward string) *Block {
-	bc.mutex.Lock()
-
-	// If we don't have any pending transactions, add just the reward transaction
-	var pendingTransactionsCopy []Transaction
-	if len(bc.PendingTransactions) == 0 {
-		// Create empty slice but don't clear anything since there's nothing to clear
-		pendingTransactionsCopy = []Transaction{}
-	} else {
-		// Copy pending transactions
-		pendingTransactionsCopy = make([]Transaction, len(bc.PendingTransactions))
-		copy(pendingTransactionsCopy, bc.PendingTransactions)
-		// Clear pending transactions only after copying them
-		bc.PendingTransactions = []Transaction{}
+// MinePendingTransactions mines a block template on the tip, paying the
+// reward and fees to minerReward
+func (bc *Blockchain) MinePendingTransactions(minerReward string) (*Block, error) {
+	if bc.Params.Engine().Name() != ConsensusPoW {
+		return nil, ErrWrongConsensus
 	}
-	bc.mutex.Unlock()
-
-	// Create the reward transaction
-	rewardTx := NewTransaction("system", minerReward, 1.0)
-	allTransactions := append(pendingTransactionsCopy, rewardTx)
+	template := bc.NewBlockTemplate(minerReward)
+	defer bc.ReleaseTemplate(template)
 
-	// Add the new block with all transactions
-	return bc.AddBlock(allTransactions)
+	// Accepting the block removes its transactions from the mempool
+	template.Block.MineBlock(bc.Params.PoW())
+	if err := bc.AddMinedBlock(template.Block); err != nil {
+		return nil, err
+	}
+	return template.Block, nil
 }
 
 func (bc *Blockchain) GetLatestBlock() *Block {
@@ -68,27 +179,96 @@ func (bc *Blockchain) GetLatestBlock() *Block {
 	return bc.Blocks[len(bc.Blocks)-1]
 }
 
-func (bc *Blockchain) IsValid() bool {
+// WatchTip returns the tip and a channel that is closed as soon as another
+// block replaces it, either by extending the chain or through a reorg
+func (bc *Blockchain) WatchTip() (*Block, <-chan struct{}) {
 	bc.mutex.RLock()
 	defer bc.mutex.RUnlock()
 
-	for i := 1; i < len(bc.Blocks); i++ {
-		currentBlock := bc.Blocks[i]
-		previousBlock := bc.Blocks[i-1]
+	return bc.Blocks[len(bc.Blocks)-1], bc.tipChanged
+}
 
-		if currentBlock.Hash != currentBlock.CalculateHash() {
-			return false
-		}
+// HasBlock reports whether a block with the given hash is known, on any branch
+func (bc *Blockchain) HasBlock(hash string) bool {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
 
-		if currentBlock.PreviousHash != previousBlock.Hash {
-			return false
-		}
+	_, ok := bc.tree[hash]
+	return ok
+}
+
+// IsOnMainChain reports whether the block with the given hash is on the main chain
+func (bc *Blockchain) IsOnMainChain(hash string) bool {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
 
-		if !currentBlock.IsValidHash() {
-			return false
+	_, ok := bc.hashIndex[hash]
+	return ok
+}
+
+// GetBlocks returns a copy of the main chain
+func (bc *Blockchain) GetBlocks() []*Block {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return append([]*Block{}, bc.Blocks...)
+}
+
+// Height returns the index of the tip block
+func (bc *Blockchain) Height() int {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return len(bc.Blocks) - 1
+}
+
+// GetBlock returns the block at index on the main chain
+func (bc *Blockchain) GetBlock(index int) (*Block, bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if index < 0 || index >= len(bc.Blocks) {
+		return nil, false
+	}
+	return bc.Blocks[index], true
+}
+
+// NextDifficulty returns the difficulty the next block on the tip must meet
+func (bc *Blockchain) NextDifficulty() int {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return bc.Params.difficultyAt(bc.Blocks, len(bc.Blocks))
+}
+
+// IsValid reports whether the main chain passes validation
+func (bc *Blockchain) IsValid() bool {
+	return bc.Validate() == nil
+}
+
+// Validate re-runs the block validation pipeline over the whole main chain
+// and returns the first failure
+func (bc *Blockchain) Validate() error {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if len(bc.Blocks) == 0 {
+		return fmt.Errorf("%w: chain has no genesis block", ErrBadIndex)
+	}
+	if err := validateGenesis(bc.Blocks[0], bc.Params); err != nil {
+		return fmt.Errorf("block 0: %w", err)
+	}
+
+	balances := make(map[string]float64)
+	txIndex := make(map[string]int)
+	applyBlockTo(balances, txIndex, bc.Blocks[0])
+	for i := 1; i < len(bc.Blocks); i++ {
+		if err := validateBlock(bc.Blocks[i], bc.Params, bc.Blocks[:i], balances, txIndex); err != nil {
+			return fmt.Errorf("block %d: %w", i, err)
 		}
+		applyBlockTo(balances, txIndex, bc.Blocks[i])
 	}
-	return true
+	return nil
 }
 
 // GetPendingTransactions returns a copy of the pending transactions
@@ -97,24 +277,41 @@ func (bc *Blockchain) GetPendingTransactions() []Transaction {
 	defer bc.mutex.RUnlock()
 
 	// Create a copy to avoid race conditions
-	transactions := make([]Transaction, len(bc.PendingTransactions))
-	copy(transactions, bc.PendingTransactions)
+	return bc.mempool.All()
+}
 
-	return transactions
+// AddMinedBlock validates a pre-mined block and adds it to the block tree. It
+// joins the main chain if it extends the tip or completes a branch with more
+// work, which triggers a reorg; otherwise it is kept on a side branch.
+// Rejections wrap the sentinel errors declared in errors.go.
+func (bc *Blockchain) AddMinedBlock(block *Block) error {
+	return bc.AddMinedBlockContext(context.Background(), block)
 }
 
-// AddMinedBlock adds a pre-mined block to the blockchain
-func (bc *Blockchain) AddMinedBlock(block *Block) {
-	bc.mutex.Lock()
+// AddMinedBlockContext adds a block like AddMinedBlock, but gives up and
+// returns ctx's error if ctx is done while it waits for the chain
+func (bc *Blockchain) AddMinedBlockContext(ctx context.Context, block *Block) error {
+	if err := bc.mutex.LockContext(ctx); err != nil {
+		return err
+	}
 	defer bc.mutex.Unlock()
 
-	bc.Blocks = append(bc.Blocks, block)
+	return bc.acceptBlock(block)
+}
+
+// publishBlockAppended announces a new block on the event bus
+func publishBlockAppended(block *Block) {
+	events.Publish(events.BlockAppended, fmt.Sprintf("■ Block %d appended to the chain (%s)", block.Index, block.Hash), block)
 }
 
 // ClearPendingTransactions clears all pending transactions
-func (bc *Blockchain) ClearPendingTransactions() {
+func (bc *Blockchain) ClearPendingTransactions() error {
 	bc.mutex.Lock()
 	defer bc.mutex.Unlock()
 
-	bc.PendingTransactions = []Transaction{}
+	if err := bc.store.SaveMempool([]Transaction{}); err != nil {
+		return fmt.Errorf("persist mempool: %w", err)
+	}
+	bc.mempool.Clear()
+	return nil
 }
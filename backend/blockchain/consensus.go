@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Names of the consensus engines, as used in ChainParams
+const (
+	// ConsensusPoW extends the chain with blocks whose hash meets a target
+	ConsensusPoW = "pow"
+	// ConsensusPoS extends the chain with blocks signed by a stake-weighted slot leader
+	ConsensusPoS = "pos"
+)
+
+var (
+	// ErrUnknownConsensus is returned for a consensus name that is not supported
+	ErrUnknownConsensus = errors.New("unknown consensus engine")
+	// ErrWrongConsensus is returned when an operation needs the other consensus engine
+	ErrWrongConsensus = errors.New("operation is not supported by the chain's consensus engine")
+)
+
+// Consensus decides who may extend the chain and how a block proves it. The
+// block tree, fork choice and transaction rules are the same for every
+// engine; only sealing and chain weight differ.
+type Consensus interface {
+	// Name identifies the engine in ChainParams
+	Name() string
+	// Prepare sets the consensus fields of an unsealed block that will extend
+	// branch on behalf of producer, who is paid its coinbase
+	Prepare(block *Block, branch []*Block, producer string)
+	// VerifySeal checks that block proves its right to extend branch. balances
+	// is the state after branch and must not be modified.
+	VerifySeal(block *Block, branch []*Block, balances map[string]float64) error
+	// Weight is what block adds to its branch in fork choice
+	Weight(block *Block) *big.Int
+}
+
+// Engine returns the chain's consensus engine. OpenBlockchain rejects unknown
+// names, so an unknown name only falls back to ConsensusPoW for unopened params.
+func (p ChainParams) Engine() Consensus {
+	if p.Consensus == ConsensusPoS {
+		return proofOfStake{p}
+	}
+	return proofOfWork{p}
+}
+
+// checkConsensus rejects parameters naming an unknown engine or proof of work
+func (p ChainParams) checkConsensus() error {
+	if p.Consensus != "" && p.Consensus != ConsensusPoW && p.Consensus != ConsensusPoS {
+		return fmt.Errorf("%w: %q", ErrUnknownConsensus, p.Consensus)
+	}
+	_, err := ProofOfWorkByName(p.ProofOfWork)
+	return err
+}
+
+// proofOfWork seals blocks by solving the chain's ProofOfWork at the
+// retargeted difficulty; fork choice follows the most cumulative work
+type proofOfWork struct {
+	params ChainParams
+}
+
+func (e proofOfWork) Name() string { return ConsensusPoW }
+
+func (e proofOfWork) Prepare(block *Block, branch []*Block, producer string) {
+	block.Difficulty = e.params.difficultyAt(branch, block.Index)
+	block.Bits = e.params.PoW().Bits(block.Difficulty)
+}
+
+func (e proofOfWork) VerifySeal(block *Block, branch []*Block, balances map[string]float64) error {
+	if expected := e.params.difficultyAt(branch, block.Index); block.Difficulty != expected {
+		return fmt.Errorf("%w: expected %d, got %d", ErrBadDifficulty, expected, block.Difficulty)
+	}
+	pow := e.params.PoW()
+	if block.Bits != pow.Bits(block.Difficulty) {
+		return fmt.Errorf("%w: bits %08x do not encode the target for difficulty %d", ErrBadDifficulty, block.Bits, block.Difficulty)
+	}
+	if block.Validator != "" || block.Signature != "" {
+		return fmt.Errorf("%w: proof-of-work blocks are not signed", ErrBadSeal)
+	}
+	if block.Hash != block.CalculateHash(pow) {
+		return ErrBadHash
+	}
+	if !block.IsValidHash(pow) {
+		return ErrInsufficientWork
+	}
+	return nil
+}
+
+func (e proofOfWork) Weight(block *Block) *big.Int {
+	return block.Work(e.params.PoW())
+}
@@ -0,0 +1,125 @@
+package blockchain
+
+import "math/big"
+
+const (
+	// DefaultDifficulty is the number of leading hex zeros required of the
+	// genesis block under the default proof of work
+	DefaultDifficulty = 4
+	// MinDifficulty and MaxDifficulty bound what retargeting can produce
+	// under the default proof of work
+	MinDifficulty = 1
+	MaxDifficulty = 6
+	// DefaultRetargetInterval is how many blocks pass between difficulty adjustments
+	DefaultRetargetInterval = 5
+	// DefaultTargetBlockTime is the desired number of seconds between blocks
+	DefaultTargetBlockTime = 10
+	// GenesisTimestamp is the fixed timestamp of every genesis block
+	GenesisTimestamp = 1735689600
+	// DefaultMaxBlockTransactions and DefaultMaxBlockBytes cap the
+	// non-coinbase transactions in a block
+	DefaultMaxBlockTransactions = 50
+	DefaultMaxBlockBytes        = 64 * 1024
+)
+
+// ChainParams holds the consensus parameters that govern the consensus
+// engine, difficulty and block size. A zero block limit means unlimited.
+type ChainParams struct {
+	Consensus            string `json:"consensus"`              // Name of the engine; empty means ConsensusPoW
+	ProofOfWork          string `json:"proofOfWork"`            // Name of the algorithm; empty means PoWSHA256
+	SlotDuration         int64  `json:"slotDuration,omitempty"` // Seconds per proof-of-stake slot
+	InitialDifficulty    int    `json:"initialDifficulty"`
+	RetargetInterval     int    `json:"retargetInterval"`
+	TargetBlockTime      int64  `json:"targetBlockTime"`
+	MaxBlockTransactions int    `json:"maxBlockTransactions"`
+	MaxBlockBytes        int    `json:"maxBlockBytes"`
+}
+
+// DefaultChainParams returns the parameters used by NewBlockchain
+func DefaultChainParams() ChainParams {
+	return ChainParams{
+		Consensus:            ConsensusPoW,
+		ProofOfWork:          PoWSHA256,
+		InitialDifficulty:    DefaultDifficulty,
+		RetargetInterval:     DefaultRetargetInterval,
+		TargetBlockTime:      DefaultTargetBlockTime,
+		MaxBlockTransactions: DefaultMaxBlockTransactions,
+		MaxBlockBytes:        DefaultMaxBlockBytes,
+	}
+}
+
+// NewChainParams returns the default parameters for the named proof of work,
+// starting at its default difficulty
+func NewChainParams(proofOfWork string) (ChainParams, error) {
+	pow, err := ProofOfWorkByName(proofOfWork)
+	if err != nil {
+		return ChainParams{}, err
+	}
+	params := DefaultChainParams()
+	params.ProofOfWork = pow.Name()
+	params.InitialDifficulty = pow.DefaultDifficulty()
+	return params, nil
+}
+
+// PoW returns the chain's proof of work. OpenBlockchain rejects unknown
+// names, so an unknown name only falls back to PoWSHA256 for unopened params.
+func (p ChainParams) PoW() ProofOfWork {
+	pow, err := ProofOfWorkByName(p.ProofOfWork)
+	if err != nil {
+		return proofsOfWork[PoWSHA256]
+	}
+	return pow
+}
+
+// difficultyAt returns the difficulty required of the block at index, given
+// the blocks before it. Every RetargetInterval blocks the difficulty moves one
+// step toward the target block time: up if the last window was mined in less
+// than half the expected time, down if it took more than twice as long.
+// Proof-of-stake blocks have no difficulty.
+func (p ChainParams) difficultyAt(blocks []*Block, index int) int {
+	if p.Consensus == ConsensusPoS {
+		return 0
+	}
+	if index == 0 {
+		return p.InitialDifficulty
+	}
+
+	prev := blocks[index-1].Difficulty
+	if p.RetargetInterval < 2 || index%p.RetargetInterval != 0 {
+		return prev
+	}
+
+	first := blocks[index-p.RetargetInterval]
+	if first.Index == 0 {
+		// The genesis timestamp is fixed, so it says nothing about block times
+		first = blocks[1]
+	}
+	last := blocks[index-1]
+	actual := last.Timestamp - first.Timestamp
+	expected := p.TargetBlockTime * int64(last.Index-first.Index)
+
+	next := prev
+	if actual < expected/2 {
+		next++
+	} else if actual > expected*2 {
+		next--
+	}
+
+	min, max := p.PoW().DifficultyBounds()
+	if next < min {
+		next = min
+	}
+	if next > max {
+		next = max
+	}
+	return next
+}
+
+// Work returns the expected number of hashes needed to find the block under
+// pow, derived from the target pow.Target sets for the block's difficulty:
+// 2^256 / (target + 1). For the leading hex zero algorithm that is
+// 16^Difficulty; for the bit-target algorithms, whose targets go through
+// the compact Bits encoding, it is about 2^Difficulty.
+func (b *Block) Work(pow ProofOfWork) *big.Int {
+	return WorkForTarget(pow.Target(b.Difficulty))
+}
@@ -0,0 +1,34 @@
+package blockchain
+
+import "errors"
+
+// Errors returned when a block is rejected
+var (
+	ErrKnownBlock       = errors.New("block is already known")
+	ErrBadPrevHash      = errors.New("block's previous hash does not match a known block")
+	ErrBadIndex         = errors.New("block index does not follow its parent")
+	ErrBadHash          = errors.New("block hash does not match its contents")
+	ErrBadHeader        = errors.New("block header is malformed")
+	ErrBadDifficulty    = errors.New("block difficulty does not match the retarget schedule")
+	ErrInsufficientWork = errors.New("block hash does not meet its difficulty")
+	ErrBadSlot          = errors.New("block slot does not match its timestamp or follow its parent")
+	ErrNotSlotLeader    = errors.New("block is not proposed by the slot leader")
+	ErrBadSeal          = errors.New("block signature does not match its validator")
+	ErrBadTimestamp     = errors.New("block timestamp is out of range")
+	ErrBadMerkleRoot    = errors.New("block merkle root does not match its transactions")
+	ErrBadCoinbase      = errors.New("block must contain exactly one coinbase paying at most the mining reward plus fees")
+	ErrBlockTooLarge    = errors.New("block exceeds the transaction count or size limit")
+	ErrInvalidTx        = errors.New("block contains an invalid transaction")
+)
+
+// Errors returned when a transaction is rejected
+var (
+	ErrInvalidAmount        = errors.New("transaction amount must be positive")
+	ErrInvalidFee           = errors.New("transaction fee must not be negative")
+	ErrInvalidAddress       = errors.New("transaction sender and recipient are required")
+	ErrInsufficientFunds    = errors.New("sender has insufficient funds")
+	ErrDuplicateTransaction = errors.New("transaction is already pending or confirmed")
+	ErrCoinbaseNotAllowed   = errors.New("coinbase transactions can only be created by miners")
+	ErrMissingSignature     = errors.New("transaction is not signed")
+	ErrInvalidSignature     = errors.New("transaction signature does not match its sender and contents")
+)
@@ -0,0 +1,194 @@
+package blockchain
+
+import (
+	"blockchain-visualizer/events"
+	"blockchain-visualizer/locks"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	blockLogFile = "blocks.log"
+	mempoolFile  = "mempool.json"
+)
+
+// FileStore persists blocks to an append-only log and the mempool to a
+// snapshot file. Each log record is "<crc32> <json>\n"; a torn or corrupt
+// record at the end of the log, left by a crash mid-append, is truncated away
+// when the log is loaded and reported as a BlockLogRepaired event.
+type FileStore struct {
+	dir   string
+	log   *os.File
+	mutex *locks.Mutex
+}
+
+// Reasons a block log record is discarded
+const (
+	RecordTorn    = "torn"    // The last record has no terminating newline
+	RecordCorrupt = "corrupt" // The record fails its checksum or does not decode
+)
+
+// LogRepair is the payload published when the block log is truncated
+type LogRepair struct {
+	Offset int64  `json:"offset"` // Where the discarded record started, and the log's new length
+	Reason string `json:"reason"`
+}
+
+// OpenFileStore opens (creating if needed) a file store in dir
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create data directory: %w", err)
+	}
+	log, err := os.OpenFile(filepath.Join(dir, blockLogFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open block log: %w", err)
+	}
+	return &FileStore{dir: dir, log: log, mutex: locks.NewMutex("file store")}, nil
+}
+
+func (s *FileStore) AppendBlock(block *Block) error {
+	data, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+	record := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(data), data)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, err := s.log.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if _, err := s.log.WriteString(record); err != nil {
+		return fmt.Errorf("append block %d: %w", block.Index, err)
+	}
+	return s.log.Sync()
+}
+
+func (s *FileStore) LoadBlocks() ([]*Block, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, err := s.log.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	blocks := []*Block{}
+	reader := bufio.NewReader(s.log)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				return blocks, s.repair(offset, RecordTorn)
+			}
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		block, ok := decodeRecord(line)
+		if !ok {
+			return blocks, s.repair(offset, RecordCorrupt)
+		}
+		blocks = append(blocks, block)
+		offset += int64(len(line))
+	}
+}
+
+// decodeRecord parses and checksums one block log record
+func decodeRecord(line []byte) (*Block, bool) {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	sep := bytes.IndexByte(line, ' ')
+	if sep < 0 {
+		return nil, false
+	}
+	sum, err := strconv.ParseUint(string(line[:sep]), 16, 32)
+	if err != nil {
+		return nil, false
+	}
+	data := line[sep+1:]
+	if crc32.ChecksumIEEE(data) != uint32(sum) {
+		return nil, false
+	}
+	var block Block
+	if err := json.Unmarshal(data, &block); err != nil {
+		return nil, false
+	}
+	return &block, true
+}
+
+// repair drops everything in the block log after offset and reports why
+func (s *FileStore) repair(offset int64, reason string) error {
+	if err := s.log.Truncate(offset); err != nil {
+		return fmt.Errorf("truncate block log: %w", err)
+	}
+	events.Publishf(events.BlockLogRepaired, LogRepair{Offset: offset, Reason: reason},
+		"■ Discarding %s block record at offset %d", reason, offset)
+	return s.log.Sync()
+}
+
+// SaveMempool writes the snapshot to a temporary file and renames it into
+// place so a crash never leaves a half-written mempool behind
+func (s *FileStore) SaveMempool(transactions []Transaction) error {
+	data, err := json.Marshal(transactions)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	path := filepath.Join(s.dir, mempoolFile)
+	tmp, err := os.CreateTemp(s.dir, mempoolFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStore) LoadMempool() ([]Transaction, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(s.dir, mempoolFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Transaction{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var transactions []Transaction
+	if err := json.Unmarshal(data, &transactions); err != nil {
+		return nil, fmt.Errorf("decode mempool: %w", err)
+	}
+	return transactions, nil
+}
+
+func (s *FileStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.log.Close()
+}
@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"blockchain-visualizer/events"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadBlocksReportsDiscardedRecords(t *testing.T) {
+	for _, test := range []struct {
+		reason string
+		tail   string
+	}{
+		{RecordTorn, `0000abcd {"Index":2`},
+		{RecordCorrupt, "0000abcd {\"Index\":2}\n"},
+	} {
+		t.Run(test.reason, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := OpenFileStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.AppendBlock(&Block{Index: 1}); err != nil {
+				t.Fatal(err)
+			}
+			store.Close()
+
+			path := filepath.Join(dir, blockLogFile)
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			log, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			log.WriteString(test.tail)
+			log.Close()
+
+			repairs, unsubscribe := events.Default.Subscribe(8)
+			defer unsubscribe()
+			store, err = OpenFileStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			blocks, err := store.LoadBlocks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(blocks) != 1 {
+				t.Fatalf("loaded %d blocks, want 1", len(blocks))
+			}
+
+			var repair *LogRepair
+			for len(repairs) > 0 && repair == nil {
+				if event := <-repairs; event.Type == events.BlockLogRepaired {
+					r := event.Data.(LogRepair)
+					repair = &r
+				}
+			}
+			if want := (LogRepair{Offset: info.Size(), Reason: test.reason}); repair == nil || *repair != want {
+				t.Errorf("repair %+v, want %+v", repair, want)
+			}
+			if truncated, _ := os.Stat(path); truncated.Size() != info.Size() {
+				t.Errorf("log is %d bytes after repair, want %d", truncated.Size(), info.Size())
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"blockchain-visualizer/events"
+)
+
+// ForkEvent is the payload published when a block lands on a side branch
+type ForkEvent struct {
+	Block     *Block `json:"block"`
+	TipHash   string `json:"tipHash"`
+	Work      string `json:"work"`
+	TipWork   string `json:"tipWork"`
+	ForkIndex int    `json:"forkIndex"`
+}
+
+// ReorgEvent is the payload published when a heavier branch replaces the main chain
+type ReorgEvent struct {
+	ForkIndex    int    `json:"forkIndex"`
+	OldTip       string `json:"oldTip"`
+	NewTip       string `json:"newTip"`
+	Disconnected int    `json:"disconnected"`
+	Connected    int    `json:"connected"`
+	Returned     int    `json:"returned"`
+}
+
+// BranchTip describes the tip of one branch of the block tree
+type BranchTip struct {
+	Hash      string `json:"hash"`
+	Index     int    `json:"index"`
+	Work      string `json:"work"`
+	ForkIndex int    `json:"forkIndex"` // Last block shared with the main chain
+	Main      bool   `json:"main"`
+}
+
+// acceptBlock validates a solved block, adds it to the block tree, and makes
+// it part of the main chain if it extends the tip or its branch now has the
+// most cumulative work. The caller must hold the write lock.
+func (bc *Blockchain) acceptBlock(block *Block) error {
+	if _, ok := bc.tree[block.Hash]; ok {
+		return ErrKnownBlock
+	}
+	parent, ok := bc.tree[block.PreviousHash]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrBadPrevHash, block.PreviousHash)
+	}
+	if err := bc.validateAgainstParent(block, parent); err != nil {
+		return err
+	}
+
+	if !bc.replaying {
+		if err := bc.store.AppendBlock(block); err != nil {
+			return fmt.Errorf("persist block %d: %w", block.Index, err)
+		}
+	}
+	bc.tree[block.Hash] = block
+	bc.work[block.Hash] = new(big.Int).Add(bc.work[parent.Hash], bc.Params.Engine().Weight(block))
+
+	tip := bc.Blocks[len(bc.Blocks)-1]
+	if parent.Hash == tip.Hash {
+		return bc.extendChain(block)
+	}
+	if bc.work[block.Hash].Cmp(bc.work[tip.Hash]) <= 0 {
+		if !bc.replaying {
+			events.Publishf(events.ForkDetected, ForkEvent{
+				Block:     block,
+				TipHash:   tip.Hash,
+				Work:      bc.work[block.Hash].String(),
+				TipWork:   bc.work[tip.Hash].String(),
+				ForkIndex: bc.forkIndex(block.Hash),
+			}, "⑂ Block %d (%s) stored on a side branch", block.Index, block.Hash)
+		}
+		return nil
+	}
+	return bc.reorganize(block)
+}
+
+// extendChain appends a block whose parent is the tip. The caller must hold the write lock.
+func (bc *Blockchain) extendChain(block *Block) error {
+	bc.Blocks = append(bc.Blocks, block)
+	bc.applyBlock(block)
+	bc.notifyTipChanged()
+	if !bc.replaying {
+		publishBlockAppended(block)
+	}
+	return bc.pruneConfirmed()
+}
+
+// reorganize switches the main chain to the branch ending at newTip. Non-coinbase
+// transactions from the abandoned blocks go back to the mempool if they are
+// still valid on the new branch. The caller must hold the write lock.
+func (bc *Blockchain) reorganize(newTip *Block) error {
+	newBranch := bc.branch(newTip.Hash)
+	fork := 0
+	for fork < len(bc.Blocks) && fork < len(newBranch) && bc.Blocks[fork].Hash == newBranch[fork].Hash {
+		fork++
+	}
+	oldTip := bc.Blocks[len(bc.Blocks)-1]
+	disconnected := bc.Blocks[fork:]
+	connected := newBranch[fork:]
+
+	bc.Blocks = newBranch
+	bc.rebuildState()
+	bc.notifyTipChanged()
+	if bc.replaying {
+		// The stored mempool already reflects the outcome of this reorg
+		return nil
+	}
+
+	// Orphaned transactions are revalidated first so they win any conflict
+	// with transactions submitted since
+	candidates := []Transaction{}
+	for _, block := range disconnected {
+		for _, tx := range block.Transactions {
+			if tx.Sender != CoinbaseSender {
+				candidates = append(candidates, tx)
+			}
+		}
+	}
+	orphaned := len(candidates)
+	candidates = append(candidates, bc.mempool.All()...)
+
+	// Revalidate into an empty pool, then swap the survivors back into the
+	// current one so in-flight block templates keep their reservations
+	previous := bc.mempool
+	bc.mempool = NewMempool()
+	returned := 0
+	for i, tx := range candidates {
+		if bc.validateTransaction(tx) == nil {
+			bc.mempool.Add(tx)
+			if i < orphaned {
+				returned++
+			}
+		}
+	}
+	previous.Replace(bc.mempool)
+	bc.mempool = previous
+
+	if err := bc.store.SaveMempool(bc.mempool.All()); err != nil {
+		return fmt.Errorf("persist mempool: %w", err)
+	}
+	events.Publishf(events.Reorg, ReorgEvent{
+		ForkIndex:    fork - 1,
+		OldTip:       oldTip.Hash,
+		NewTip:       newTip.Hash,
+		Disconnected: len(disconnected),
+		Connected:    len(connected),
+		Returned:     returned,
+	}, "⑂ Reorganized from block %d to block %d at fork point %d (%d blocks disconnected, %d connected)",
+		oldTip.Index, newTip.Index, fork-1, len(disconnected), len(connected))
+	for _, block := range connected {
+		publishBlockAppended(block)
+	}
+	return nil
+}
+
+// notifyTipChanged wakes everyone waiting on WatchTip. The caller must hold the write lock.
+func (bc *Blockchain) notifyTipChanged() {
+	close(bc.tipChanged)
+	bc.tipChanged = make(chan struct{})
+}
+
+// pruneConfirmed drops pending transactions that are now on the main chain.
+// The caller must hold the write lock.
+func (bc *Blockchain) pruneConfirmed() error {
+	removed := bc.mempool.Filter(func(tx Transaction) bool {
+		_, confirmed := bc.txIndex[tx.ID]
+		return !confirmed
+	})
+	if !removed || bc.replaying {
+		return nil
+	}
+	if err := bc.store.SaveMempool(bc.mempool.All()); err != nil {
+		return fmt.Errorf("persist mempool: %w", err)
+	}
+	return nil
+}
+
+// branch returns the blocks from genesis to the block with the given hash.
+// The caller must hold the lock.
+func (bc *Blockchain) branch(hash string) []*Block {
+	blocks := []*Block{}
+	for block, ok := bc.tree[hash]; ok; block, ok = bc.tree[block.PreviousHash] {
+		blocks = append(blocks, block)
+	}
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+	return blocks
+}
+
+// forkIndex returns the index of the last main chain block on the branch
+// ending at hash. The caller must hold the lock.
+func (bc *Blockchain) forkIndex(hash string) int {
+	for block, ok := bc.tree[hash]; ok; block, ok = bc.tree[block.PreviousHash] {
+		if index, onMain := bc.hashIndex[block.Hash]; onMain {
+			return index
+		}
+	}
+	return -1
+}
+
+// TotalWork returns the cumulative work of the main chain
+func (bc *Blockchain) TotalWork() *big.Int {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return new(big.Int).Set(bc.work[bc.Blocks[len(bc.Blocks)-1].Hash])
+}
+
+// Tips returns the tip of every branch in the block tree, main chain first
+func (bc *Blockchain) Tips() []BranchTip {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	hasChild := make(map[string]bool)
+	for _, block := range bc.tree {
+		hasChild[block.PreviousHash] = true
+	}
+
+	tips := []BranchTip{}
+	for hash, block := range bc.tree {
+		if hasChild[hash] {
+			continue
+		}
+		_, main := bc.hashIndex[hash]
+		tips = append(tips, BranchTip{
+			Hash:      hash,
+			Index:     block.Index,
+			Work:      bc.work[hash].String(),
+			ForkIndex: bc.forkIndex(hash),
+			Main:      main,
+		})
+	}
+	sort.Slice(tips, func(i, j int) bool {
+		if tips[i].Main != tips[j].Main {
+			return tips[i].Main
+		}
+		return bc.work[tips[i].Hash].Cmp(bc.work[tips[j].Hash]) > 0
+	})
+	return tips
+}
@@ -0,0 +1,161 @@
+package blockchain
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+)
+
+// testParams are quick to mine and never retarget, so a block's difficulty
+// is always its parent's
+func testParams() ChainParams {
+	params := DefaultChainParams()
+	params.InitialDifficulty = MinDifficulty
+	params.RetargetInterval = 0
+	return params
+}
+
+// wallet is a key pair and the address derived from it
+type wallet struct {
+	key     *rsa.PrivateKey
+	address string
+}
+
+func newWallet(t *testing.T) wallet {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wallet{key: key, address: AddressFromPublicKey(&key.PublicKey)}
+}
+
+// pay returns a signed transaction from w to recipient
+func (w wallet) pay(t *testing.T, recipient string, amount, fee float64) Transaction {
+	t.Helper()
+	tx := NewTransactionWithFee(w.address, recipient, amount, fee)
+	if err := tx.Sign(w.key); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// newBlockOn mines a block on parent holding transactions and a coinbase
+// paying miner their fees, without adding it to any chain
+func newBlockOn(params ChainParams, parent *Block, miner string, transactions ...Transaction) *Block {
+	fees := 0.0
+	for _, tx := range transactions {
+		fees += tx.Fee
+	}
+	coinbase := NewCoinbaseTransaction(miner, parent.Index+1, fees)
+	block := NewBlock(parent.Index+1, parent.Hash, append(append([]Transaction{}, transactions...), coinbase), parent.Difficulty)
+	block.MineBlock(params.PoW())
+	return block
+}
+
+// mineOn mines a block on parent like newBlockOn and adds it to bc
+func mineOn(t *testing.T, bc *Blockchain, parent *Block, miner string, transactions ...Transaction) *Block {
+	t.Helper()
+	block := newBlockOn(bc.Params, parent, miner, transactions...)
+	if err := bc.AddMinedBlock(block); err != nil {
+		t.Fatalf("adding block %d: %v", block.Index, err)
+	}
+	return block
+}
+
+func TestReorgSwitchesToHeavierBranch(t *testing.T) {
+	bc := NewBlockchainWithParams(testParams())
+	alice := newWallet(t)
+	funded := mineOn(t, bc, bc.GetLatestBlock(), alice.address)
+
+	payment := alice.pay(t, "bob", 0.5, 0.1)
+	if err := bc.AddTransaction(payment); err != nil {
+		t.Fatal(err)
+	}
+	a1 := mineOn(t, bc, funded, "miner-a", payment)
+	if pending := bc.GetPendingTransactions(); len(pending) != 0 {
+		t.Fatalf("pending %v after the payment was mined, want none", pending)
+	}
+
+	// An equally heavy branch does not replace the main chain
+	b1 := mineOn(t, bc, funded, "miner-b")
+	if tip := bc.GetLatestBlock(); tip.Hash != a1.Hash || bc.IsOnMainChain(b1.Hash) {
+		t.Fatalf("tip %s after a competing block, want %s", tip.Hash, a1.Hash)
+	}
+	if tips := bc.Tips(); len(tips) != 2 || tips[0].Hash != a1.Hash || tips[1].Hash != b1.Hash {
+		t.Errorf("tips %+v, want %s on the main chain and %s beside it", tips, a1.Hash, b1.Hash)
+	}
+
+	b2 := mineOn(t, bc, b1, "miner-b")
+	if tip := bc.GetLatestBlock(); tip.Hash != b2.Hash || bc.IsOnMainChain(a1.Hash) {
+		t.Fatalf("tip %s after the side branch got heavier, want %s", tip.Hash, b2.Hash)
+	}
+	for address, want := range map[string]float64{alice.address: 1, "bob": 0, "miner-a": 0, "miner-b": 2} {
+		if balance := bc.Balance(address); balance != want {
+			t.Errorf("balance of %s is %v after the reorg, want %v", address, balance, want)
+		}
+	}
+
+	// The payment goes back to the mempool and the abandoned coinbase is forgotten
+	if pending := bc.GetPendingTransactions(); len(pending) != 1 || pending[0].ID != payment.ID {
+		t.Errorf("pending %v after the reorg, want the orphaned payment", pending)
+	}
+	if info, ok := bc.GetTransaction(payment.ID); !ok || info.Status != TxPending {
+		t.Errorf("orphaned payment is %+v, want it pending", info)
+	}
+	coinbase := a1.Transactions[len(a1.Transactions)-1]
+	if info, ok := bc.GetTransaction(coinbase.ID); ok {
+		t.Errorf("abandoned coinbase is %+v, want it unknown", info)
+	}
+	if err := bc.Validate(); err != nil {
+		t.Errorf("chain invalid after the reorg: %v", err)
+	}
+
+	if err := bc.AddMinedBlock(a1); !errors.Is(err, ErrKnownBlock) {
+		t.Errorf("adding the abandoned block again returned %v, want %v", err, ErrKnownBlock)
+	}
+	if err := bc.AddMinedBlock(b2); !errors.Is(err, ErrKnownBlock) {
+		t.Errorf("adding the tip again returned %v, want %v", err, ErrKnownBlock)
+	}
+
+	// The returned payment can be mined on the new branch
+	b3 := mineOn(t, bc, b2, "miner-b", payment)
+	if info, ok := bc.GetTransaction(payment.ID); !ok || info.Status != TxConfirmed || info.BlockHash != b3.Hash {
+		t.Errorf("payment is %+v, want it confirmed in %s", info, b3.Hash)
+	}
+}
+
+func TestReorgDropsOrphanedDoubleSpend(t *testing.T) {
+	bc := NewBlockchainWithParams(testParams())
+	alice := newWallet(t)
+	funded := mineOn(t, bc, bc.GetLatestBlock(), alice.address)
+
+	toBob := alice.pay(t, "bob", 0.5, 0.1)
+	toCarol := alice.pay(t, "carol", 0.9, 0)
+	mineOn(t, bc, funded, "miner-a", toBob)
+
+	// The heavier branch spends the same funds elsewhere, so the payment to
+	// bob can no longer be made and must not return to the mempool
+	b1 := mineOn(t, bc, funded, "miner-b", toCarol)
+	mineOn(t, bc, b1, "miner-b")
+
+	if pending := bc.GetPendingTransactions(); len(pending) != 0 {
+		t.Errorf("pending %v after the reorg, want the double spend dropped", pending)
+	}
+	for address, want := range map[string]float64{"bob": 0, "carol": 0.9} {
+		if balance := bc.Balance(address); balance != want {
+			t.Errorf("balance of %s is %v after the reorg, want %v", address, balance, want)
+		}
+	}
+}
+
+func TestAcceptBlockRejectsUnknownParent(t *testing.T) {
+	bc := NewBlockchainWithParams(testParams())
+	other := NewBlockchainWithParams(testParams())
+	orphan := newBlockOn(other.Params, mineOn(t, other, other.GetLatestBlock(), "miner"), "miner")
+
+	if err := bc.AddMinedBlock(orphan); !errors.Is(err, ErrBadPrevHash) {
+		t.Errorf("adding a block with an unknown parent returned %v, want %v", err, ErrBadPrevHash)
+	}
+}
@@ -0,0 +1,178 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strconv"
+)
+
+// Block header versions
+const (
+	// HeaderVersionLegacy hashes the fields concatenated as decimal strings.
+	// The encoding is ambiguous, so it is only accepted for stored chains
+	// that started before HeaderVersionBinary.
+	HeaderVersionLegacy = 0
+	// HeaderVersionBinary hashes the fixed-width encoding built by Header
+	HeaderVersionBinary = 1
+	// CurrentHeaderVersion is the version NewBlock creates
+	CurrentHeaderVersion = HeaderVersionBinary
+)
+
+// Layout of a binary header. Every field has a fixed width and integers are
+// big-endian. The nonce comes last, after exactly two 64-byte SHA-256
+// blocks, so the midstate over everything before it can be reused while
+// only the nonce changes.
+const (
+	headerVersionOffset    = 0   // uint32
+	headerIndexOffset      = 4   // uint64
+	headerTimestampOffset  = 12  // int64
+	headerPrevHashOffset   = 20  // 32 bytes, zero for the genesis block
+	headerMerkleRootOffset = 52  // 32 bytes
+	headerDifficultyOffset = 84  // uint32
+	headerBitsOffset       = 88  // uint32
+	headerSlotOffset       = 92  // uint64
+	headerValidatorOffset  = 100 // 20 byte address, zero for proof-of-work blocks
+	headerExtraNonceOffset = 120 // uint64
+	headerNonceOffset      = 128 // uint64
+	// HeaderSize is the length of a binary header
+	HeaderSize = 136
+)
+
+// addressSize is the length of a decoded wallet address
+const addressSize = 20
+
+// Header serializes the fields the block hash commits to, in the encoding
+// of the block's version
+func (b *Block) Header() []byte {
+	if b.Version == HeaderVersionLegacy {
+		return b.legacyHeader()
+	}
+
+	header := make([]byte, HeaderSize)
+	binary.BigEndian.PutUint32(header[headerVersionOffset:], b.Version)
+	binary.BigEndian.PutUint64(header[headerIndexOffset:], uint64(b.Index))
+	binary.BigEndian.PutUint64(header[headerTimestampOffset:], uint64(b.Timestamp))
+	hex.Decode(header[headerPrevHashOffset:headerMerkleRootOffset], []byte(b.PreviousHash))
+	hex.Decode(header[headerMerkleRootOffset:headerDifficultyOffset], []byte(b.MerkleRoot))
+	binary.BigEndian.PutUint32(header[headerDifficultyOffset:], uint32(b.Difficulty))
+	binary.BigEndian.PutUint32(header[headerBitsOffset:], b.Bits)
+	binary.BigEndian.PutUint64(header[headerSlotOffset:], uint64(b.Slot))
+	hex.Decode(header[headerValidatorOffset:headerExtraNonceOffset], []byte(b.Validator))
+	binary.BigEndian.PutUint64(header[headerExtraNonceOffset:], uint64(b.ExtraNonce))
+	binary.BigEndian.PutUint64(header[headerNonceOffset:], uint64(b.Nonce))
+	return header
+}
+
+// legacyHeader is the HeaderVersionLegacy encoding. Bits and the
+// proof-of-stake slot and validator are only appended when set, so blocks
+// mined before they existed keep their hashes.
+func (b *Block) legacyHeader() []byte {
+	data := strconv.Itoa(b.Index) + strconv.FormatInt(b.Timestamp, 10) + b.PreviousHash + b.MerkleRoot +
+		strconv.Itoa(b.ExtraNonce) + ":" + strconv.Itoa(b.Nonce)
+	if b.Bits != 0 {
+		data += ":" + strconv.FormatUint(uint64(b.Bits), 16)
+	}
+	if b.Validator != "" {
+		data += ":" + strconv.Itoa(b.Slot) + ":" + b.Validator
+	}
+	return []byte(data)
+}
+
+// checkHeader rejects unknown versions and, for binary headers, fields that
+// do not fit their fixed width, since Header could not encode them faithfully
+func (b *Block) checkHeader() error {
+	switch b.Version {
+	case HeaderVersionLegacy:
+		return nil
+	case HeaderVersionBinary:
+	default:
+		return fmt.Errorf("%w: unknown version %d", ErrBadHeader, b.Version)
+	}
+
+	if b.Index < 0 || b.Timestamp < 0 || b.Difficulty < 0 || b.Slot < 0 || b.ExtraNonce < 0 || b.Nonce < 0 {
+		return fmt.Errorf("%w: negative field", ErrBadHeader)
+	}
+	if b.PreviousHash != "" && !isHex(b.PreviousHash, sha256.Size) {
+		return fmt.Errorf("%w: previous hash is not %d bytes of hex", ErrBadHeader, sha256.Size)
+	}
+	if !isHex(b.MerkleRoot, sha256.Size) {
+		return fmt.Errorf("%w: merkle root is not %d bytes of hex", ErrBadHeader, sha256.Size)
+	}
+	if b.Validator != "" && !isHex(b.Validator, addressSize) {
+		return fmt.Errorf("%w: validator is not a %d byte address", ErrBadHeader, addressSize)
+	}
+	return nil
+}
+
+// isHex reports whether s is exactly size bytes of lower-case hex
+func isHex(s string, size int) bool {
+	if len(s) != 2*size {
+		return false
+	}
+	decoded, err := hex.DecodeString(s)
+	return err == nil && hex.EncodeToString(decoded) == s
+}
+
+// sha256Finisher is implemented by proofs of work whose first step is a
+// single SHA-256 of the header. It returns the function that turns that
+// digest into the final hash, or nil if the algorithm does not qualify.
+type sha256Finisher interface {
+	sha256Finisher() func(digest []byte) []byte
+}
+
+// NonceHasher hashes one block header with one nonce after another. For
+// binary headers under a SHA-256 based proof of work it hashes the 128
+// bytes before the nonce once and resumes from that midstate for every
+// nonce, so each hash only compresses the final 64-byte block.
+type NonceHasher struct {
+	pow      ProofOfWork
+	block    Block // Copy hashed in full when there is no midstate
+	digest   hash.Hash
+	restore  encoding.BinaryUnmarshaler // digest, for resetting it to the midstate
+	midstate []byte
+	finish   func([]byte) []byte
+	nonce    [8]byte
+	sum      []byte
+}
+
+// NewNonceHasher prepares to hash block with pow for varying nonces. A change
+// to any other header field needs a new NonceHasher.
+func NewNonceHasher(pow ProofOfWork, block *Block) *NonceHasher {
+	h := &NonceHasher{pow: pow, block: *block}
+
+	finisher, ok := pow.(sha256Finisher)
+	if !ok || finisher.sha256Finisher() == nil || block.Version != HeaderVersionBinary {
+		return h
+	}
+	digest := sha256.New()
+	digest.Write(block.Header()[:headerNonceOffset])
+	midstate, err := digest.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return h
+	}
+
+	h.digest = digest
+	h.restore = digest.(encoding.BinaryUnmarshaler)
+	h.midstate = midstate
+	h.finish = finisher.sha256Finisher()
+	h.sum = make([]byte, 0, sha256.Size)
+	return h
+}
+
+// Sum returns the hash of the header with nonce. The result is only valid
+// until the next call.
+func (h *NonceHasher) Sum(nonce int) []byte {
+	if h.digest == nil {
+		h.block.Nonce = nonce
+		return h.pow.Sum(h.block.Header())
+	}
+
+	h.restore.UnmarshalBinary(h.midstate)
+	binary.BigEndian.PutUint64(h.nonce[:], uint64(nonce))
+	h.digest.Write(h.nonce[:])
+	return h.finish(h.digest.Sum(h.sum[:0]))
+}
@@ -0,0 +1,102 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+)
+
+// benchmarkBlock returns a template like the ones miners work on
+func benchmarkBlock(version uint32) *Block {
+	coinbase := NewCoinbaseTransaction("91829a68de5060ab7281baeb154a55829a679f0f", 1, 0)
+	block := NewBlock(1, emptyMerkleRoot, []Transaction{coinbase}, 4)
+	block.Version = version
+	return block
+}
+
+func TestHeaderIsFixedWidth(t *testing.T) {
+	// The legacy encoding of index 1 with nonce 23 and of index 12 with
+	// nonce 3 differ only in where the digits are split
+	a := &Block{Version: HeaderVersionBinary, Index: 1, Nonce: 23, MerkleRoot: emptyMerkleRoot}
+	b := &Block{Version: HeaderVersionBinary, Index: 12, Nonce: 3, MerkleRoot: emptyMerkleRoot}
+
+	if len(a.Header()) != HeaderSize || len(b.Header()) != HeaderSize {
+		t.Fatalf("header sizes %d and %d, want %d", len(a.Header()), len(b.Header()), HeaderSize)
+	}
+	if bytes.Equal(a.Header(), b.Header()) {
+		t.Fatal("headers of different blocks are equal")
+	}
+}
+
+func TestNonceHasherMatchesFullHash(t *testing.T) {
+	for _, name := range ProofOfWorkNames() {
+		pow, _ := ProofOfWorkByName(name)
+		for _, version := range []uint32{HeaderVersionLegacy, HeaderVersionBinary} {
+			block := benchmarkBlock(version)
+			hasher := NewNonceHasher(pow, block)
+			for nonce := 0; nonce < 3; nonce++ {
+				block.Nonce = nonce
+				if got, want := hasher.Sum(nonce), pow.Sum(block.Header()); !bytes.Equal(got, want) {
+					t.Errorf("%s version %d nonce %d: got %x, want %x", name, version, nonce, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestCheckHeaderRejectsMalformedFields(t *testing.T) {
+	block := benchmarkBlock(HeaderVersionBinary)
+	if err := block.checkHeader(); err != nil {
+		t.Fatalf("valid header rejected: %v", err)
+	}
+
+	block.PreviousHash = "abc"
+	if err := block.checkHeader(); err == nil {
+		t.Error("short previous hash accepted")
+	}
+
+	block = benchmarkBlock(HeaderVersionBinary + 1)
+	if err := block.checkHeader(); err == nil {
+		t.Error("unknown version accepted")
+	}
+}
+
+// BenchmarkLegacyHeaderHash is the miner's hot loop before binary headers:
+// build the string header and hash all of it for every nonce
+func BenchmarkLegacyHeaderHash(b *testing.B) {
+	block := benchmarkBlock(HeaderVersionLegacy)
+	pow := proofsOfWork[PoWSHA256]
+	for i := 0; i < b.N; i++ {
+		block.Nonce = i
+		pow.Sum(block.Header())
+	}
+}
+
+// BenchmarkBinaryHeaderHash encodes and hashes the full binary header for every nonce
+func BenchmarkBinaryHeaderHash(b *testing.B) {
+	block := benchmarkBlock(HeaderVersionBinary)
+	pow := proofsOfWork[PoWSHA256]
+	for i := 0; i < b.N; i++ {
+		block.Nonce = i
+		pow.Sum(block.Header())
+	}
+}
+
+// BenchmarkNonceHasher is the miner's hot loop: resume from the midstate
+// and hash only the nonce block
+func BenchmarkNonceHasher(b *testing.B) {
+	block := benchmarkBlock(HeaderVersionBinary)
+	hasher := NewNonceHasher(proofsOfWork[PoWSHA256], block)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		hasher.Sum(i)
+	}
+}
+
+// BenchmarkNonceHasherDoubleSHA256 is the hot loop under sha256d
+func BenchmarkNonceHasherDoubleSHA256(b *testing.B) {
+	block := benchmarkBlock(HeaderVersionBinary)
+	hasher := NewNonceHasher(proofsOfWork[PoWDoubleSHA256], block)
+	for i := 0; i < b.N; i++ {
+		hasher.Sum(i)
+	}
+}
@@ -0,0 +1,160 @@
+package blockchain
+
+// Transaction states reported by GetTransaction
+const (
+	TxConfirmed = "confirmed"
+	TxPending   = "pending"
+)
+
+// BlockInfo is a block together with its place relative to the main chain
+type BlockInfo struct {
+	Block         *Block `json:"block"`
+	MainChain     bool   `json:"mainChain"`
+	Confirmations int    `json:"confirmations"` // The block and those on top of it, 0 off the main chain
+}
+
+// TransactionInfo is a transaction together with where it stands
+type TransactionInfo struct {
+	Transaction   Transaction `json:"transaction"`
+	Status        string      `json:"status"`
+	BlockIndex    int         `json:"blockIndex,omitempty"`
+	BlockHash     string      `json:"blockHash,omitempty"`
+	Confirmations int         `json:"confirmations"`
+}
+
+// MempoolInfo summarizes the pending transactions, most profitable first
+type MempoolInfo struct {
+	Count        int           `json:"count"`
+	Bytes        int           `json:"bytes"`
+	Reserved     int           `json:"reserved"` // Held by block templates being mined
+	Fees         float64       `json:"fees"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// GetBlockInfo returns the main chain block at index
+func (bc *Blockchain) GetBlockInfo(index int) (BlockInfo, bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if index < 0 || index >= len(bc.Blocks) {
+		return BlockInfo{}, false
+	}
+	return bc.blockInfo(bc.Blocks[index]), true
+}
+
+// GetBlockInfoByHash returns the block with the given hash, which may be on a side branch
+func (bc *Blockchain) GetBlockInfoByHash(hash string) (BlockInfo, bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	block, ok := bc.tree[hash]
+	if !ok {
+		return BlockInfo{}, false
+	}
+	return bc.blockInfo(block), true
+}
+
+// blockInfo places block relative to the main chain. The caller must hold the lock.
+func (bc *Blockchain) blockInfo(block *Block) BlockInfo {
+	info := BlockInfo{Block: block}
+	if index, ok := bc.hashIndex[block.Hash]; ok {
+		info.MainChain = true
+		info.Confirmations = len(bc.Blocks) - index
+	}
+	return info
+}
+
+// ChainPage is a run of main chain blocks together with the length of the
+// chain they were read from and the difficulty of its next block
+type ChainPage struct {
+	Blocks         []*Block
+	From           int // Index of the first block in Blocks
+	Length         int
+	NextDifficulty int
+}
+
+// GetBlockRange returns up to limit main chain blocks starting at index from
+func (bc *Blockchain) GetBlockRange(from, limit int) []*Block {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return bc.blockRange(from, limit)
+}
+
+// GetChainPage returns up to limit main chain blocks starting at index
+// from, or the last limit blocks if from is negative. The blocks, length
+// and next difficulty are read together, so a block landing meanwhile
+// cannot make them disagree.
+func (bc *Blockchain) GetChainPage(from, limit int) ChainPage {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	length := len(bc.Blocks)
+	if from < 0 {
+		from = length - limit
+		if from < 0 {
+			from = 0
+		}
+	}
+	return ChainPage{
+		Blocks:         bc.blockRange(from, limit),
+		From:           from,
+		Length:         length,
+		NextDifficulty: bc.Params.difficultyAt(bc.Blocks, length),
+	}
+}
+
+// blockRange is GetBlockRange for callers already holding the lock
+func (bc *Blockchain) blockRange(from, limit int) []*Block {
+	if from < 0 || from >= len(bc.Blocks) || limit <= 0 {
+		return []*Block{}
+	}
+	to := from + limit
+	if to > len(bc.Blocks) {
+		to = len(bc.Blocks)
+	}
+	return append([]*Block{}, bc.Blocks[from:to]...)
+}
+
+// GetTransaction finds a transaction on the main chain or in the mempool
+func (bc *Blockchain) GetTransaction(id string) (TransactionInfo, bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if index, ok := bc.txIndex[id]; ok {
+		block := bc.Blocks[index]
+		for _, tx := range block.Transactions {
+			if tx.ID == id {
+				return TransactionInfo{
+					Transaction:   tx,
+					Status:        TxConfirmed,
+					BlockIndex:    block.Index,
+					BlockHash:     block.Hash,
+					Confirmations: len(bc.Blocks) - block.Index,
+				}, true
+			}
+		}
+	}
+
+	if tx, ok := bc.mempool.Get(id); ok {
+		return TransactionInfo{Transaction: tx, Status: TxPending}, true
+	}
+	return TransactionInfo{}, false
+}
+
+// MempoolInfo returns the pending transactions and their totals
+func (bc *Blockchain) MempoolInfo() MempoolInfo {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	info := MempoolInfo{
+		Count:        bc.mempool.Len(),
+		Bytes:        bc.mempool.Bytes(),
+		Reserved:     bc.mempool.ReservedCount(),
+		Transactions: bc.mempool.All(),
+	}
+	for _, tx := range info.Transactions {
+		info.Fees += tx.Fee
+	}
+	return info
+}
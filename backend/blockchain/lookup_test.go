@@ -0,0 +1,90 @@
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+)
+
+// hashes returns the hashes of blocks in order
+func hashes(blocks []*Block) []string {
+	result := []string{}
+	for _, block := range blocks {
+		result = append(result, block.Hash)
+	}
+	return result
+}
+
+func TestGetBlockRangeAndChainPage(t *testing.T) {
+	bc := NewBlockchainWithParams(testParams())
+	for i := 0; i < 4; i++ {
+		mineOn(t, bc, bc.GetLatestBlock(), "miner")
+	}
+	blocks := bc.GetBlocks()
+
+	for _, test := range []struct {
+		from, limit int
+		want        []*Block
+	}{
+		{0, 2, blocks[:2]},
+		{3, 10, blocks[3:]},
+		{4, 1, blocks[4:]},
+		{5, 1, nil},
+		{-1, 2, nil},
+		{1, 0, nil},
+	} {
+		if got := bc.GetBlockRange(test.from, test.limit); !reflect.DeepEqual(hashes(got), hashes(test.want)) {
+			t.Errorf("range from %d limit %d is %v, want %v", test.from, test.limit, hashes(got), hashes(test.want))
+		}
+	}
+
+	// Without a start the page holds the latest blocks
+	page := bc.GetChainPage(-1, 2)
+	if page.From != 3 || page.Length != 5 || !reflect.DeepEqual(hashes(page.Blocks), hashes(blocks[3:])) {
+		t.Errorf("latest page %+v, want blocks 3 and 4 of 5", page)
+	}
+	if page.NextDifficulty != bc.NextDifficulty() {
+		t.Errorf("page says the next difficulty is %d, want %d", page.NextDifficulty, bc.NextDifficulty())
+	}
+	if page := bc.GetChainPage(-1, 10); page.From != 0 || len(page.Blocks) != 5 {
+		t.Errorf("page larger than the chain %+v, want every block", page)
+	}
+}
+
+func TestLookupsFollowTheMainChain(t *testing.T) {
+	bc := NewBlockchainWithParams(testParams())
+	alice := newWallet(t)
+	funded := mineOn(t, bc, bc.GetLatestBlock(), alice.address)
+
+	payment := alice.pay(t, "bob", 0.5, 0.1)
+	if err := bc.AddTransaction(payment); err != nil {
+		t.Fatal(err)
+	}
+	if info, ok := bc.GetTransaction(payment.ID); !ok || info.Status != TxPending || info.Confirmations != 0 {
+		t.Errorf("submitted payment is %+v, want it pending", info)
+	}
+
+	confirmed := mineOn(t, bc, funded, "miner-a", payment)
+	side := mineOn(t, bc, funded, "miner-b")
+	mineOn(t, bc, confirmed, "miner-a")
+
+	info, ok := bc.GetTransaction(payment.ID)
+	if !ok || info.Status != TxConfirmed || info.BlockIndex != confirmed.Index || info.BlockHash != confirmed.Hash || info.Confirmations != 2 {
+		t.Errorf("payment is %+v, want it confirmed in block %d with 2 confirmations", info, confirmed.Index)
+	}
+	if _, ok := bc.GetTransaction(side.Transactions[0].ID); ok {
+		t.Error("coinbase of a side branch block found as a transaction")
+	}
+
+	if info, ok := bc.GetBlockInfoByHash(confirmed.Hash); !ok || !info.MainChain || info.Confirmations != 2 {
+		t.Errorf("main chain block is %+v, want it on the main chain with 2 confirmations", info)
+	}
+	if info, ok := bc.GetBlockInfoByHash(side.Hash); !ok || info.MainChain || info.Confirmations != 0 || info.Block.Hash != side.Hash {
+		t.Errorf("side branch block is %+v, want it found off the main chain", info)
+	}
+	if _, ok := bc.GetBlockInfoByHash(emptyMerkleRoot); ok {
+		t.Error("unknown hash found")
+	}
+	if info, ok := bc.GetBlockInfo(confirmed.Index); !ok || info.Block.Hash != confirmed.Hash {
+		t.Errorf("block %d is %+v, want the main chain block", confirmed.Index, info)
+	}
+}
@@ -0,0 +1,198 @@
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// Mempool holds pending transactions ordered by fee rate, highest first,
+// with earlier arrivals ahead of later ones at the same rate. It tracks which
+// transactions are reserved by an in-flight block template and is guarded
+// by the owning Blockchain's mutex.
+type Mempool struct {
+	entries  []mempoolEntry
+	index    map[string]int    // Transaction ID to position in entries
+	reserved map[string]string // Transaction ID to reservation ID
+}
+
+// mempoolEntry caches a pending transaction's size and fee rate
+type mempoolEntry struct {
+	tx      Transaction
+	size    int
+	feeRate float64
+}
+
+// Reservation is a set of pending transactions handed to one block
+// template. While reserved they are not handed to any other template.
+type Reservation struct {
+	ID           string
+	Transactions []Transaction
+	Fees         float64
+	Bytes        int
+}
+
+// reservationCounter numbers reservations across all mempools
+var reservationCounter uint64
+
+// NewMempool creates an empty mempool
+func NewMempool() *Mempool {
+	return &Mempool{
+		index:    make(map[string]int),
+		reserved: make(map[string]string),
+	}
+}
+
+// Add inserts a transaction after every transaction with the same or a
+// higher fee rate
+func (m *Mempool) Add(tx Transaction) {
+	size := tx.Size()
+	entry := mempoolEntry{tx: tx, size: size, feeRate: tx.Fee / float64(size)}
+	position := sort.Search(len(m.entries), func(i int) bool {
+		return m.entries[i].feeRate < entry.feeRate
+	})
+	m.entries = append(m.entries, mempoolEntry{})
+	copy(m.entries[position+1:], m.entries[position:])
+	m.entries[position] = entry
+	m.reindex()
+}
+
+// Contains reports whether a transaction with the given ID is pending
+func (m *Mempool) Contains(id string) bool {
+	_, ok := m.index[id]
+	return ok
+}
+
+// Get returns the pending transaction with the given ID
+func (m *Mempool) Get(id string) (Transaction, bool) {
+	i, ok := m.index[id]
+	if !ok {
+		return Transaction{}, false
+	}
+	return m.entries[i].tx, true
+}
+
+// Len returns the number of pending transactions
+func (m *Mempool) Len() int {
+	return len(m.entries)
+}
+
+// Bytes returns the total size of the pending transactions
+func (m *Mempool) Bytes() int {
+	total := 0
+	for _, entry := range m.entries {
+		total += entry.size
+	}
+	return total
+}
+
+// All returns a copy of every pending transaction in priority order
+func (m *Mempool) All() []Transaction {
+	transactions := make([]Transaction, len(m.entries))
+	for i, entry := range m.entries {
+		transactions[i] = entry.tx
+	}
+	return transactions
+}
+
+// SpentBy returns the total amount and fees address is paying in pending transactions
+func (m *Mempool) SpentBy(address string) float64 {
+	total := 0.0
+	for _, entry := range m.entries {
+		if entry.tx.Sender == address {
+			total += entry.tx.Cost()
+		}
+	}
+	return total
+}
+
+// Filter keeps only the transactions for which keep returns true and
+// reports whether anything was removed. Reservations of removed
+// transactions are dropped with them.
+func (m *Mempool) Filter(keep func(Transaction) bool) bool {
+	kept := []mempoolEntry{}
+	for _, entry := range m.entries {
+		if keep(entry.tx) {
+			kept = append(kept, entry)
+		} else {
+			delete(m.reserved, entry.tx.ID)
+		}
+	}
+	if len(kept) == len(m.entries) {
+		return false
+	}
+	m.entries = kept
+	m.reindex()
+	return true
+}
+
+// Reserve sets aside the most profitable unreserved transactions that fit
+// in maxTransactions and maxBytes, where zero means unlimited. Transactions
+// are taken greedily by fee rate; one too large for the remaining space is
+// skipped so smaller ones behind it can still fill the block.
+func (m *Mempool) Reserve(maxTransactions, maxBytes int) *Reservation {
+	reservation := &Reservation{
+		ID:           fmt.Sprintf("r%d", atomic.AddUint64(&reservationCounter, 1)),
+		Transactions: []Transaction{},
+	}
+	for _, entry := range m.entries {
+		if maxTransactions > 0 && len(reservation.Transactions) == maxTransactions {
+			break
+		}
+		if _, taken := m.reserved[entry.tx.ID]; taken {
+			continue
+		}
+		if maxBytes > 0 && reservation.Bytes+entry.size > maxBytes {
+			continue
+		}
+		m.reserved[entry.tx.ID] = reservation.ID
+		reservation.Transactions = append(reservation.Transactions, entry.tx)
+		reservation.Fees += entry.tx.Fee
+		reservation.Bytes += entry.size
+	}
+	return reservation
+}
+
+// Release makes a reservation's transactions that are still pending
+// available to other templates again
+func (m *Mempool) Release(reservation *Reservation) {
+	for _, tx := range reservation.Transactions {
+		if m.reserved[tx.ID] == reservation.ID {
+			delete(m.reserved, tx.ID)
+		}
+	}
+}
+
+// ReservedCount returns how many pending transactions are reserved
+func (m *Mempool) ReservedCount() int {
+	return len(m.reserved)
+}
+
+// Clear removes every transaction and reservation
+func (m *Mempool) Clear() {
+	m.entries = nil
+	m.index = make(map[string]int)
+	m.reserved = make(map[string]string)
+}
+
+// Replace swaps in the transactions of other, keeping the reservations of
+// transactions that are still present
+func (m *Mempool) Replace(other *Mempool) {
+	reserved := make(map[string]string)
+	for _, entry := range other.entries {
+		if id, ok := m.reserved[entry.tx.ID]; ok {
+			reserved[entry.tx.ID] = id
+		}
+	}
+	m.entries = other.entries
+	m.reserved = reserved
+	m.reindex()
+}
+
+// reindex rebuilds the ID to position map after transactions are removed
+func (m *Mempool) reindex() {
+	m.index = make(map[string]int, len(m.entries))
+	for i, entry := range m.entries {
+		m.index[entry.tx.ID] = i
+	}
+}
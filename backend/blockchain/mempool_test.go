@@ -0,0 +1,140 @@
+package blockchain
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ids returns the IDs of transactions in order
+func ids(transactions []Transaction) []string {
+	result := []string{}
+	for _, tx := range transactions {
+		result = append(result, tx.ID)
+	}
+	return result
+}
+
+func TestReservationsAreExclusiveUntilReleased(t *testing.T) {
+	m := NewMempool()
+	a := NewTransactionWithFee("alice", "bob", 1, 0.3)
+	b := NewTransactionWithFee("alice", "bob", 1, 0.2)
+	c := NewTransactionWithFee("alice", "bob", 1, 0.1)
+	for _, tx := range []Transaction{a, b, c} {
+		m.Add(tx)
+	}
+
+	first := m.Reserve(2, 0)
+	second := m.Reserve(0, 0)
+	if want := ids([]Transaction{a, b}); !reflect.DeepEqual(ids(first.Transactions), want) {
+		t.Errorf("first reservation %v, want %v", ids(first.Transactions), want)
+	}
+	if want := ids([]Transaction{c}); !reflect.DeepEqual(ids(second.Transactions), want) {
+		t.Errorf("second reservation %v, want only what the first left, %v", ids(second.Transactions), want)
+	}
+	if m.ReservedCount() != 3 || m.Len() != 3 {
+		t.Errorf("%d of %d reserved, want all 3 reserved and still pending", m.ReservedCount(), m.Len())
+	}
+
+	// Releasing hands the transactions to the next reservation
+	m.Release(first)
+	if third := m.Reserve(0, 0); !reflect.DeepEqual(ids(third.Transactions), ids(first.Transactions)) {
+		t.Errorf("reservation after release %v, want %v", ids(third.Transactions), ids(first.Transactions))
+	}
+
+	// Releasing a transaction that was removed meanwhile leaves the pool alone
+	m.Filter(func(tx Transaction) bool { return tx.ID != c.ID })
+	m.Release(second)
+	if m.ReservedCount() != 2 || m.Len() != 2 {
+		t.Errorf("%d of %d reserved after removing a reserved transaction, want 2 of 2", m.ReservedCount(), m.Len())
+	}
+}
+
+func TestTemplatesKeepTransactionsSubmittedWhileMining(t *testing.T) {
+	bc := NewBlockchainWithParams(testParams())
+	alice := newWallet(t)
+	mineOn(t, bc, bc.GetLatestBlock(), alice.address)
+
+	first, second := alice.pay(t, "bob", 0.1, 0.01), alice.pay(t, "carol", 0.1, 0.01)
+	for _, tx := range []Transaction{first, second} {
+		if err := bc.AddTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mined := bc.NewBlockTemplate("miner")
+
+	// A transaction arriving while the template is mined goes to the next one
+	late := alice.pay(t, "dave", 0.1, 0.01)
+	if err := bc.AddTransaction(late); err != nil {
+		t.Fatal(err)
+	}
+	failed := bc.NewBlockTemplate("miner")
+	if got := ids(failed.Reservation.Transactions); !reflect.DeepEqual(got, ids([]Transaction{late})) {
+		t.Fatalf("second template reserved %v, want only the late transaction", got)
+	}
+
+	mined.Block.MineBlock(mined.PoW)
+	if err := bc.AddMinedBlock(mined.Block); err != nil {
+		t.Fatal(err)
+	}
+	bc.ReleaseTemplate(mined)
+	if pending := bc.MempoolInfo(); pending.Count != 1 || pending.Reserved != 1 || pending.Transactions[0].ID != late.ID {
+		t.Errorf("mempool %+v after mining, want only the late transaction, still reserved", pending)
+	}
+
+	// A template that is never mined gives its transactions back
+	bc.ReleaseTemplate(failed)
+	if pending := bc.MempoolInfo(); pending.Count != 1 || pending.Reserved != 0 {
+		t.Errorf("mempool %+v after releasing an unmined template, want the transaction pending and free", pending)
+	}
+	if next := bc.NewBlockTemplate("miner"); !reflect.DeepEqual(ids(next.Reservation.Transactions), ids([]Transaction{late})) {
+		t.Errorf("next template reserved %v, want the released transaction", ids(next.Reservation.Transactions))
+	}
+}
+
+func TestMempoolOrdersByFeeRate(t *testing.T) {
+	m := NewMempool()
+	low := NewTransactionWithFee("alice", "bob", 1, 0.1)
+	mid := NewTransactionWithFee("alice", "bob", 1, 0.2)
+	high := NewTransactionWithFee("alice", "bob", 1, 0.3)
+	// Same size and fee as mid, so the same rate, but it arrives later
+	sameAsMid := NewTransactionWithFee("alice", "bo2", 1, 0.2)
+	// A larger transaction pays less per byte for a higher fee
+	large := NewTransactionWithFee("alice", strings.Repeat("b", 600), 1, 0.25)
+	for _, tx := range []Transaction{low, mid, large, high, sameAsMid} {
+		m.Add(tx)
+	}
+
+	if want := ids([]Transaction{high, mid, sameAsMid, low, large}); !reflect.DeepEqual(ids(m.All()), want) {
+		t.Errorf("mempool order %v, want %v", ids(m.All()), want)
+	}
+}
+
+func TestReserveSkipsTransactionsThatDoNotFit(t *testing.T) {
+	m := NewMempool()
+	// The large transaction has the best fee rate but takes most of a block
+	large := NewTransactionWithFee("alice", strings.Repeat("b", 400), 1, 5)
+	small := NewTransactionWithFee("alice", "bob", 1, 0.3)
+	smaller := NewTransactionWithFee("alice", "bob", 1, 0.2)
+	for _, tx := range []Transaction{small, large, smaller} {
+		m.Add(tx)
+	}
+	if first := m.All()[0]; first.ID != large.ID {
+		t.Fatalf("%s is first in the mempool, want the large transaction", first.ID)
+	}
+
+	// Too few bytes for the large transaction leaves room for the two behind it
+	fits := m.Reserve(0, small.Size()+smaller.Size())
+	if want := ids([]Transaction{small, smaller}); !reflect.DeepEqual(ids(fits.Transactions), want) {
+		t.Errorf("reserved %v within %d bytes, want %v", ids(fits.Transactions), small.Size()+smaller.Size(), want)
+	}
+	if fits.Bytes != small.Size()+smaller.Size() || fits.Fees != small.Fee+smaller.Fee {
+		t.Errorf("reservation of %d bytes paying %v, want %d bytes paying %v",
+			fits.Bytes, fits.Fees, small.Size()+smaller.Size(), small.Fee+smaller.Fee)
+	}
+	m.Release(fits)
+
+	if one := m.Reserve(1, 0); !reflect.DeepEqual(ids(one.Transactions), ids([]Transaction{large})) {
+		t.Errorf("reserved %v with room for one transaction, want the most profitable", ids(one.Transactions))
+	}
+}
@@ -0,0 +1,179 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// emptyMerkleRoot is the root of a block with no transactions
+var emptyMerkleRoot = strings.Repeat("0", 64)
+
+// ErrTransactionNotInBlock is returned when a proof is requested for a transaction the block does not contain
+var ErrTransactionNotInBlock = errors.New("transaction is not in the block")
+
+// ErrMalformedMerkleHash is returned for a transaction ID or proof hash that is not a hex SHA-256 hash
+var ErrMalformedMerkleHash = errors.New("merkle tree hash is not a hex encoded SHA-256 hash")
+
+// Leaves and inner nodes are hashed with different prefixes, so an inner
+// node can never be passed off as a transaction ID in a proof
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// ProofStep is one sibling hash on the path from a leaf to the Merkle root
+type ProofStep struct {
+	Hash     string `json:"hash"`
+	Position string `json:"position"` // "left" or "right" of the running hash
+}
+
+// MerkleProof shows that a transaction is included under a Merkle root
+type MerkleProof struct {
+	TxID  string      `json:"txId"`
+	Index int         `json:"index"`
+	Root  string      `json:"root"`
+	Path  []ProofStep `json:"path"`
+}
+
+// decodeMerkleHash decodes a hex encoded SHA-256 hash
+func decodeMerkleHash(hash string) ([]byte, error) {
+	decoded, err := hex.DecodeString(hash)
+	if err != nil || len(decoded) != sha256.Size {
+		return nil, fmt.Errorf("%w: %q", ErrMalformedMerkleHash, hash)
+	}
+	return decoded, nil
+}
+
+// hashLeaf hashes a transaction ID into a leaf of the tree
+func hashLeaf(txID string) (string, error) {
+	id, err := decodeMerkleHash(txID)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(append([]byte{merkleLeafPrefix}, id...))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// hashPair hashes two hex encoded child hashes into their parent node
+func hashPair(left, right string) (string, error) {
+	l, err := decodeMerkleHash(left)
+	if err != nil {
+		return "", err
+	}
+	r, err := decodeMerkleHash(right)
+	if err != nil {
+		return "", err
+	}
+	data := append(append([]byte{merkleNodePrefix}, l...), r...)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// MerkleTree returns every level of the Merkle tree over the transaction IDs,
+// the leaves hashed from the IDs first and the root last. A level with an odd number of hashes
+// pairs its last hash with itself. It fails if an ID is not a hex SHA-256 hash.
+func MerkleTree(transactions []Transaction) ([][]string, error) {
+	if len(transactions) == 0 {
+		return [][]string{{emptyMerkleRoot}}, nil
+	}
+
+	level := make([]string, len(transactions))
+	for i, tx := range transactions {
+		leaf, err := hashLeaf(tx.ID)
+		if err != nil {
+			return nil, err
+		}
+		level[i] = leaf
+	}
+	levels := [][]string{level}
+
+	for len(level) > 1 {
+		next := make([]string, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			node, err := hashPair(level[i], right)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, node)
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels, nil
+}
+
+// ComputeMerkleRoot returns the Merkle root over the transaction IDs
+func ComputeMerkleRoot(transactions []Transaction) (string, error) {
+	levels, err := MerkleTree(transactions)
+	if err != nil {
+		return "", err
+	}
+	return levels[len(levels)-1][0], nil
+}
+
+// BuildMerkleProof returns the inclusion proof for txID within transactions
+func BuildMerkleProof(transactions []Transaction, txID string) (*MerkleProof, error) {
+	index := -1
+	for i, tx := range transactions {
+		if tx.ID == txID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, ErrTransactionNotInBlock
+	}
+
+	levels, err := MerkleTree(transactions)
+	if err != nil {
+		return nil, err
+	}
+	proof := &MerkleProof{
+		TxID:  txID,
+		Index: index,
+		Root:  levels[len(levels)-1][0],
+		Path:  []ProofStep{},
+	}
+
+	position := index
+	for _, level := range levels[:len(levels)-1] {
+		if position%2 == 0 {
+			sibling := level[position]
+			if position+1 < len(level) {
+				sibling = level[position+1]
+			}
+			proof.Path = append(proof.Path, ProofStep{Hash: sibling, Position: "right"})
+		} else {
+			proof.Path = append(proof.Path, ProofStep{Hash: level[position-1], Position: "left"})
+		}
+		position /= 2
+	}
+	return proof, nil
+}
+
+// Verify recomputes the root from the transaction ID and the proof path. A
+// malformed ID or path hash never verifies.
+func (p *MerkleProof) Verify() bool {
+	hash, err := hashLeaf(p.TxID)
+	for _, step := range p.Path {
+		if err != nil {
+			return false
+		}
+		switch step.Position {
+		case "left":
+			hash, err = hashPair(step.Hash, hash)
+		case "right":
+			hash, err = hashPair(hash, step.Hash)
+		default:
+			return false
+		}
+	}
+	return err == nil && hash == p.Root
+}
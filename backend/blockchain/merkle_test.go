@@ -0,0 +1,109 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// merkleTransactions returns n transactions with distinct well-formed IDs
+func merkleTransactions(n int) []Transaction {
+	transactions := make([]Transaction, n)
+	for i := range transactions {
+		id := sha256.Sum256([]byte(fmt.Sprint(i)))
+		transactions[i].ID = hex.EncodeToString(id[:])
+	}
+	return transactions
+}
+
+// mustHash returns a hash helper that fails t on error
+func mustHash(t *testing.T) func(hash string, err error) string {
+	return func(hash string, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+}
+
+func TestMerkleRoots(t *testing.T) {
+	must := mustHash(t)
+	root, err := ComputeMerkleRoot(nil)
+	if err != nil || root != emptyMerkleRoot {
+		t.Errorf("empty root %q, %v, want %q", root, err, emptyMerkleRoot)
+	}
+
+	// A lone transaction's root is its leaf hash, not its bare ID
+	single := merkleTransactions(1)
+	leaf := must(hashLeaf(single[0].ID))
+	if root := must(ComputeMerkleRoot(single)); root != leaf || root == single[0].ID {
+		t.Errorf("single transaction root %q, want its leaf hash %q", root, leaf)
+	}
+
+	// An odd level pairs its last hash with itself
+	three := merkleTransactions(3)
+	leaves := make([]string, 3)
+	for i, tx := range three {
+		leaves[i] = must(hashLeaf(tx.ID))
+	}
+	want := must(hashPair(must(hashPair(leaves[0], leaves[1])), must(hashPair(leaves[2], leaves[2]))))
+	if root := must(ComputeMerkleRoot(three)); root != want {
+		t.Errorf("three transaction root %q, want %q", root, want)
+	}
+}
+
+func TestMerkleProofsVerifyAtEveryPosition(t *testing.T) {
+	must := mustHash(t)
+	for n := 1; n <= 9; n++ {
+		transactions := merkleTransactions(n)
+		root := must(ComputeMerkleRoot(transactions))
+		for i, tx := range transactions {
+			proof, err := BuildMerkleProof(transactions, tx.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if proof.Index != i || proof.Root != root || !proof.Verify() {
+				t.Errorf("%d transactions: proof %+v for position %d does not verify against %q", n, proof, i, root)
+			}
+
+			// The same path must not prove another transaction
+			forged := *proof
+			forged.TxID = transactions[(i+1)%n].ID
+			if n > 1 && forged.Verify() {
+				t.Errorf("%d transactions: proof for position %d verifies another transaction", n, i)
+			}
+		}
+	}
+}
+
+func TestMerkleProofRejectsInnerNodeAsTransaction(t *testing.T) {
+	transactions := merkleTransactions(4)
+	levels, err := MerkleTree(transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The node over the first two leaves, with the right half as its
+	// sibling, would reproduce the root if leaves and nodes hashed alike
+	forged := MerkleProof{
+		TxID: levels[1][0],
+		Root: levels[2][0],
+		Path: []ProofStep{{Hash: levels[1][1], Position: "right"}},
+	}
+	if forged.Verify() {
+		t.Error("inner node verified as a transaction")
+	}
+}
+
+func TestMalformedTransactionIDIsRejected(t *testing.T) {
+	transactions := merkleTransactions(2)
+	for _, id := range []string{"abc1", "zz", transactions[0].ID[:62]} {
+		transactions[1].ID = id
+		if _, err := ComputeMerkleRoot(transactions); !errors.Is(err, ErrMalformedMerkleHash) {
+			t.Errorf("ID %q gave %v, want %v", id, err, ErrMalformedMerkleHash)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Names of the built-in proof-of-work algorithms, as used in ChainParams
+const (
+	// PoWSHA256 is the original scheme: difficulty counts leading hex zeros
+	// of a SHA-256 hash, so each step is 16 times the work
+	PoWSHA256 = "sha256"
+	// PoWSHA256Bits compares a SHA-256 hash against a 256-bit target carried
+	// in the block's nBits field; difficulty counts leading zero bits
+	PoWSHA256Bits = "sha256-bits"
+	// PoWDoubleSHA256 is PoWSHA256Bits with Bitcoin's SHA-256(SHA-256(header))
+	PoWDoubleSHA256 = "sha256d"
+	// PoWScrypt is PoWSHA256Bits with Litecoin's memory-hard scrypt
+	// (N=1024, r=1, p=1), which needs 128 KiB per hash
+	PoWScrypt = "scrypt"
+)
+
+// ErrUnknownProofOfWork is returned for a proof-of-work name that is not registered
+var ErrUnknownProofOfWork = errors.New("unknown proof-of-work algorithm")
+
+// ProofOfWork is a proof-of-work algorithm: how a block header is hashed and
+// how far below a target the hash must fall at a given difficulty. Every
+// chain picks one at genesis through ChainParams.ProofOfWork.
+type ProofOfWork interface {
+	// Name identifies the algorithm in ChainParams
+	Name() string
+	// Sum hashes a serialized block header into 32 bytes
+	Sum(header []byte) []byte
+	// Target returns the largest hash, read as a big-endian number, that meets difficulty
+	Target(difficulty int) *big.Int
+	// Bits returns the compact nBits encoding of the target blocks at
+	// difficulty carry, or 0 if the target is implied by the difficulty
+	Bits(difficulty int) uint32
+	// DifficultyBounds returns the range retargeting keeps the difficulty in
+	DifficultyBounds() (min, max int)
+	// DefaultDifficulty is a genesis difficulty that mines in about a second
+	DefaultDifficulty() int
+}
+
+// proofsOfWork holds the registered algorithms by name
+var proofsOfWork = map[string]ProofOfWork{
+	PoWSHA256:       hexSHA256{},
+	PoWSHA256Bits:   bitTarget{name: PoWSHA256Bits, sum: sha256Sum, finish: identity, min: 4, max: 24, initial: 16},
+	PoWDoubleSHA256: bitTarget{name: PoWDoubleSHA256, sum: doubleSHA256Sum, finish: sha256Sum, min: 4, max: 24, initial: 16},
+	PoWScrypt:       bitTarget{name: PoWScrypt, sum: scryptSum, min: 1, max: 20, initial: 10},
+}
+
+// ProofOfWorkByName returns the registered algorithm with the given name.
+// An empty name selects PoWSHA256, which chains used before the choice existed.
+func ProofOfWorkByName(name string) (ProofOfWork, error) {
+	if name == "" {
+		name = PoWSHA256
+	}
+	pow, ok := proofsOfWork[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProofOfWork, name)
+	}
+	return pow, nil
+}
+
+// ProofOfWorkNames lists the registered algorithms
+func ProofOfWorkNames() []string {
+	names := make([]string, 0, len(proofsOfWork))
+	for name := range proofsOfWork {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TargetBytes returns target as 32 big-endian bytes, for comparing against
+// hashes with MeetsTarget
+func TargetBytes(target *big.Int) []byte {
+	return target.FillBytes(make([]byte, 32))
+}
+
+// MeetsTarget reports whether a 32-byte hash is at most the 32-byte target
+func MeetsTarget(sum, target []byte) bool {
+	return bytes.Compare(sum, target) <= 0
+}
+
+// WorkForTarget returns the expected number of hashes needed to meet target,
+// which is 2^256 / (target + 1)
+func WorkForTarget(target *big.Int) *big.Int {
+	space := new(big.Int).Lsh(big.NewInt(1), 256)
+	return space.Div(space, new(big.Int).Add(target, big.NewInt(1)))
+}
+
+// hexSHA256 implements PoWSHA256
+type hexSHA256 struct{}
+
+func (hexSHA256) Name() string             { return PoWSHA256 }
+func (hexSHA256) Sum(header []byte) []byte { return sha256Sum(header) }
+func (hexSHA256) Bits(int) uint32          { return 0 }
+func (hexSHA256) DifficultyBounds() (int, int) {
+	return MinDifficulty, MaxDifficulty
+}
+func (hexSHA256) DefaultDifficulty() int { return DefaultDifficulty }
+
+func (hexSHA256) sha256Finisher() func([]byte) []byte { return identity }
+
+// Target is 16^(64-difficulty) - 1: the hash must start with difficulty hex zeros
+func (hexSHA256) Target(difficulty int) *big.Int {
+	return leadingZeroTarget(4 * difficulty)
+}
+
+// bitTarget implements the algorithms whose difficulty counts leading zero
+// bits and whose target travels in nBits form
+type bitTarget struct {
+	name    string
+	sum     func([]byte) []byte
+	finish  func([]byte) []byte // Applied to a SHA-256 of the header to get sum, nil if sum does not start with one
+	min     int
+	max     int
+	initial int
+}
+
+func (p bitTarget) Name() string                 { return p.name }
+func (p bitTarget) Sum(header []byte) []byte     { return p.sum(header) }
+func (p bitTarget) DifficultyBounds() (int, int) { return p.min, p.max }
+func (p bitTarget) DefaultDifficulty() int       { return p.initial }
+
+func (p bitTarget) sha256Finisher() func([]byte) []byte { return p.finish }
+
+// Bits is the compact form of 2^(256-difficulty) - 1
+func (p bitTarget) Bits(difficulty int) uint32 {
+	return BigToCompact(leadingZeroTarget(difficulty))
+}
+
+// Target is what Bits decodes to, so it is exactly what the header commits to
+func (p bitTarget) Target(difficulty int) *big.Int {
+	return CompactToBig(p.Bits(difficulty))
+}
+
+// leadingZeroTarget returns 2^(256-bits) - 1, the largest hash with bits leading zero bits
+func leadingZeroTarget(bits int) *big.Int {
+	target := new(big.Int).Lsh(big.NewInt(1), uint(256-bits))
+	return target.Sub(target, big.NewInt(1))
+}
+
+func identity(digest []byte) []byte {
+	return digest
+}
+
+func sha256Sum(header []byte) []byte {
+	sum := sha256.Sum256(header)
+	return sum[:]
+}
+
+func doubleSHA256Sum(header []byte) []byte {
+	first := sha256.Sum256(header)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+func scryptSum(header []byte) []byte {
+	// The parameters are constants known to be valid, so scrypt cannot fail
+	sum, _ := scrypt.Key(header, header, 1024, 1, 1, 32)
+	return sum
+}
+
+// CompactToBig decodes Bitcoin's compact nBits form: the top byte is a
+// base-256 exponent and the low three bytes are the mantissa. Targets are
+// never negative, so the sign bit is ignored.
+func CompactToBig(compact uint32) *big.Int {
+	mantissa := int64(compact & 0x007fffff)
+	exponent := uint(compact >> 24)
+	if exponent <= 3 {
+		return big.NewInt(mantissa >> (8 * (3 - exponent)))
+	}
+	target := big.NewInt(mantissa)
+	return target.Lsh(target, 8*(exponent-3))
+}
+
+// BigToCompact encodes a non-negative target in compact nBits form, keeping
+// its three most significant bytes
+func BigToCompact(target *big.Int) uint32 {
+	if target.Sign() <= 0 {
+		return 0
+	}
+
+	exponent := uint(len(target.Bytes()))
+	var mantissa uint32
+	if exponent <= 3 {
+		mantissa = uint32(target.Uint64()) << (8 * (3 - exponent))
+	} else {
+		mantissa = uint32(new(big.Int).Rsh(target, 8*(exponent-3)).Uint64())
+	}
+	// The high mantissa bit is the sign, so move a set bit into the exponent
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+	return uint32(exponent<<24) | mantissa
+}
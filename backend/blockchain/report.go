@@ -0,0 +1,235 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Rules a block can violate, as reported by ValidateChain
+const (
+	RuleBrokenLink       = "broken_link"       // Previous hash or index does not follow the block before
+	RuleHashMismatch     = "hash_mismatch"     // Hash does not match the header
+	RuleMalformedHeader  = "malformed_header"  // Header fields cannot be encoded
+	RuleInsufficientWork = "insufficient_work" // Hash misses the target, or the difficulty is off schedule
+	RuleBadSeal          = "bad_seal"          // Proof-of-stake slot, leader or signature is wrong
+	RuleBadTimestamp     = "bad_timestamp"
+	RuleBadMerkleRoot    = "bad_merkle_root" // Transactions were changed after the block was built
+	RuleBadTransaction   = "bad_transaction" // A transaction or the coinbase breaks the spending rules
+	RuleOther            = "other"
+)
+
+// ErrBadTamper is returned for a tamper simulation that names no block or field of the chain
+var ErrBadTamper = errors.New("tamper target does not exist")
+
+// BlockFailure is one block that broke a validation rule
+type BlockFailure struct {
+	Index int    `json:"index"`
+	Hash  string `json:"hash"`
+	Rule  string `json:"rule"`
+	Error string `json:"error"`
+}
+
+// ValidationReport lists every main chain block that fails validation.
+// Each block is checked against the one before it even if that one failed,
+// so a single edit shows up both where it was made and where it breaks the
+// links after it.
+type ValidationReport struct {
+	Valid        bool           `json:"valid"`
+	Length       int            `json:"length"`
+	FirstInvalid int            `json:"firstInvalid"` // Where the chain diverges from a valid one, -1 if it does not
+	Failures     []BlockFailure `json:"failures"`
+}
+
+// Tamper describes an edit to one main chain block for SimulateTamper
+type Tamper struct {
+	Index       int    `json:"index"`
+	Field       string `json:"field"`       // amount, recipient, nonce, timestamp, previousHash or removeTransaction
+	Value       string `json:"value"`       // New value of the field, unused by removeTransaction
+	Transaction int    `json:"transaction"` // Position of the transaction to edit for amount, recipient and removeTransaction
+	Rehash      bool   `json:"rehash"`      // Recompute the transaction ID, Merkle root and hash after the edit, as a forger would
+}
+
+// TamperResult is the outcome of validating a chain with one block edited
+type TamperResult struct {
+	Tamper   Tamper           `json:"tamper"`
+	Original *Block           `json:"original"`
+	Tampered *Block           `json:"tampered"`
+	Report   ValidationReport `json:"report"`
+}
+
+// ValidationReport validates the main chain block by block
+func (bc *Blockchain) ValidationReport() ValidationReport {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return ValidateChain(bc.Blocks, bc.Params)
+}
+
+// SimulateTamper validates a copy of the main chain in which one block is
+// edited. The chain itself is left untouched.
+func (bc *Blockchain) SimulateTamper(tamper Tamper) (TamperResult, error) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if tamper.Index < 0 || tamper.Index >= len(bc.Blocks) {
+		return TamperResult{}, fmt.Errorf("%w: no block %d", ErrBadTamper, tamper.Index)
+	}
+	original := bc.Blocks[tamper.Index]
+	edited, err := tamperBlock(original, tamper, bc.Params)
+	if err != nil {
+		return TamperResult{}, err
+	}
+
+	// Only the edited block is copied; validation does not modify the others
+	sandbox := append([]*Block{}, bc.Blocks...)
+	sandbox[tamper.Index] = edited
+	return TamperResult{
+		Tamper:   tamper,
+		Original: original,
+		Tampered: edited,
+		Report:   ValidateChain(sandbox, bc.Params),
+	}, nil
+}
+
+// tamperBlock returns a copy of block with the edit applied
+func tamperBlock(block *Block, tamper Tamper, params ChainParams) (*Block, error) {
+	edited := *block
+	edited.Transactions = append([]Transaction{}, block.Transactions...)
+	var editedTx *Transaction
+
+	txTarget := func() (*Transaction, error) {
+		if tamper.Transaction < 0 || tamper.Transaction >= len(edited.Transactions) {
+			return nil, fmt.Errorf("%w: block %d has no transaction %d", ErrBadTamper, block.Index, tamper.Transaction)
+		}
+		return &edited.Transactions[tamper.Transaction], nil
+	}
+	badValue := func(err error) error {
+		return fmt.Errorf("%w: invalid %s %q: %v", ErrBadTamper, tamper.Field, tamper.Value, err)
+	}
+
+	switch tamper.Field {
+	case "amount":
+		tx, err := txTarget()
+		if err != nil {
+			return nil, err
+		}
+		amount, err := strconv.ParseFloat(tamper.Value, 64)
+		if err != nil {
+			return nil, badValue(err)
+		}
+		tx.Amount = amount
+		editedTx = tx
+	case "recipient":
+		tx, err := txTarget()
+		if err != nil {
+			return nil, err
+		}
+		tx.Recipient = tamper.Value
+		editedTx = tx
+	case "removeTransaction":
+		if _, err := txTarget(); err != nil {
+			return nil, err
+		}
+		edited.Transactions = append(edited.Transactions[:tamper.Transaction], edited.Transactions[tamper.Transaction+1:]...)
+	case "nonce":
+		nonce, err := strconv.Atoi(tamper.Value)
+		if err != nil {
+			return nil, badValue(err)
+		}
+		edited.Nonce = nonce
+	case "timestamp":
+		timestamp, err := strconv.ParseInt(tamper.Value, 10, 64)
+		if err != nil {
+			return nil, badValue(err)
+		}
+		edited.Timestamp = timestamp
+	case "previousHash":
+		edited.PreviousHash = tamper.Value
+	default:
+		return nil, fmt.Errorf("%w: unknown field %q", ErrBadTamper, tamper.Field)
+	}
+
+	if tamper.Rehash {
+		// Only a well-formed header can be encoded and hashed
+		if err := edited.checkHeader(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadTamper, err)
+		}
+		// A signed transaction's signature still covers its old ID, so only
+		// a coinbase survives the new ID
+		if editedTx != nil {
+			editedTx.ID = editedTx.CalculateHash()
+		}
+		root, err := ComputeMerkleRoot(edited.Transactions)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadTamper, err)
+		}
+		edited.MerkleRoot = root
+		edited.Hash = edited.CalculateHash(params.PoW())
+	}
+	return &edited, nil
+}
+
+// ValidateChain checks blocks as a chain starting at genesis and reports
+// every block that breaks a rule
+func ValidateChain(blocks []*Block, params ChainParams) ValidationReport {
+	report := ValidationReport{Length: len(blocks), FirstInvalid: -1, Failures: []BlockFailure{}}
+	fail := func(block *Block, index int, err error) {
+		report.Failures = append(report.Failures, BlockFailure{
+			Index: index,
+			Hash:  block.Hash,
+			Rule:  ruleFor(err),
+			Error: err.Error(),
+		})
+		if report.FirstInvalid < 0 {
+			report.FirstInvalid = index
+		}
+	}
+
+	if len(blocks) == 0 {
+		report.FirstInvalid = 0
+		report.Failures = append(report.Failures, BlockFailure{Rule: RuleBrokenLink, Error: "chain has no genesis block"})
+		return report
+	}
+	if err := validateGenesis(blocks[0], params); err != nil {
+		fail(blocks[0], 0, err)
+	}
+
+	balances := make(map[string]float64)
+	txIndex := make(map[string]int)
+	applyBlockTo(balances, txIndex, blocks[0])
+	for i := 1; i < len(blocks); i++ {
+		if err := validateBlock(blocks[i], params, blocks[:i], balances, txIndex); err != nil {
+			fail(blocks[i], i, err)
+		}
+		// Apply even a failing block so later blocks see the state they were built on
+		applyBlockTo(balances, txIndex, blocks[i])
+	}
+
+	report.Valid = len(report.Failures) == 0
+	return report
+}
+
+// ruleFor classifies a validation error
+func ruleFor(err error) string {
+	switch {
+	case errors.Is(err, ErrBadPrevHash), errors.Is(err, ErrBadIndex):
+		return RuleBrokenLink
+	case errors.Is(err, ErrBadHash):
+		return RuleHashMismatch
+	case errors.Is(err, ErrBadHeader):
+		return RuleMalformedHeader
+	case errors.Is(err, ErrInsufficientWork), errors.Is(err, ErrBadDifficulty):
+		return RuleInsufficientWork
+	case errors.Is(err, ErrBadSlot), errors.Is(err, ErrNotSlotLeader), errors.Is(err, ErrBadSeal):
+		return RuleBadSeal
+	case errors.Is(err, ErrBadTimestamp):
+		return RuleBadTimestamp
+	case errors.Is(err, ErrBadMerkleRoot):
+		return RuleBadMerkleRoot
+	case errors.Is(err, ErrInvalidTx), errors.Is(err, ErrBadCoinbase), errors.Is(err, ErrBlockTooLarge):
+		return RuleBadTransaction
+	default:
+		return RuleOther
+	}
+}
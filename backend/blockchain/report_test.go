@@ -0,0 +1,119 @@
+package blockchain
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTamperRejectsMalformedHeaderBeforeRehash(t *testing.T) {
+	bc := NewBlockchain()
+	tooLong := strings.Repeat("ab", 35)
+
+	_, err := bc.SimulateTamper(Tamper{Index: 0, Field: "previousHash", Value: tooLong, Rehash: true})
+	if !errors.Is(err, ErrBadTamper) {
+		t.Fatalf("tamper with a %d character previous hash returned %v, want %v", len(tooLong), err, ErrBadTamper)
+	}
+
+	// Without rehashing the edit is validated, not hashed, and reported
+	result, err := bc.SimulateTamper(Tamper{Index: 0, Field: "previousHash", Value: tooLong})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Report.Valid {
+		t.Error("tampered chain reported valid")
+	}
+}
+
+func TestTamperReportsTheBrokenRule(t *testing.T) {
+	// At the default difficulty a rehashed block all but never meets its target
+	params := testParams()
+	params.InitialDifficulty = DefaultDifficulty
+	bc := NewBlockchainWithParams(params)
+	alice := newWallet(t)
+	funded := mineOn(t, bc, bc.GetLatestBlock(), alice.address)
+	paid := mineOn(t, bc, funded, "miner", alice.pay(t, "bob", 0.5, 0.1))
+	// Block 3 comes a minute later, so moving block 2 on by a second only breaks block 2
+	last := newBlockOn(params, paid, "miner")
+	last.Timestamp = paid.Timestamp + 60
+	if err := bc.AddMinedBlock(reseal(params, last)); err != nil {
+		t.Fatal(err)
+	}
+	timestamp := strconv.FormatInt(paid.Timestamp+1, 10)
+
+	// failure is the index and rule of one expected BlockFailure
+	type failure struct {
+		index int
+		rule  string
+	}
+	// A rehashed block 2 no longer meets its target, and block 3 no longer links to it
+	rehashed := []failure{{2, RuleInsufficientWork}, {3, RuleBrokenLink}}
+
+	for _, test := range []struct {
+		name   string
+		tamper Tamper
+		want   []failure
+	}{
+		{"amount", Tamper{Field: "amount", Value: "500"}, []failure{{2, RuleBadTransaction}}},
+		{"amount rehashed", Tamper{Field: "amount", Value: "500", Rehash: true}, rehashed},
+		{"coinbase amount", Tamper{Field: "amount", Transaction: 1, Value: "500"}, []failure{{2, RuleBadTransaction}}},
+		{"recipient", Tamper{Field: "recipient", Value: "mallory"}, []failure{{2, RuleBadTransaction}}},
+		{"recipient rehashed", Tamper{Field: "recipient", Value: "mallory", Rehash: true}, rehashed},
+		{"nonce", Tamper{Field: "nonce", Value: "7"}, []failure{{2, RuleHashMismatch}}},
+		{"nonce rehashed", Tamper{Field: "nonce", Value: "7", Rehash: true}, rehashed},
+		{"timestamp", Tamper{Field: "timestamp", Value: timestamp}, []failure{{2, RuleHashMismatch}}},
+		{"timestamp rehashed", Tamper{Field: "timestamp", Value: timestamp, Rehash: true}, rehashed},
+		{"timestamp before the parent", Tamper{Field: "timestamp", Value: "0"}, []failure{{2, RuleBadTimestamp}}},
+		{"previous hash", Tamper{Field: "previousHash", Value: emptyMerkleRoot}, []failure{{2, RuleBrokenLink}}},
+		{"previous hash rehashed", Tamper{Field: "previousHash", Value: emptyMerkleRoot, Rehash: true},
+			[]failure{{2, RuleBrokenLink}, {3, RuleBrokenLink}}},
+		{"removed transaction", Tamper{Field: "removeTransaction"}, []failure{{2, RuleBadMerkleRoot}}},
+		{"removed transaction rehashed", Tamper{Field: "removeTransaction", Rehash: true}, rehashed},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.tamper.Index = paid.Index
+			result, err := bc.SimulateTamper(test.tamper)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []failure{}
+			for _, f := range result.Report.Failures {
+				got = append(got, failure{f.Index, f.Rule})
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("failures %+v, want %v", result.Report.Failures, test.want)
+			}
+			if result.Report.Valid || result.Report.FirstInvalid != paid.Index {
+				t.Errorf("report valid %v diverging at %d, want invalid from block %d",
+					result.Report.Valid, result.Report.FirstInvalid, paid.Index)
+			}
+			if test.tamper.Rehash == (result.Tampered.Hash == paid.Hash) {
+				t.Errorf("tampered hash %s, original %s: want a new hash only when rehashed", result.Tampered.Hash, paid.Hash)
+			}
+		})
+	}
+
+	if report := bc.ValidationReport(); !report.Valid {
+		t.Errorf("simulated tampering changed the chain: %+v", report.Failures)
+	}
+}
+
+func TestTamperRejectsMissingTargets(t *testing.T) {
+	bc := NewBlockchainWithParams(testParams())
+	mineOn(t, bc, bc.GetLatestBlock(), "miner")
+
+	for _, tamper := range []Tamper{
+		{Index: 2, Field: "nonce", Value: "1"},
+		{Index: -1, Field: "nonce", Value: "1"},
+		{Index: 1, Field: "amount", Transaction: 1, Value: "1"},
+		{Index: 1, Field: "amount", Value: "lots"},
+		{Index: 1, Field: "difficulty", Value: "1"},
+	} {
+		if _, err := bc.SimulateTamper(tamper); !errors.Is(err, ErrBadTamper) {
+			t.Errorf("tamper %+v returned %v, want %v", tamper, err, ErrBadTamper)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package blockchain
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// StakePrefix marks the account holding a validator's stake. Nobody holds
+	// a key for it, so whatever is sent there stays locked.
+	StakePrefix = "stake:"
+	// DefaultSlotDuration is the number of seconds each proof-of-stake slot lasts
+	DefaultSlotDuration = 5
+)
+
+// StakeAccount returns the account whose balance is validator's stake.
+// Sending coins to it stakes them for validator.
+func StakeAccount(validator string) string {
+	return StakePrefix + validator
+}
+
+// Stakes returns the stake of every validator with some in balances
+func Stakes(balances map[string]float64) map[string]float64 {
+	stakes := make(map[string]float64)
+	for account, balance := range balances {
+		if strings.HasPrefix(account, StakePrefix) && balance > 0 {
+			stakes[strings.TrimPrefix(account, StakePrefix)] = balance
+		}
+	}
+	return stakes
+}
+
+// SlotLeader picks the validator allowed to propose the block for slot on
+// top of parentHash, with a chance proportional to its stake. Every node
+// derives the same leader from the parent hash and slot, so anyone can verify
+// the choice, though a leader could grind its block to steer the next pick.
+// It returns "" while nobody has staked, when any validator may propose.
+func SlotLeader(stakes map[string]float64, parentHash string, slot int) string {
+	validators := make([]string, 0, len(stakes))
+	total := 0.0
+	for validator, stake := range stakes {
+		validators = append(validators, validator)
+		total += stake
+	}
+	if len(validators) == 0 {
+		return ""
+	}
+	sort.Strings(validators)
+
+	seed := sha256.Sum256([]byte(parentHash + ":" + strconv.Itoa(slot)))
+	// The top 53 bits of the seed give a uniform float64 in [0, 1)
+	point := float64(binary.BigEndian.Uint64(seed[:8])>>11) / (1 << 53) * total
+	for _, validator := range validators {
+		point -= stakes[validator]
+		if point < 0 {
+			return validator
+		}
+	}
+	return validators[len(validators)-1]
+}
+
+// SlotAt returns the proof-of-stake slot that timestamp falls in
+func (p ChainParams) SlotAt(timestamp int64) int {
+	duration := p.SlotDuration
+	if duration <= 0 {
+		duration = DefaultSlotDuration
+	}
+	return int((timestamp - GenesisTimestamp) / duration)
+}
+
+// NewProofOfStakeParams returns the default parameters with proof-of-stake
+// consensus. Block hashes only need to be SHA-256, so difficulty stays 0.
+func NewProofOfStakeParams() ChainParams {
+	params := DefaultChainParams()
+	params.Consensus = ConsensusPoS
+	params.InitialDifficulty = 0
+	params.SlotDuration = DefaultSlotDuration
+	return params
+}
+
+// Sign seals a proof-of-stake block: it attaches the validator's public key,
+// hashes the header and signs the hash with privateKey
+func (b *Block) Sign(privateKey *rsa.PrivateKey) error {
+	publicKey, err := EncodePublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return err
+	}
+	b.PublicKey = publicKey
+	b.Hash = b.CalculateHash(proofsOfWork[PoWSHA256])
+
+	digest, err := hex.DecodeString(b.Hash)
+	if err != nil {
+		return err
+	}
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest)
+	if err != nil {
+		return fmt.Errorf("sign block: %w", err)
+	}
+	b.Signature = hex.EncodeToString(signature)
+	return nil
+}
+
+// VerifySignature checks that the block is signed by the key whose address
+// is its validator
+func (b *Block) VerifySignature() error {
+	if b.PublicKey == "" || b.Signature == "" {
+		return fmt.Errorf("%w: block is not signed", ErrBadSeal)
+	}
+	publicKey, err := DecodePublicKey(b.PublicKey)
+	if err != nil || AddressFromPublicKey(publicKey) != b.Validator {
+		return fmt.Errorf("%w: public key does not belong to %s", ErrBadSeal, b.Validator)
+	}
+	digest, err := hex.DecodeString(b.Hash)
+	if err != nil {
+		return ErrBadHash
+	}
+	signature, err := hex.DecodeString(b.Signature)
+	if err != nil || rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest, signature) != nil {
+		return ErrBadSeal
+	}
+	return nil
+}
+
+// proofOfStake seals blocks with the signature of the slot leader; fork
+// choice follows the longest chain
+type proofOfStake struct {
+	params ChainParams
+}
+
+func (e proofOfStake) Name() string { return ConsensusPoS }
+
+func (e proofOfStake) Prepare(block *Block, branch []*Block, producer string) {
+	block.Difficulty = 0
+	block.Bits = 0
+	block.Slot = e.params.SlotAt(block.Timestamp)
+	block.Validator = producer
+}
+
+func (e proofOfStake) VerifySeal(block *Block, branch []*Block, balances map[string]float64) error {
+	parent := branch[len(branch)-1]
+
+	if block.Difficulty != 0 || block.Bits != 0 {
+		return fmt.Errorf("%w: proof-of-stake blocks have no difficulty", ErrBadDifficulty)
+	}
+	if expected := e.params.SlotAt(block.Timestamp); block.Slot != expected {
+		return fmt.Errorf("%w: timestamp %d is in slot %d, not %d", ErrBadSlot, block.Timestamp, expected, block.Slot)
+	}
+	if block.Slot <= parent.Slot {
+		return fmt.Errorf("%w: slot %d is not after its parent's %d", ErrBadSlot, block.Slot, parent.Slot)
+	}
+	if leader := SlotLeader(Stakes(balances), parent.Hash, block.Slot); leader != "" && block.Validator != leader {
+		return fmt.Errorf("%w: slot %d belongs to %s, not %s", ErrNotSlotLeader, block.Slot, leader, block.Validator)
+	}
+	if block.Validator == "" {
+		return fmt.Errorf("%w: block has no validator", ErrBadSeal)
+	}
+	for _, tx := range block.Transactions {
+		if tx.Sender == CoinbaseSender && tx.Recipient != block.Validator {
+			return fmt.Errorf("%w: coinbase must pay the validator", ErrBadCoinbase)
+		}
+	}
+	if block.Hash != block.CalculateHash(proofsOfWork[PoWSHA256]) {
+		return ErrBadHash
+	}
+	return block.VerifySignature()
+}
+
+// Weight is one per block, so the longest chain wins
+func (e proofOfStake) Weight(block *Block) *big.Int {
+	return big.NewInt(1)
+}
+
+// CurrentSlot returns the proof-of-stake slot the local clock is in
+func (bc *Blockchain) CurrentSlot() int {
+	return bc.Params.SlotAt(time.Now().Unix())
+}
+
+// Stakes returns the stake of every validator on the main chain
+func (bc *Blockchain) Stakes() map[string]float64 {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return Stakes(bc.balances)
+}
+
+// SlotLeader returns the validator allowed to propose the block for slot on
+// the current tip, or "" while nobody has staked, along with the tip's hash
+func (bc *Blockchain) SlotLeader(slot int) (string, string) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	tip := bc.Blocks[len(bc.Blocks)-1]
+	return SlotLeader(Stakes(bc.balances), tip.Hash, slot), tip.Hash
+}
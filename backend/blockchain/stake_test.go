@@ -0,0 +1,140 @@
+package blockchain
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSlotLeaderIsDeterministicAndWeightedByStake(t *testing.T) {
+	if leader := SlotLeader(map[string]float64{}, "parent", 1); leader != "" {
+		t.Errorf("leader %q with nobody staked, want none", leader)
+	}
+
+	stakes := map[string]float64{"alice": 1, "bob": 3}
+	const slots = 4000
+	chosen := make(map[string]int)
+	for slot := 0; slot < slots; slot++ {
+		leader := SlotLeader(stakes, "parent", slot)
+		// Map iteration order must not change the pick
+		if again := SlotLeader(map[string]float64{"bob": 3, "alice": 1}, "parent", slot); again != leader {
+			t.Fatalf("slot %d led by %s and then by %s", slot, leader, again)
+		}
+		chosen[leader]++
+	}
+	if share := float64(chosen["bob"]) / slots; math.Abs(share-0.75) > 0.05 {
+		t.Errorf("bob led %.2f of the slots with three quarters of the stake", share)
+	}
+
+	// Another parent reshuffles the leaders
+	differ := 0
+	for slot := 0; slot < 100; slot++ {
+		if SlotLeader(stakes, "parent", slot) != SlotLeader(stakes, "other parent", slot) {
+			differ++
+		}
+	}
+	if differ == 0 {
+		t.Error("leaders do not depend on the parent hash")
+	}
+}
+
+// proposeOn builds a proof-of-stake block on parent for the slot timestamp
+// falls in, paying its coinbase to and signed by proposer
+func proposeOn(t *testing.T, params ChainParams, parent *Block, proposer wallet, timestamp int64, transactions ...Transaction) *Block {
+	t.Helper()
+	fees := 0.0
+	for _, tx := range transactions {
+		fees += tx.Fee
+	}
+	coinbase := NewCoinbaseTransaction(proposer.address, parent.Index+1, fees)
+	block := NewBlock(parent.Index+1, parent.Hash, append(append([]Transaction{}, transactions...), coinbase), 0)
+	block.Timestamp = timestamp
+	params.Engine().Prepare(block, nil, proposer.address)
+	if err := block.Sign(proposer.key); err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+func TestProofOfStakeSealRules(t *testing.T) {
+	params := NewProofOfStakeParams()
+	bc := NewBlockchainWithParams(params)
+	alice, bob := newWallet(t), newWallet(t)
+	now := time.Now().Unix()
+	slot := func(n int64) int64 { return now + n*params.SlotDuration }
+
+	// While nobody has staked anyone may propose
+	funded := proposeOn(t, params, bc.GetLatestBlock(), alice, slot(0))
+	if err := bc.AddMinedBlock(funded); err != nil {
+		t.Fatal(err)
+	}
+	staked := proposeOn(t, params, funded, bob, slot(1), alice.pay(t, StakeAccount(alice.address), 0.5, 0))
+	if err := bc.AddMinedBlock(staked); err != nil {
+		t.Fatal(err)
+	}
+
+	// Now alice holds all the stake and leads every slot
+	if leader, tip := bc.SlotLeader(params.SlotAt(slot(2))); leader != alice.address || tip != staked.Hash {
+		t.Fatalf("slot led by %q on %s, want alice on %s", leader, tip, staked.Hash)
+	}
+
+	for _, test := range []struct {
+		name  string
+		block func() *Block
+		want  error
+	}{
+		{"non-leader", func() *Block {
+			return proposeOn(t, params, staked, bob, slot(2))
+		}, ErrNotSlotLeader},
+		{"bad signature", func() *Block {
+			block := proposeOn(t, params, staked, alice, slot(2))
+			signature := []byte(block.Signature)
+			signature[len(signature)-1] ^= 1
+			block.Signature = string(signature)
+			return block
+		}, ErrBadSeal},
+		{"signed with another key", func() *Block {
+			block := proposeOn(t, params, staked, alice, slot(2))
+			if err := block.Sign(bob.key); err != nil {
+				t.Fatal(err)
+			}
+			return block
+		}, ErrBadSeal},
+		{"unsigned", func() *Block {
+			block := proposeOn(t, params, staked, alice, slot(2))
+			block.Signature = ""
+			return block
+		}, ErrBadSeal},
+		{"coinbase paying someone else", func() *Block {
+			block := proposeOn(t, params, staked, alice, slot(2))
+			block.Transactions[0] = NewCoinbaseTransaction(bob.address, block.Index, 0)
+			block.MerkleRoot, _ = ComputeMerkleRoot(block.Transactions)
+			if err := block.Sign(alice.key); err != nil {
+				t.Fatal(err)
+			}
+			return block
+		}, ErrBadCoinbase},
+		{"slot of the parent", func() *Block {
+			return proposeOn(t, params, staked, alice, slot(1))
+		}, ErrBadSlot},
+		{"slot does not match the timestamp", func() *Block {
+			block := proposeOn(t, params, staked, alice, slot(2))
+			block.Slot++
+			if err := block.Sign(alice.key); err != nil {
+				t.Fatal(err)
+			}
+			return block
+		}, ErrBadSlot},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if err := bc.ValidateBlock(test.block()); !errors.Is(err, test.want) {
+				t.Errorf("validated with %v, want %v", err, test.want)
+			}
+		})
+	}
+
+	if err := bc.AddMinedBlock(proposeOn(t, params, staked, alice, slot(2))); err != nil {
+		t.Errorf("block from the slot leader rejected: %v", err)
+	}
+}
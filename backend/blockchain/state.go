@@ -0,0 +1,95 @@
+package blockchain
+
+import "time"
+
+const (
+	// CoinbaseSender is the sender of mining reward transactions
+	CoinbaseSender = "system"
+	// MiningReward is the amount paid to the miner of each block
+	MiningReward = 1.0
+)
+
+// NewCoinbaseTransaction creates the transaction paying a miner the block
+// reward plus the fees of the block at height
+func NewCoinbaseTransaction(miner string, height int, fees float64) Transaction {
+	tx := Transaction{
+		Sender:    CoinbaseSender,
+		Recipient: miner,
+		Amount:    MiningReward + fees,
+		Timestamp: time.Now().Unix(),
+		Height:    height,
+	}
+	tx.ID = tx.CalculateHash()
+	return tx
+}
+
+// applyBlock credits and debits every transaction in block to the balance
+// and transaction indexes. The caller must hold the write lock.
+func (bc *Blockchain) applyBlock(block *Block) {
+	applyBlockTo(bc.balances, bc.txIndex, block)
+	bc.hashIndex[block.Hash] = block.Index
+}
+
+// applyBlockTo updates balances and txIndex with the transactions in block
+func applyBlockTo(balances map[string]float64, txIndex map[string]int, block *Block) {
+	for _, tx := range block.Transactions {
+		if tx.Sender != CoinbaseSender {
+			balances[tx.Sender] -= tx.Cost()
+		}
+		balances[tx.Recipient] += tx.Amount
+		txIndex[tx.ID] = block.Index
+	}
+}
+
+// rebuildState recomputes balances and the transaction index from the chain.
+// The caller must hold the write lock.
+func (bc *Blockchain) rebuildState() {
+	bc.balances = make(map[string]float64)
+	bc.txIndex = make(map[string]int)
+	bc.hashIndex = make(map[string]int)
+	for _, block := range bc.Blocks {
+		bc.applyBlock(block)
+	}
+}
+
+// Balance returns the confirmed balance of address
+func (bc *Blockchain) Balance(address string) float64 {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return bc.balances[address]
+}
+
+// SpendableBalance returns the confirmed balance of address minus what it
+// is already spending in pending transactions
+func (bc *Blockchain) SpendableBalance(address string) float64 {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return bc.spendable(address)
+}
+
+// spendable is SpendableBalance for callers already holding the lock
+func (bc *Blockchain) spendable(address string) float64 {
+	return bc.balances[address] - bc.mempool.SpentBy(address)
+}
+
+// validateTransaction checks a new transaction against the chain state and
+// the pending pool. The caller must hold the lock.
+func (bc *Blockchain) validateTransaction(tx Transaction) error {
+	if err := checkTransaction(tx); err != nil {
+		return err
+	}
+
+	if _, ok := bc.txIndex[tx.ID]; ok {
+		return ErrDuplicateTransaction
+	}
+	if bc.mempool.Contains(tx.ID) {
+		return ErrDuplicateTransaction
+	}
+
+	if bc.spendable(tx.Sender) < tx.Cost() {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
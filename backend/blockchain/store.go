@@ -0,0 +1,59 @@
+package blockchain
+
+import "blockchain-visualizer/locks"
+
+// Store persists blocks and the pending transaction pool so a chain can be
+// recovered after a restart
+type Store interface {
+	// AppendBlock durably records a block that has been accepted
+	AppendBlock(block *Block) error
+	// LoadBlocks returns every recorded block in the order it was appended
+	LoadBlocks() ([]*Block, error)
+	// SaveMempool replaces the recorded pending transactions
+	SaveMempool(transactions []Transaction) error
+	// LoadMempool returns the last saved pending transactions
+	LoadMempool() ([]Transaction, error)
+	Close() error
+}
+
+// MemoryStore keeps everything in memory and is lost on restart
+type MemoryStore struct {
+	blocks  []*Block
+	mempool []Transaction
+	mutex   *locks.Mutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{mutex: locks.NewMutex("memory store")}
+}
+
+func (s *MemoryStore) AppendBlock(block *Block) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.blocks = append(s.blocks, block)
+	return nil
+}
+
+func (s *MemoryStore) LoadBlocks() ([]*Block, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*Block{}, s.blocks...), nil
+}
+
+func (s *MemoryStore) SaveMempool(transactions []Transaction) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.mempool = append([]Transaction{}, transactions...)
+	return nil
+}
+
+func (s *MemoryStore) LoadMempool() ([]Transaction, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Transaction{}, s.mempool...), nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,58 @@
+package blockchain
+
+import "context"
+
+// BlockTemplate is an unmined block on the current tip, filled with the most
+// profitable pending transactions and a coinbase paying their fees to the
+// miner. Its transactions stay reserved until ReleaseTemplate is called.
+type BlockTemplate struct {
+	Block       *Block
+	PoW         ProofOfWork // The chain's proof of work, which the block must be mined with
+	Fees        float64
+	Bytes       int
+	Reservation *Reservation
+	Stale       <-chan struct{} // Closed once the tip moves and the block can no longer extend it
+}
+
+// NewBlockTemplate builds a block template paying minerAddress. The caller
+// must hand it back with ReleaseTemplate whether or not the block is mined;
+// transactions that made it onto the main chain have already left the
+// mempool by then, and the rest become available to the next template.
+func (bc *Blockchain) NewBlockTemplate(minerAddress string) *BlockTemplate {
+	template, _ := bc.NewBlockTemplateContext(context.Background(), minerAddress)
+	return template
+}
+
+// NewBlockTemplateContext builds a block template like NewBlockTemplate,
+// but gives up and returns ctx's error if ctx is done while it waits for
+// the chain
+func (bc *Blockchain) NewBlockTemplateContext(ctx context.Context, minerAddress string) (*BlockTemplate, error) {
+	if err := bc.mutex.LockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer bc.mutex.Unlock()
+
+	tip := bc.Blocks[len(bc.Blocks)-1]
+	reservation := bc.mempool.Reserve(bc.Params.MaxBlockTransactions, bc.Params.MaxBlockBytes)
+	coinbase := NewCoinbaseTransaction(minerAddress, tip.Index+1, reservation.Fees)
+	transactions := append(append([]Transaction{}, reservation.Transactions...), coinbase)
+	block := NewBlock(tip.Index+1, tip.Hash, transactions, 0)
+	bc.Params.Engine().Prepare(block, bc.Blocks, minerAddress)
+
+	return &BlockTemplate{
+		Block:       block,
+		PoW:         bc.Params.PoW(),
+		Fees:        reservation.Fees,
+		Bytes:       reservation.Bytes,
+		Reservation: reservation,
+		Stale:       bc.tipChanged,
+	}, nil
+}
+
+// ReleaseTemplate returns a template's unmined transactions to the pool
+func (bc *Blockchain) ReleaseTemplate(template *BlockTemplate) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	bc.mempool.Release(template.Reservation)
+}
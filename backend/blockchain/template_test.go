@@ -0,0 +1,42 @@
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBlockTemplatePaysFeesOfTheMostProfitableTransactions(t *testing.T) {
+	params := testParams()
+	params.MaxBlockTransactions = 2
+	bc := NewBlockchainWithParams(params)
+	alice := newWallet(t)
+	mineOn(t, bc, bc.GetLatestBlock(), alice.address)
+
+	cheap := alice.pay(t, "bob", 0.1, 0.1)
+	best := alice.pay(t, "bob", 0.1, 0.3)
+	good := alice.pay(t, "bob", 0.1, 0.2)
+	for _, tx := range []Transaction{cheap, best, good} {
+		if err := bc.AddTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	template := bc.NewBlockTemplate("miner")
+	defer bc.ReleaseTemplate(template)
+	transactions := template.Block.Transactions
+	if got, want := ids(transactions[:len(transactions)-1]), ids([]Transaction{best, good}); !reflect.DeepEqual(got, want) {
+		t.Errorf("template holds %v, want the two best paying %v", got, want)
+	}
+	coinbase := transactions[len(transactions)-1]
+	if fees := best.Fee + good.Fee; template.Fees != fees || coinbase.Recipient != "miner" || coinbase.Amount != MiningReward+fees {
+		t.Errorf("coinbase pays %v to %s with fees %v, want %v to miner", coinbase.Amount, coinbase.Recipient, template.Fees, MiningReward+fees)
+	}
+
+	template.Block.MineBlock(template.PoW)
+	if err := bc.AddMinedBlock(template.Block); err != nil {
+		t.Fatalf("mined template rejected: %v", err)
+	}
+	if pending := bc.GetPendingTransactions(); !reflect.DeepEqual(ids(pending), ids([]Transaction{cheap})) {
+		t.Errorf("pending %v after mining, want the transaction left out", ids(pending))
+	}
+}
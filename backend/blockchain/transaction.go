@@ -1,38 +1,196 @@
 package blockchain
 
 import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 )
 
+// transactionEncodingVersion is the first byte of the encoding the ID hashes
+const transactionEncodingVersion = 1
+
+// Transaction moves Amount from Sender to Recipient and pays Fee to the miner
+// that includes it. Every transaction except the coinbase carries the
+// sender's public key and a signature over its ID, and Sender must be the
+// address derived from that key.
 type Transaction struct {
 	ID        string
 	Sender    string
 	Recipient string
 	Amount    float64
+	Fee       float64
 	Timestamp int64
+	Height    int    // Block index, set only on coinbase transactions so every reward has a distinct ID
+	PublicKey string // Hex encoded PKIX public key of the sender
+	Signature string // Hex encoded PKCS#1 v1.5 signature over the ID
 }
 
 func NewTransaction(sender, recipient string, amount float64) Transaction {
+	return NewTransactionWithFee(sender, recipient, amount, 0)
+}
+
+// NewTransactionWithFee creates a transaction that offers fee to the miner
+func NewTransactionWithFee(sender, recipient string, amount, fee float64) Transaction {
 	tx := Transaction{
 		Sender:    sender,
 		Recipient: recipient,
 		Amount:    amount,
+		Fee:       fee,
 		Timestamp: time.Now().Unix(),
 	}
 	tx.ID = tx.CalculateHash()
 	return tx
 }
 
+// CalculateHash hashes the encoding built by Encode, which is the ID the
+// signature covers
 func (tx *Transaction) CalculateHash() string {
-	record := fmt.Sprintf("%s%s%f%d", tx.Sender, tx.Recipient, tx.Amount, tx.Timestamp)
-	hash := sha256.Sum256([]byte(record))
+	hash := sha256.Sum256(tx.Encode())
 	return hex.EncodeToString(hash[:])
 }
 
+// Encode serializes every field the ID commits to. Strings are prefixed
+// with their length and amounts are written as their exact IEEE 754 bits,
+// so no two different transactions share an encoding. Integers are
+// big-endian.
+func (tx *Transaction) Encode() []byte {
+	data := []byte{transactionEncodingVersion}
+	data = appendString(data, tx.Sender)
+	data = appendString(data, tx.Recipient)
+	data = appendUint64(data, math.Float64bits(tx.Amount))
+	data = appendUint64(data, math.Float64bits(tx.Fee))
+	data = appendUint64(data, uint64(tx.Timestamp))
+	data = appendUint64(data, uint64(tx.Height))
+	return appendString(data, tx.PublicKey)
+}
+
+// appendUint64 appends v big-endian
+func appendUint64(data []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(data, buf[:]...)
+}
+
+// appendString appends s prefixed with its length as a big-endian uint32
+func appendString(data []byte, s string) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(len(s)))
+	return append(append(data, buf[:]...), s...)
+}
+
+// Cost returns what the sender gives up: the amount plus the fee
+func (tx *Transaction) Cost() float64 {
+	return tx.Amount + tx.Fee
+}
+
+// Size returns the number of bytes the transaction takes up in a block
+func (tx *Transaction) Size() int {
+	data, _ := json.Marshal(tx)
+	return len(data)
+}
+
+// FeeRate returns the fee offered per byte, which orders the mempool
+func (tx *Transaction) FeeRate() float64 {
+	return tx.Fee / float64(tx.Size())
+}
+
+// Sign attaches the public key and a signature made with privateKey, and
+// recomputes the ID to cover the key
+func (tx *Transaction) Sign(privateKey *rsa.PrivateKey) error {
+	publicKey, err := EncodePublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return err
+	}
+	tx.PublicKey = publicKey
+	tx.ID = tx.CalculateHash()
+
+	digest, err := hex.DecodeString(tx.ID)
+	if err != nil {
+		return err
+	}
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest)
+	if err != nil {
+		return fmt.Errorf("sign transaction: %w", err)
+	}
+	tx.Signature = hex.EncodeToString(signature)
+	return nil
+}
+
+// VerifySignature checks that the transaction is signed by the key whose
+// address is the sender and that none of its fields were altered
+func (tx *Transaction) VerifySignature() error {
+	if tx.PublicKey == "" || tx.Signature == "" {
+		return ErrMissingSignature
+	}
+	if tx.ID != tx.CalculateHash() {
+		return ErrInvalidSignature
+	}
+
+	publicKey, err := DecodePublicKey(tx.PublicKey)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if AddressFromPublicKey(publicKey) != tx.Sender {
+		return ErrInvalidSignature
+	}
+
+	digest, _ := hex.DecodeString(tx.ID)
+	signature, err := hex.DecodeString(tx.Signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest, signature); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
 func (tx *Transaction) ToString() string {
 	return fmt.Sprintf("Transaction{ID: %s, Sender: %s, Recipient: %s, Amount: %.2f, Timestamp: %d}",
 		tx.ID, tx.Sender, tx.Recipient, tx.Amount, tx.Timestamp)
 }
+
+// EncodePublicKey returns the hex encoded PKIX form of publicKey
+func EncodePublicKey(publicKey *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(der), nil
+}
+
+// DecodePublicKey parses a key produced by EncodePublicKey
+func DecodePublicKey(encoded string) (*rsa.PublicKey, error) {
+	der, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not an RSA key")
+	}
+	return publicKey, nil
+}
+
+// AddressFromPublicKey derives an address as the first 20 bytes of the
+// SHA-256 of the PKIX encoded key, in hex
+func AddressFromPublicKey(publicKey *rsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:20])
+}
@@ -0,0 +1,48 @@
+package blockchain
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+)
+
+func TestAlteredTransactionFailsVerification(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := NewTransactionWithFee(AddressFromPublicKey(&key.PublicKey), "abc1", 5, 0.5)
+	if err := signed.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := signed.VerifySignature(); err != nil {
+		t.Fatalf("signed transaction fails verification: %v", err)
+	}
+
+	for _, test := range []struct {
+		name  string
+		alter func(tx *Transaction)
+	}{
+		{"sender", func(tx *Transaction) { tx.Sender = "91829a68de5060ab7281baeb154a55829a679f0f" }},
+		{"recipient", func(tx *Transaction) { tx.Recipient = "abc2" }},
+		{"amount", func(tx *Transaction) { tx.Amount = 5.0000004 }},
+		{"fee", func(tx *Transaction) { tx.Fee = 0.5000001 }},
+		{"timestamp", func(tx *Transaction) { tx.Timestamp++ }},
+		{"height", func(tx *Transaction) { tx.Height = 1 }},
+		{"public key", func(tx *Transaction) { tx.PublicKey = tx.PublicKey[:len(tx.PublicKey)-2] + "00" }},
+		{"signature", func(tx *Transaction) { tx.Signature = tx.Signature[:len(tx.Signature)-2] + "00" }},
+		{"ID", func(tx *Transaction) { tx.ID = emptyMerkleRoot }},
+		// Moving a digit from the recipient into the amount once produced
+		// the same ID when fields were concatenated
+		{"recipient into amount", func(tx *Transaction) { tx.Recipient, tx.Amount = "abc", 15 }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tx := signed
+			test.alter(&tx)
+			if err := tx.VerifySignature(); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("altered transaction verified with %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package blockchain
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxFutureBlockTime is how many seconds ahead of the local clock a block timestamp may be
+const MaxFutureBlockTime = 2 * 60
+
+// ValidateBlock runs the full validation pipeline against the block's parent
+// without adding it to the chain. Every error wraps one of the ErrBad*,
+// ErrInsufficientWork, ErrNotSlotLeader, ErrInvalidTx or ErrKnownBlock sentinels.
+func (bc *Blockchain) ValidateBlock(block *Block) error {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if _, ok := bc.tree[block.Hash]; ok {
+		return ErrKnownBlock
+	}
+	parent, ok := bc.tree[block.PreviousHash]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrBadPrevHash, block.PreviousHash)
+	}
+	return bc.validateAgainstParent(block, parent)
+}
+
+// validateAgainstParent validates block as a child of parent, using the
+// cached chain state when parent is the tip and rebuilding the state of the
+// parent's branch otherwise. The caller must hold the lock.
+func (bc *Blockchain) validateAgainstParent(block *Block, parent *Block) error {
+	if parent.Hash == bc.Blocks[len(bc.Blocks)-1].Hash {
+		return validateBlock(block, bc.Params, bc.Blocks, bc.balances, bc.txIndex)
+	}
+
+	branch := bc.branch(parent.Hash)
+	balances := make(map[string]float64)
+	txIndex := make(map[string]int)
+	for _, b := range branch {
+		applyBlockTo(balances, txIndex, b)
+	}
+	return validateBlock(block, bc.Params, branch, balances, txIndex)
+}
+
+// validateBlock checks block as the next block after branch, whose last
+// block is the parent. balances and txIndex are the state after branch and
+// are not modified.
+func validateBlock(block *Block, params ChainParams, branch []*Block, balances map[string]float64, txIndex map[string]int) error {
+	parent := branch[len(branch)-1]
+
+	if err := block.checkHeader(); err != nil {
+		return err
+	}
+	if block.Version < parent.Version {
+		return fmt.Errorf("%w: version %d follows version %d", ErrBadHeader, block.Version, parent.Version)
+	}
+	if block.PreviousHash != parent.Hash {
+		return fmt.Errorf("%w: expected %s, got %s", ErrBadPrevHash, parent.Hash, block.PreviousHash)
+	}
+	if block.Index != parent.Index+1 {
+		return fmt.Errorf("%w: expected %d, got %d", ErrBadIndex, parent.Index+1, block.Index)
+	}
+	if block.Timestamp < parent.Timestamp {
+		return fmt.Errorf("%w: %d is before its parent's %d", ErrBadTimestamp, block.Timestamp, parent.Timestamp)
+	}
+	if block.Timestamp > time.Now().Unix()+MaxFutureBlockTime {
+		return fmt.Errorf("%w: %d is too far in the future", ErrBadTimestamp, block.Timestamp)
+	}
+	if err := params.Engine().VerifySeal(block, branch, balances); err != nil {
+		return err
+	}
+	if root, err := ComputeMerkleRoot(block.Transactions); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadMerkleRoot, err)
+	} else if block.MerkleRoot != root {
+		return ErrBadMerkleRoot
+	}
+	if err := params.checkBlockSize(block); err != nil {
+		return err
+	}
+	return validateBlockTransactions(block, balances, txIndex)
+}
+
+// checkBlockSize enforces the transaction count and byte limits, which
+// cover every transaction except the coinbase
+func (p ChainParams) checkBlockSize(block *Block) error {
+	count, bytes := 0, 0
+	for _, tx := range block.Transactions {
+		if tx.Sender != CoinbaseSender {
+			count++
+			bytes += tx.Size()
+		}
+	}
+	if p.MaxBlockTransactions > 0 && count > p.MaxBlockTransactions {
+		return fmt.Errorf("%w: %d transactions, limit is %d", ErrBlockTooLarge, count, p.MaxBlockTransactions)
+	}
+	if p.MaxBlockBytes > 0 && bytes > p.MaxBlockBytes {
+		return fmt.Errorf("%w: %d bytes, limit is %d", ErrBlockTooLarge, bytes, p.MaxBlockBytes)
+	}
+	return nil
+}
+
+// validateBlockTransactions checks that the block has a single coinbase for
+// its height claiming no more than the reward plus the block's fees, and that
+// every other transaction is well formed, signed, new, and spends no more
+// than its sender had before the block
+func validateBlockTransactions(block *Block, balances map[string]float64, txIndex map[string]int) error {
+	var coinbases []Transaction
+	fees := 0.0
+	seen := make(map[string]bool)
+	spent := make(map[string]float64)
+
+	for _, tx := range block.Transactions {
+		if _, confirmed := txIndex[tx.ID]; confirmed || seen[tx.ID] {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidTx, tx.ID, ErrDuplicateTransaction)
+		}
+		seen[tx.ID] = true
+
+		if tx.Sender == CoinbaseSender {
+			coinbases = append(coinbases, tx)
+			continue
+		}
+
+		if err := checkTransaction(tx); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidTx, tx.ID, err)
+		}
+		spent[tx.Sender] += tx.Cost()
+		if balances[tx.Sender] < spent[tx.Sender] {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidTx, tx.ID, ErrInsufficientFunds)
+		}
+		fees += tx.Fee
+	}
+
+	if len(coinbases) != 1 {
+		return ErrBadCoinbase
+	}
+	coinbase := coinbases[0]
+	if coinbase.Amount <= 0 || coinbase.Amount > MiningReward+fees || coinbase.Height != block.Index ||
+		coinbase.Recipient == "" || coinbase.ID != coinbase.CalculateHash() {
+		return ErrBadCoinbase
+	}
+	return nil
+}
+
+// checkTransaction runs the checks that need no chain state
+func checkTransaction(tx Transaction) error {
+	if tx.Sender == "" || tx.Recipient == "" {
+		return ErrInvalidAddress
+	}
+	if tx.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	if tx.Fee < 0 {
+		return ErrInvalidFee
+	}
+	if tx.Sender == CoinbaseSender {
+		return ErrCoinbaseNotAllowed
+	}
+	return tx.VerifySignature()
+}
+
+// validateGenesis checks the block that every chain starts from. It is
+// hashed with the chain's proof of work under either consensus engine, so a
+// genesis mined with another proof of work fails the hash check.
+func validateGenesis(genesis *Block, params ChainParams) error {
+	if genesis.Index != 0 || genesis.PreviousHash != "" {
+		return fmt.Errorf("%w: genesis must be block 0 with no parent", ErrBadIndex)
+	}
+	if err := genesis.checkHeader(); err != nil {
+		return err
+	}
+	pow := params.PoW()
+	if genesis.Bits != pow.Bits(genesis.Difficulty) {
+		return fmt.Errorf("%w: bits %08x do not encode the target for difficulty %d", ErrBadDifficulty, genesis.Bits, genesis.Difficulty)
+	}
+	if genesis.Hash != genesis.CalculateHash(pow) {
+		return fmt.Errorf("%w: not mined with %s proof of work", ErrBadHash, pow.Name())
+	}
+	if !genesis.IsValidHash(pow) {
+		return ErrInsufficientWork
+	}
+	if root, err := ComputeMerkleRoot(genesis.Transactions); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadMerkleRoot, err)
+	} else if genesis.MerkleRoot != root {
+		return ErrBadMerkleRoot
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// reseal recomputes the Merkle root of an altered block and mines it again
+func reseal(params ChainParams, block *Block) *Block {
+	root, err := ComputeMerkleRoot(block.Transactions)
+	if err != nil {
+		panic(err)
+	}
+	block.MerkleRoot = root
+	block.Nonce = 0
+	block.MineBlock(params.PoW())
+	return block
+}
+
+func TestValidateBlockRejections(t *testing.T) {
+	params := testParams()
+	params.MaxBlockTransactions = 2
+	bc := NewBlockchainWithParams(params)
+	alice := newWallet(t)
+	funded := mineOn(t, bc, bc.GetLatestBlock(), alice.address)
+	payment := alice.pay(t, "bob", 0.5, 0.1)
+
+	for _, test := range []struct {
+		name  string
+		block func() *Block
+		want  error
+	}{
+		{"valid", func() *Block {
+			return newBlockOn(params, funded, "miner", payment)
+		}, nil},
+		{"known block", func() *Block {
+			return funded
+		}, ErrKnownBlock},
+		{"unknown parent", func() *Block {
+			block := newBlockOn(params, funded, "miner")
+			block.PreviousHash = emptyMerkleRoot
+			return reseal(params, block)
+		}, ErrBadPrevHash},
+		{"index out of sequence", func() *Block {
+			block := newBlockOn(params, funded, "miner")
+			block.Index++
+			return reseal(params, block)
+		}, ErrBadIndex},
+		{"timestamp before the parent", func() *Block {
+			block := newBlockOn(params, funded, "miner")
+			block.Timestamp = funded.Timestamp - 1
+			return reseal(params, block)
+		}, ErrBadTimestamp},
+		{"timestamp too far ahead", func() *Block {
+			block := newBlockOn(params, funded, "miner")
+			block.Timestamp = time.Now().Unix() + MaxFutureBlockTime + 60
+			return reseal(params, block)
+		}, ErrBadTimestamp},
+		{"difficulty off schedule", func() *Block {
+			block := newBlockOn(params, funded, "miner")
+			block.Difficulty++
+			return reseal(params, block)
+		}, ErrBadDifficulty},
+		{"hash does not match", func() *Block {
+			block := newBlockOn(params, funded, "miner")
+			block.Nonce++
+			return block
+		}, ErrBadHash},
+		{"merkle root does not match", func() *Block {
+			block := newBlockOn(params, funded, "miner", payment)
+			block.Transactions = block.Transactions[1:]
+			block.Nonce = 0
+			block.MineBlock(params.PoW())
+			return block
+		}, ErrBadMerkleRoot},
+		{"too many transactions", func() *Block {
+			return newBlockOn(params, funded, "miner",
+				alice.pay(t, "bob", 0.1, 0), alice.pay(t, "carol", 0.1, 0), alice.pay(t, "dave", 0.1, 0))
+		}, ErrBlockTooLarge},
+		{"second coinbase", func() *Block {
+			block := newBlockOn(params, funded, "miner")
+			block.Transactions = append(block.Transactions, NewCoinbaseTransaction("thief", block.Index, 0))
+			return reseal(params, block)
+		}, ErrBadCoinbase},
+		{"no coinbase", func() *Block {
+			block := newBlockOn(params, funded, "miner", payment)
+			block.Transactions = block.Transactions[:1]
+			return reseal(params, block)
+		}, ErrBadCoinbase},
+		{"coinbase above reward plus fees", func() *Block {
+			block := newBlockOn(params, funded, "miner", payment)
+			block.Transactions[1] = NewCoinbaseTransaction("miner", block.Index, payment.Fee+0.1)
+			return reseal(params, block)
+		}, ErrBadCoinbase},
+		{"coinbase for another height", func() *Block {
+			block := newBlockOn(params, funded, "miner")
+			block.Transactions[0] = NewCoinbaseTransaction("miner", block.Index+1, 0)
+			return reseal(params, block)
+		}, ErrBadCoinbase},
+		{"duplicate transaction", func() *Block {
+			return newBlockOn(params, funded, "miner", payment, payment)
+		}, ErrInvalidTx},
+		{"already confirmed transaction", func() *Block {
+			block := newBlockOn(params, funded, "miner")
+			block.Transactions = append(block.Transactions, funded.Transactions[0])
+			return reseal(params, block)
+		}, ErrInvalidTx},
+		{"unsigned transaction", func() *Block {
+			return newBlockOn(params, funded, "miner", NewTransaction(alice.address, "bob", 0.5))
+		}, ErrInvalidTx},
+		{"overspend across transactions", func() *Block {
+			// Each payment alone is covered by alice's balance of 1
+			return newBlockOn(params, funded, "miner", alice.pay(t, "bob", 0.6, 0), alice.pay(t, "carol", 0.6, 0))
+		}, ErrInvalidTx},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := bc.ValidateBlock(test.block())
+			if test.want == nil {
+				if err != nil {
+					t.Errorf("valid block rejected: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, test.want) {
+				t.Errorf("validated with %v, want %v", err, test.want)
+			}
+		})
+	}
+}
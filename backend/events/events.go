@@ -0,0 +1,151 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Type identifies the kind of event published on the bus
+type Type string
+
+const (
+	MiningStarted    Type = "mining_started"
+	MinerStarted     Type = "miner_started"
+	HashRate         Type = "hash_rate"
+	NonceProgress    Type = "nonce_progress"
+	WinnerFound      Type = "winner_found"
+	MinerStopped     Type = "miner_stopped"
+	MiningFinished   Type = "mining_finished"
+	TokenHop         Type = "token_hop"
+	Termination      Type = "termination"
+	DeadlockCheck    Type = "deadlock_check"
+	DeadlockAlert    Type = "deadlock_alert"
+	DeadlockRecovery Type = "deadlock_recovery"
+	BankerDecision   Type = "banker_decision"
+	BlockAppended    Type = "block_appended"
+	BlockLogRepaired Type = "block_log_repaired"
+	TransactionAdded Type = "transaction_added"
+	PeerConnected    Type = "peer_connected"
+	PeerSync         Type = "peer_sync"
+	ForkDetected     Type = "fork_detected"
+	Reorg            Type = "reorg"
+	MinerService     Type = "miner_service"
+	MiningJob        Type = "mining_job"
+	StaleShare       Type = "stale_share"
+	ValidatorService Type = "validator_service"
+	SlotLeader       Type = "slot_leader"
+)
+
+// Event is a single structured notification. Message is the human readable
+// line that used to be printed directly; Data carries the structured payload.
+type Event struct {
+	Type    Type        `json:"type"`
+	Time    int64       `json:"time"` // Unix milliseconds
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Bus fans published events out to synchronous handlers and channel subscribers
+type Bus struct {
+	handlers    []func(Event)
+	subscribers map[int]chan Event
+	nextID      int
+	mutex       sync.RWMutex
+}
+
+// NewBus creates an empty event bus
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[int]chan Event),
+	}
+}
+
+// Default is the bus the miner, blockchain and deadlock detector publish to
+var Default = NewBus()
+
+// Publish sends an event to every handler and subscriber. Handlers run
+// synchronously; subscribers whose buffers are full miss the event rather
+// than stalling the publisher.
+func (b *Bus) Publish(t Type, message string, data interface{}) {
+	event := Event{
+		Type:    t,
+		Time:    time.Now().UnixMilli(),
+		Message: message,
+		Data:    data,
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Slow subscriber, drop the event
+		}
+	}
+}
+
+// Handle registers a function that is called synchronously for every event
+func (b *Bus) Handle(handler func(Event)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Subscribe returns a channel receiving future events and a function that
+// unsubscribes and closes the channel
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	ch := make(chan Event, buffer)
+	b.subscribers[id] = ch
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			delete(b.subscribers, id)
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Publish sends an event on the Default bus
+func Publish(t Type, message string, data interface{}) {
+	Default.Publish(t, message, data)
+}
+
+// Publishf formats the message and sends an event on the Default bus
+func Publishf(t Type, data interface{}, format string, args ...interface{}) {
+	Default.Publish(t, fmt.Sprintf(format, args...), data)
+}
+
+// quietTypes are published too often to echo to the console: every miner
+// reports its hash rate and nonce every 500ms, every round passes the
+// termination token around, and the deadlock check runs every 5 seconds.
+// Subscribers such as the WebSocket feed still receive them.
+var quietTypes = map[Type]bool{
+	HashRate:      true,
+	NonceProgress: true,
+	TokenHop:      true,
+	DeadlockCheck: true,
+}
+
+// PrintToConsole is a handler that writes each event's message to stdout,
+// leaving out the high-frequency types
+func PrintToConsole(event Event) {
+	if quietTypes[event.Type] {
+		return
+	}
+	fmt.Println(event.Message)
+}
@@ -5,3 +5,5 @@ go 1.18
 require github.com/gorilla/mux v1.8.1
 
 require github.com/rs/cors v1.11.1
+
+require golang.org/x/crypto v0.9.0
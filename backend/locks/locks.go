@@ -0,0 +1,87 @@
+// Package locks provides mutexes that report every wait, acquisition and
+// release to a Tracker, so a deadlock detector sees the real state of the
+// program's locks instead of a staged one.
+package locks
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Tracker is told about lock activity. A process is a goroutine, identified
+// by Process, and a resource is a lock, identified by the ID it was created
+// with. Calls must not block on any tracked lock.
+type Tracker interface {
+	// Waiting is called when process blocks on a resource another process holds
+	Waiting(process, resource int)
+	// Acquired is called when process takes a resource, ending any wait for it
+	Acquired(process, resource int)
+	// Abandoned is called when process stops waiting without taking the resource
+	Abandoned(process, resource int)
+	// Released is called when process gives a resource up
+	Released(process, resource int)
+}
+
+// trackerBox lets a nil Tracker be stored in an atomic.Value
+type trackerBox struct {
+	tracker Tracker
+}
+
+var tracker atomic.Value // trackerBox
+
+// SetTracker sends the activity of every lock to t from now on. A nil t
+// turns tracking off, which skips looking up the calling goroutine and
+// leaves uncontended locks nearly as cheap as sync's.
+func SetTracker(t Tracker) {
+	tracker.Store(trackerBox{tracker: t})
+}
+
+// CurrentTracker returns the tracker, or nil if tracking is off
+func CurrentTracker() Tracker {
+	box, _ := tracker.Load().(trackerBox)
+	return box.tracker
+}
+
+var (
+	nextResource int64
+	names        = make(map[int]string)
+	namesMutex   sync.Mutex
+)
+
+// register assigns the next resource ID to a lock called name
+func register(name string) int {
+	id := int(atomic.AddInt64(&nextResource, 1))
+	namesMutex.Lock()
+	names[id] = name
+	namesMutex.Unlock()
+	return id
+}
+
+// ResourceName returns the name a lock was created with
+func ResourceName(resource int) (string, bool) {
+	namesMutex.Lock()
+	defer namesMutex.Unlock()
+
+	name, ok := names[resource]
+	return name, ok
+}
+
+// goroutinePrefix starts the first line of every goroutine's stack trace
+var goroutinePrefix = []byte("goroutine ")
+
+// Process returns the ID of the calling goroutine, which is how trackers
+// tell processes apart. Go does not expose the ID, so it is read from the
+// goroutine's stack trace header.
+func Process() int {
+	var buf [64]byte
+	header := buf[:runtime.Stack(buf[:], false)]
+	header = bytes.TrimPrefix(header, goroutinePrefix)
+	if end := bytes.IndexByte(header, ' '); end >= 0 {
+		header = header[:end]
+	}
+	id, _ := strconv.Atoi(string(header))
+	return id
+}
@@ -0,0 +1,274 @@
+package locks
+
+import (
+	"context"
+	"sync"
+)
+
+// Mutex is a mutual exclusion lock that reports to the tracker. A wait is
+// only reported when the lock is actually contended. Unlike sync.Mutex, a
+// wait can be abandoned through LockContext, which is how a deadlock victim
+// blocked on the lock is freed.
+type Mutex struct {
+	id    int
+	owner int           // Process holding the lock, as reported to the tracker
+	token chan struct{} // Holds a token while locked
+}
+
+// NewMutex creates an unlocked mutex identified to trackers by name
+func NewMutex(name string) *Mutex {
+	return &Mutex{id: register(name), token: make(chan struct{}, 1)}
+}
+
+// ID returns the resource ID trackers know the mutex by
+func (m *Mutex) ID() int {
+	return m.id
+}
+
+// Lock locks m, reporting the wait if another process holds it
+func (m *Mutex) Lock() {
+	m.LockContext(context.Background())
+}
+
+// LockContext locks m like Lock, but gives up and returns ctx's error if ctx
+// is done before the lock is free
+func (m *Mutex) LockContext(ctx context.Context) error {
+	t := CurrentTracker()
+	if t == nil {
+		select {
+		case m.token <- struct{}{}:
+			m.owner = 0
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	process := Process()
+	select {
+	case m.token <- struct{}{}:
+	default:
+		t.Waiting(process, m.id)
+		select {
+		case m.token <- struct{}{}:
+		case <-ctx.Done():
+			t.Abandoned(process, m.id)
+			return ctx.Err()
+		}
+	}
+	m.owner = process
+	t.Acquired(process, m.id)
+	return nil
+}
+
+// Unlock unlocks m. As with sync.Mutex, it need not be called by the
+// goroutine that locked it.
+func (m *Mutex) Unlock() {
+	owner := m.owner
+	m.owner = 0
+	select {
+	case <-m.token:
+	default:
+		panic("locks: unlock of unlocked mutex")
+	}
+	if t := CurrentTracker(); t != nil && owner != 0 {
+		t.Released(owner, m.id)
+	}
+}
+
+// RWMutex is a reader/writer mutual exclusion lock that reports to the
+// tracker. Each reader is reported as a holder of its own, and, as with
+// Mutex, a wait is only reported when the lock is actually contended. As
+// with sync.RWMutex, a writer waiting for the lock keeps new readers out.
+// Waits can be abandoned through LockContext and RLockContext.
+type RWMutex struct {
+	id             int
+	writing        bool
+	writer         int           // Process holding the write lock, as reported to the tracker
+	readers        []int         // Processes holding a read lock, oldest first, 0 for those taken untracked
+	writersWaiting int           // Writers blocked on the lock, which keep new readers out
+	waiters        int           // Readers and writers blocked on the lock
+	changed        chan struct{} // Closed and replaced whenever a waiter may be able to proceed
+	mutex          sync.Mutex    // Guards the fields above
+}
+
+// NewRWMutex creates an unlocked reader/writer mutex identified to trackers by name
+func NewRWMutex(name string) *RWMutex {
+	return &RWMutex{id: register(name), changed: make(chan struct{})}
+}
+
+// ID returns the resource ID trackers know the mutex by
+func (m *RWMutex) ID() int {
+	return m.id
+}
+
+// Lock locks m for writing, reporting the wait if any process holds it
+func (m *RWMutex) Lock() {
+	m.LockContext(context.Background())
+}
+
+// LockContext locks m for writing like Lock, but gives up and returns ctx's
+// error if ctx is done before the lock is free
+func (m *RWMutex) LockContext(ctx context.Context) error {
+	t := CurrentTracker()
+	process := 0
+	if t != nil {
+		process = Process()
+	}
+
+	m.mutex.Lock()
+	if m.writing || len(m.readers) > 0 {
+		m.writersWaiting++
+		err := m.wait(ctx, t, process, func() bool { return m.writing || len(m.readers) > 0 })
+		m.writersWaiting--
+		if err != nil {
+			// Readers held back by this writer may go ahead now
+			m.broadcast()
+			m.mutex.Unlock()
+			return err
+		}
+	}
+	m.writing = true
+	m.writer = process
+	m.mutex.Unlock()
+
+	if t != nil {
+		t.Acquired(process, m.id)
+	}
+	return nil
+}
+
+// Unlock unlocks m for writing
+func (m *RWMutex) Unlock() {
+	m.mutex.Lock()
+	if !m.writing {
+		m.mutex.Unlock()
+		panic("locks: unlock of unlocked RWMutex")
+	}
+	writer := m.writer
+	m.writing = false
+	m.writer = 0
+	m.broadcast()
+	m.mutex.Unlock()
+
+	if t := CurrentTracker(); t != nil && writer != 0 {
+		t.Released(writer, m.id)
+	}
+}
+
+// RLock locks m for reading, reporting the wait if a writer holds it or is
+// queued for it
+func (m *RWMutex) RLock() {
+	m.RLockContext(context.Background())
+}
+
+// RLockContext locks m for reading like RLock, but gives up and returns
+// ctx's error if ctx is done before the lock can be shared
+func (m *RWMutex) RLockContext(ctx context.Context) error {
+	t := CurrentTracker()
+	process := 0
+	if t != nil {
+		process = Process()
+	}
+
+	m.mutex.Lock()
+	if m.writing || m.writersWaiting > 0 {
+		if err := m.wait(ctx, t, process, func() bool { return m.writing || m.writersWaiting > 0 }); err != nil {
+			m.mutex.Unlock()
+			return err
+		}
+	}
+	m.readers = append(m.readers, process)
+	m.mutex.Unlock()
+
+	if t != nil {
+		t.Acquired(process, m.id)
+	}
+	return nil
+}
+
+// RUnlock undoes a single RLock call. It releases the calling goroutine's
+// read lock if it holds one; otherwise, as with sync.RWMutex, it may be
+// called by another goroutine, and the oldest reader's hold is released.
+func (m *RWMutex) RUnlock() {
+	t := CurrentTracker()
+	process := 0
+	if t != nil {
+		process = Process()
+	}
+
+	m.mutex.Lock()
+	if len(m.readers) == 0 {
+		m.mutex.Unlock()
+		panic("locks: RUnlock of unlocked RWMutex")
+	}
+	reader := m.removeReader(process)
+	if len(m.readers) == 0 {
+		m.broadcast()
+	}
+	m.mutex.Unlock()
+
+	if t != nil && reader != 0 {
+		t.Released(reader, m.id)
+	}
+}
+
+// wait blocks until blocked returns false, reporting the wait to t, or
+// until ctx is done, reporting the wait abandoned and returning ctx's
+// error. The caller must hold the mutex, which is released while waiting
+// and held again when wait returns.
+func (m *RWMutex) wait(ctx context.Context, t Tracker, process int, blocked func() bool) error {
+	reported := false
+	m.waiters++
+	defer func() { m.waiters-- }()
+	for blocked() {
+		changed := m.changed
+		m.mutex.Unlock()
+		if t != nil && !reported {
+			t.Waiting(process, m.id)
+			reported = true
+		}
+
+		select {
+		case <-changed:
+			m.mutex.Lock()
+		case <-ctx.Done():
+			if reported {
+				t.Abandoned(process, m.id)
+			}
+			m.mutex.Lock()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// broadcast wakes every waiter to check the lock again. Without waiters
+// there is nobody to wake, so uncontended locking allocates nothing. The
+// caller must hold the mutex.
+func (m *RWMutex) broadcast() {
+	if m.waiters == 0 {
+		return
+	}
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// removeReader forgets the read lock RUnlock is undoing for process and
+// returns the process that held it, or 0 if it was taken untracked. With
+// process 0, as when tracking is off, the oldest reader's hold goes. The
+// caller must hold the mutex and there must be a reader.
+func (m *RWMutex) removeReader(process int) int {
+	i := 0
+	if process != 0 {
+		for j, reader := range m.readers {
+			if reader == process {
+				i = j
+				break
+			}
+		}
+	}
+	reader := m.readers[i]
+	m.readers = append(m.readers[:i], m.readers[i+1:]...)
+	return reader
+}
@@ -0,0 +1,145 @@
+package locks
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// holdTracker records which processes hold which resources
+type holdTracker struct {
+	holds map[int][]int
+	mutex sync.Mutex
+}
+
+func newHoldTracker() *holdTracker {
+	return &holdTracker{holds: make(map[int][]int)}
+}
+
+func (h *holdTracker) Waiting(process, resource int)   {}
+func (h *holdTracker) Abandoned(process, resource int) {}
+
+func (h *holdTracker) Acquired(process, resource int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.holds[process] = append(h.holds[process], resource)
+}
+
+func (h *holdTracker) Released(process, resource int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i, r := range h.holds[process] {
+		if r == resource {
+			h.holds[process] = append(h.holds[process][:i], h.holds[process][i+1:]...)
+			break
+		}
+	}
+	if len(h.holds[process]) == 0 {
+		delete(h.holds, process)
+	}
+}
+
+func TestReadLockReleasedOnAnotherGoroutine(t *testing.T) {
+	tracker := newHoldTracker()
+	SetTracker(tracker)
+	defer SetTracker(nil)
+
+	m := NewRWMutex("test")
+	m.RLock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.RUnlock()
+	}()
+	<-done
+
+	if len(tracker.holds) != 0 {
+		t.Errorf("holds %v after the read lock was released, want none", tracker.holds)
+	}
+}
+
+func TestReadLockReleasesItsOwnHold(t *testing.T) {
+	tracker := newHoldTracker()
+	SetTracker(tracker)
+	defer SetTracker(nil)
+
+	// Another goroutine takes the first read lock, so releasing ours must
+	// not release theirs
+	m := NewRWMutex("test")
+	var other int
+	locked := make(chan struct{})
+	go func() {
+		other = Process()
+		m.RLock()
+		close(locked)
+	}()
+	<-locked
+	m.RLock()
+	m.RUnlock()
+
+	if want := map[int][]int{other: {m.ID()}}; !reflect.DeepEqual(tracker.holds, want) {
+		t.Errorf("holds %v, want %v", tracker.holds, want)
+	}
+	m.RUnlock()
+}
+
+func TestAbandonedWriteLockLetsReadersIn(t *testing.T) {
+	m := NewRWMutex("test")
+	m.RLock()
+
+	// The writer queues behind the reader and gives up
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := make(chan error)
+	go func() {
+		abandoned <- m.LockContext(ctx)
+	}()
+	for {
+		m.mutex.Lock()
+		waiting := m.writersWaiting
+		m.mutex.Unlock()
+		if waiting > 0 {
+			break
+		}
+		runtime.Gosched()
+	}
+	done, cancelDone := context.WithCancel(context.Background())
+	cancelDone()
+	if err := m.RLockContext(done); err == nil {
+		t.Fatal("read lock taken while a writer was waiting")
+	}
+	cancel()
+	if err := <-abandoned; !errors.Is(err, context.Canceled) {
+		t.Fatalf("abandoned write lock returned %v, want %v", err, context.Canceled)
+	}
+
+	// New readers are no longer held back
+	m.RLock()
+	m.RUnlock()
+	m.RUnlock()
+	if err := m.LockContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	m.Unlock()
+}
+
+// BenchmarkUntrackedReadLock takes and releases a read lock with tracking
+// off, which should stay within a small factor of BenchmarkSyncReadLock
+func BenchmarkUntrackedReadLock(b *testing.B) {
+	SetTracker(nil)
+	m := NewRWMutex("bench")
+	for i := 0; i < b.N; i++ {
+		m.RLock()
+		m.RUnlock()
+	}
+}
+
+func BenchmarkSyncReadLock(b *testing.B) {
+	var m sync.RWMutex
+	for i := 0; i < b.N; i++ {
+		m.RLock()
+		m.RUnlock()
+	}
+}
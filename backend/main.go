@@ -1,23 +1,102 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"blockchain-visualizer/api"
 	"blockchain-visualizer/blockchain"
+	"blockchain-visualizer/events"
+	"blockchain-visualizer/locks"
 	"blockchain-visualizer/miner"
+	"blockchain-visualizer/network"
+	"blockchain-visualizer/wallet"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 )
 
 func main() {
-	// Initialize the blockchain
-	blockchain := blockchain.NewBlockchain()
+	// Echo published events to the console, apart from the high-frequency ones
+	events.Default.Handle(events.PrintToConsole)
+
+	dataDir := flag.String("datadir", "data", "directory for chain storage (empty keeps the chain in memory only)")
+	port := flag.Int("port", 8080, "port to serve the API and peer-to-peer endpoints on")
+	peers := flag.String("peers", "", "comma separated URLs of peers to connect to, e.g. http://localhost:8081")
+	advertise := flag.String("advertise", "", "URL peers should use to reach this node (default http://localhost:<port>)")
+	consensus := flag.String("consensus", blockchain.ConsensusPoW, "consensus engine for a new chain: pow or pos")
+	pow := flag.String("pow", blockchain.PoWSHA256, "proof of work for a new chain: "+strings.Join(blockchain.ProofOfWorkNames(), ", "))
+	difficulty := flag.Int("difficulty", 0, "genesis difficulty for a new chain (0 for the proof of work's default)")
+	maxBlockTransactions := flag.Int("max-block-txs", blockchain.DefaultMaxBlockTransactions, "maximum transactions per block besides the coinbase (0 for no limit)")
+	maxBlockBytes := flag.Int("max-block-bytes", blockchain.DefaultMaxBlockBytes, "maximum bytes of transactions per block besides the coinbase (0 for no limit)")
+	recoveryPolicy := flag.String("deadlock-recovery", string(miner.RecoverNone), "what to do about detected deadlocks: none, abort_youngest, preempt or rollback")
+	walletToken := flag.String("wallet-token", "", "token clients present to have the server sign with its wallets (default a random token printed at startup)")
+	flag.Parse()
+
+	policy, err := miner.ParseRecoveryPolicy(*recoveryPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	params, err := blockchain.NewChainParams(*pow)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *difficulty > 0 {
+		params.InitialDifficulty = *difficulty
+	}
+	if *consensus != blockchain.ConsensusPoW {
+		// Proof-of-stake blocks are signed, so -pow and -difficulty do not apply
+		params = blockchain.NewProofOfStakeParams()
+		params.Consensus = *consensus
+	}
+	params.MaxBlockTransactions = *maxBlockTransactions
+	params.MaxBlockBytes = *maxBlockBytes
+
+	if *advertise == "" {
+		*advertise = fmt.Sprintf("http://localhost:%d", *port)
+	}
+
+	// Initialize the blockchain and keystore, recovering any persisted state
+	var store blockchain.Store = blockchain.NewMemoryStore()
+	keystore := wallet.NewKeystore()
+	if *dataDir != "" {
+		fileStore, err := blockchain.OpenFileStore(*dataDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		store = fileStore
+
+		keystore, err = wallet.OpenKeystore(filepath.Join(*dataDir, "wallets.json"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *walletToken == "" {
+		token := make([]byte, 16)
+		if _, err := rand.Read(token); err != nil {
+			log.Fatal(err)
+		}
+		*walletToken = hex.EncodeToString(token)
+		fmt.Printf("Wallet token for server-side signing: %s\n", *walletToken)
+	}
+	keystore.SetSigningToken(*walletToken)
+
+	blockchain, err := blockchain.OpenBlockchain(store, params)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer blockchain.Close()
+	fmt.Printf("Loaded chain with %d blocks and %d pending transactions\n",
+		blockchain.Height()+1, len(blockchain.GetPendingTransactions()))
 
 	// Set up the router
 	router := mux.NewRouter()
@@ -27,17 +106,20 @@ func main() {
 	fmt.Printf("Using %d miners for concurrent mining with spanning tree termination\n", numMiners)
 
 	// Define API routes with mining options
-	api.SetupRoutesWithMining(router, blockchain, numMiners)
+	api.SetupRoutesWithMining(router, blockchain, keystore, numMiners)
+
+	// Join the peer-to-peer network
+	node := network.NewNetwork(*advertise, blockchain)
+	api.SetupNetworkRoutes(router, node)
 
-	// Initialize deadlock detector
+	// Initialize deadlock detector and feed it every instrumented lock
 	detector := miner.NewDeadlockDetector()
+	detector.SetRecoveryPolicy(policy)
+	locks.SetTracker(detector)
 
-	// Create a sample deadlock for demonstration purposes
-	// fmt.Println("▸▸▸ Creating sample deadlock scenario for demonstration")
-	// detector.AddAllocation(1, 1)
-	// detector.AddWaitFor(1, 2)
-	// detector.AddAllocation(2, 2)
-	// detector.AddWaitFor(2, 1)
+	// Scenarios staged by hand get a detector of their own
+	scenarios := miner.NewDeadlockDetector()
+	api.SetupDeadlockRoutes(router, detector, scenarios)
 
 	// Run deadlock detection immediately at startup
 	fmt.Println("\n▸▸▸ Running initial deadlock detection...")
@@ -54,7 +136,6 @@ func main() {
 		for {
 			select {
 			case <-ticker.C:
-				fmt.Println("\n▸▸▸ Running scheduled deadlock detection...")
 				detector.PrintDeadlocks()
 			case <-stopChan:
 				return
@@ -62,6 +143,14 @@ func main() {
 		}
 	}()
 
+	bootstrap := []string{}
+	for _, peer := range strings.Split(*peers, ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			bootstrap = append(bootstrap, peer)
+		}
+	}
+	node.Start(bootstrap, stopChan)
+
 	// CORS configuration
 	corsOptions := cors.Options{
 		AllowedOrigins:   []string{"*", "http://localhost:3000"}, // Allow all origins for testing
@@ -74,8 +163,9 @@ func main() {
 	handler := corsHandler.Handler(router)
 
 	// This call blocks until the server is shut down
-	fmt.Println("Starting server on :8080")
-	log.Fatal(http.ListenAndServe(":8080", handler))
+	addr := fmt.Sprintf(":%d", *port)
+	fmt.Printf("Starting server on %s as %s\n", addr, *advertise)
+	log.Fatal(http.ListenAndServe(addr, handler))
 
 	// These lines will only execute if the server shuts down gracefully
 	close(stopChan)
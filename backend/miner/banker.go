@@ -0,0 +1,357 @@
+package miner
+
+import (
+	"blockchain-visualizer/events"
+	"blockchain-visualizer/locks"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Errors returned by the Banker for requests it can never grant
+var (
+	ErrUnknownResource = errors.New("resource is not managed by the banker")
+	ErrClaimTooLarge   = errors.New("claim exceeds the resource's instances")
+	ErrUndeclared      = errors.New("process has not declared its maximum claim")
+	ErrExceedsClaim    = errors.New("request exceeds the process's declared maximum claim")
+	ErrBadRelease      = errors.New("process does not hold that many instances")
+)
+
+// Banker allocates instances of resources to processes using the Banker's
+// algorithm. Processes declare the most of each resource they will ever
+// hold, and a request is only granted if every process could still finish
+// afterwards, in some order, with what is left. Processes and resources are
+// the same IDs DeadlockDetector uses, and every grant, wait and release is
+// passed to the tracker, so with a DeadlockDetector as tracker the same
+// workload can be run with avoidance and, after SetAvoidance(false), with
+// detection only. The tracker is told one unit at a time, and a
+// DeadlockDetector also learns how many instances each resource has.
+type Banker struct {
+	total      map[int]int         // Resource to its number of instances
+	available  map[int]int         // Resource to instances not allocated
+	max        map[int]map[int]int // Process to its declared claim per resource
+	allocation map[int]map[int]int // Process to the instances it holds per resource
+	avoid      bool
+	tracker    locks.Tracker
+	changed    chan struct{} // Closed and replaced whenever instances are released or claims change
+	mutex      sync.Mutex
+}
+
+// BankerState is a snapshot of the allocator
+type BankerState struct {
+	Avoidance    bool                `json:"avoidance"`
+	Total        map[int]int         `json:"total"`
+	Available    map[int]int         `json:"available"`
+	Max          map[int]map[int]int `json:"max"`
+	Allocation   map[int]map[int]int `json:"allocation"`
+	Need         map[int]map[int]int `json:"need"`
+	Safe         bool                `json:"safe"`
+	SafeSequence []int               `json:"safeSequence"` // Order in which every process can finish, if Safe
+}
+
+// Grant is the outcome of a granted request
+type Grant struct {
+	Process      int   `json:"process"`
+	Resource     int   `json:"resource"`
+	Count        int   `json:"count"`
+	Waited       bool  `json:"waited"`
+	SafeSequence []int `json:"safeSequence,omitempty"` // Empty when avoidance is off
+}
+
+// resourceRegistry is implemented by trackers that count resource
+// instances, such as DeadlockDetector
+type resourceRegistry interface {
+	AddResource(resource Resource)
+}
+
+// NewBanker creates an allocator for resources, given as resource ID to
+// number of instances, with avoidance on. tracker may be nil.
+func NewBanker(resources map[int]int, tracker locks.Tracker) *Banker {
+	b := &Banker{
+		total:      make(map[int]int),
+		available:  make(map[int]int),
+		max:        make(map[int]map[int]int),
+		allocation: make(map[int]map[int]int),
+		avoid:      true,
+		tracker:    tracker,
+		changed:    make(chan struct{}),
+	}
+	registry, _ := tracker.(resourceRegistry)
+	for resource, instances := range resources {
+		b.total[resource] = instances
+		b.available[resource] = instances
+		if registry != nil {
+			registry.AddResource(Resource{ID: resource, Instances: instances})
+		}
+	}
+	return b
+}
+
+// SetAvoidance turns the safety check on or off. With it off, requests are
+// granted whenever enough instances are free, so deadlocks can form.
+func (b *Banker) SetAvoidance(on bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.avoid = on
+	b.notify()
+}
+
+// Declare sets the most of each resource process will ever hold
+func (b *Banker) Declare(process int, claims map[int]int) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for resource, claim := range claims {
+		total, ok := b.total[resource]
+		if !ok {
+			return fmt.Errorf("%w: %d", ErrUnknownResource, resource)
+		}
+		if claim < 0 || claim > total || claim < b.allocation[process][resource] {
+			return fmt.Errorf("%w: process %d claims %d of resource %d, which has %d",
+				ErrClaimTooLarge, process, claim, resource, total)
+		}
+	}
+
+	b.max[process] = make(map[int]int)
+	for resource, claim := range claims {
+		b.max[process][resource] = claim
+	}
+	if b.allocation[process] == nil {
+		b.allocation[process] = make(map[int]int)
+	}
+	b.notify()
+	return nil
+}
+
+// Request blocks until count instances of resource can be granted to
+// process, or ctx is done. With avoidance on, a request that would leave
+// the system unsafe waits even if the instances are free.
+func (b *Banker) Request(ctx context.Context, process, resource, count int) (Grant, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.checkRequest(process, resource, count); err != nil {
+		return Grant{}, err
+	}
+
+	grant := Grant{Process: process, Resource: resource, Count: count}
+	for {
+		sequence, reason := b.tryGrant(process, resource, count)
+		if reason == "" {
+			grant.SafeSequence = sequence
+			break
+		}
+
+		if !grant.Waited {
+			grant.Waited = true
+			if b.tracker != nil {
+				for i := 0; i < count; i++ {
+					b.tracker.Waiting(process, resource)
+				}
+			}
+			events.Publishf(events.BankerDecision, grant, "▸▸▸ Banker blocked process %d's request for %d of resource %d: %s",
+				process, count, resource, reason)
+		}
+
+		changed := b.changed
+		b.mutex.Unlock()
+		select {
+		case <-changed:
+			b.mutex.Lock()
+		case <-ctx.Done():
+			b.mutex.Lock()
+			if b.tracker != nil {
+				for i := 0; i < count; i++ {
+					b.tracker.Abandoned(process, resource)
+				}
+			}
+			return Grant{}, ctx.Err()
+		}
+	}
+
+	if b.tracker != nil {
+		for i := 0; i < count; i++ {
+			b.tracker.Acquired(process, resource)
+		}
+	}
+	message := fmt.Sprintf("▸▸▸ Banker granted process %d %d of resource %d", process, count, resource)
+	if b.avoid {
+		message += ", safe sequence " + formatCycle(grant.SafeSequence, " → ")
+	}
+	events.Publish(events.BankerDecision, message, grant)
+	return grant, nil
+}
+
+// Release returns count instances of resource held by process
+func (b *Banker) Release(process, resource, count int) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if count <= 0 || b.allocation[process][resource] < count {
+		return fmt.Errorf("%w: process %d releasing %d of resource %d", ErrBadRelease, process, count, resource)
+	}
+	b.release(process, resource, count)
+	b.notify()
+	return nil
+}
+
+// Finish releases everything process holds and withdraws its claim
+func (b *Banker) Finish(process int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for resource, count := range b.allocation[process] {
+		b.release(process, resource, count)
+	}
+	delete(b.allocation, process)
+	delete(b.max, process)
+	b.notify()
+}
+
+// State returns a snapshot of the allocator with the current safe sequence
+func (b *Banker) State() BankerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	sequence, safe := b.safeSequence()
+	state := BankerState{
+		Avoidance:    b.avoid,
+		Total:        copyCounts(b.total),
+		Available:    copyCounts(b.available),
+		Max:          make(map[int]map[int]int),
+		Allocation:   make(map[int]map[int]int),
+		Need:         make(map[int]map[int]int),
+		Safe:         safe,
+		SafeSequence: sequence,
+	}
+	for process := range b.max {
+		state.Max[process] = copyCounts(b.max[process])
+		state.Allocation[process] = copyCounts(b.allocation[process])
+		state.Need[process] = b.need(process)
+	}
+	return state
+}
+
+// checkRequest rejects requests that could never be granted. The caller
+// must hold the mutex.
+func (b *Banker) checkRequest(process, resource, count int) error {
+	if _, ok := b.total[resource]; !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownResource, resource)
+	}
+	claims, ok := b.max[process]
+	if !ok {
+		return fmt.Errorf("%w: process %d", ErrUndeclared, process)
+	}
+	if count <= 0 || b.allocation[process][resource]+count > claims[resource] {
+		return fmt.Errorf("%w: process %d holds %d of resource %d and requests %d, claim is %d",
+			ErrExceedsClaim, process, b.allocation[process][resource], resource, count, claims[resource])
+	}
+	return nil
+}
+
+// tryGrant allocates the request if it can be granted now, returning the
+// safe sequence afterwards, or otherwise the reason it must wait. The
+// caller must hold the mutex.
+func (b *Banker) tryGrant(process, resource, count int) ([]int, string) {
+	if b.available[resource] < count {
+		return nil, fmt.Sprintf("only %d available", b.available[resource])
+	}
+
+	b.available[resource] -= count
+	b.allocation[process][resource] += count
+	if !b.avoid {
+		return nil, ""
+	}
+	sequence, safe := b.safeSequence()
+	if !safe {
+		b.available[resource] += count
+		b.allocation[process][resource] -= count
+		return nil, "granting it would leave the system unsafe"
+	}
+	return sequence, ""
+}
+
+// safeSequence runs the safety algorithm: repeatedly pick a process whose
+// remaining need fits in the available instances and reclaim what it holds.
+// The state is safe if every process gets picked. Ties go to the lowest
+// process ID so the sequence is deterministic. The caller must hold the mutex.
+func (b *Banker) safeSequence() ([]int, bool) {
+	work := copyCounts(b.available)
+	processes := make([]int, 0, len(b.max))
+	for process := range b.max {
+		processes = append(processes, process)
+	}
+	sort.Ints(processes)
+
+	finished := make(map[int]bool)
+	sequence := []int{}
+	for len(sequence) < len(processes) {
+		progressed := false
+		for _, process := range processes {
+			if finished[process] || !fits(b.need(process), work) {
+				continue
+			}
+			for resource, count := range b.allocation[process] {
+				work[resource] += count
+			}
+			finished[process] = true
+			sequence = append(sequence, process)
+			progressed = true
+		}
+		if !progressed {
+			return sequence, false
+		}
+	}
+	return sequence, true
+}
+
+// need returns what process may still request of each resource. The
+// caller must hold the mutex.
+func (b *Banker) need(process int) map[int]int {
+	need := make(map[int]int)
+	for resource, claim := range b.max[process] {
+		need[resource] = claim - b.allocation[process][resource]
+	}
+	return need
+}
+
+// release returns instances to the pool and tells the tracker. The caller
+// must hold the mutex.
+func (b *Banker) release(process, resource, count int) {
+	b.allocation[process][resource] -= count
+	b.available[resource] += count
+	if b.tracker != nil {
+		for i := 0; i < count; i++ {
+			b.tracker.Released(process, resource)
+		}
+	}
+}
+
+// notify wakes every waiting request to check again. The caller must hold
+// the mutex.
+func (b *Banker) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// fits reports whether every count in need is covered by work
+func fits(need, work map[int]int) bool {
+	for resource, count := range need {
+		if count > work[resource] {
+			return false
+		}
+	}
+	return true
+}
+
+// copyCounts copies a resource count map
+func copyCounts(counts map[int]int) map[int]int {
+	copied := make(map[int]int, len(counts))
+	for resource, count := range counts {
+		copied[resource] = count
+	}
+	return copied
+}
@@ -0,0 +1,131 @@
+package miner
+
+import (
+	"blockchain-visualizer/events"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// bankerDemoHold is how long a demo process holds its first resource
+// before asking for the second, so every process gets its first one
+// before anyone asks for a second
+const bankerDemoHold = 50 * time.Millisecond
+
+// bankerDemoTimeout bounds a demo run that neither finishes nor deadlocks
+const bankerDemoTimeout = 5 * time.Second
+
+// bankerDemoPoll is how often a demo run checks for deadlocks
+const bankerDemoPoll = 10 * time.Millisecond
+
+// BankerDemoResult is the outcome of one run of the banker demo workload
+type BankerDemoResult struct {
+	Avoidance bool    `json:"avoidance"`
+	Processes int     `json:"processes"`
+	Finished  []int   `json:"finished"`  // Processes that got both resources, in the order they finished
+	Abandoned []int   `json:"abandoned"` // Processes whose wait was abandoned when the run ended
+	Waits     int     `json:"waits"`     // Requests the banker made wait
+	Deadlocks [][]int `json:"deadlocks"` // Cycles the detector found
+	ElapsedMs int64   `json:"elapsedMs"`
+}
+
+// RunBankerDemo runs a dining philosophers workload through a Banker. Each
+// of processes processes claims one instance of its own resource and of
+// its neighbour's, takes its own and then asks for its neighbour's, so
+// without avoidance they all end up waiting in a circle. The banker reports
+// to a DeadlockDetector of its own, away from the server's locks, and the
+// run ends once every process has finished or the detector finds a
+// deadlock, which abandons the waits. processes must be at least 2.
+func RunBankerDemo(processes int, avoidance bool) BankerDemoResult {
+	detector := NewDeadlockDetector()
+	resources := make(map[int]int)
+	for resource := 1; resource <= processes; resource++ {
+		resources[resource] = 1
+	}
+	banker := NewBanker(resources, detector)
+	banker.SetAvoidance(avoidance)
+
+	// Process p's own resource is p and its neighbour's is the next one
+	for process := 1; process <= processes; process++ {
+		banker.Declare(process, map[int]int{process: 1, process%processes + 1: 1})
+	}
+
+	result := BankerDemoResult{
+		Avoidance: avoidance,
+		Processes: processes,
+		Finished:  []int{},
+		Abandoned: []int{},
+		Deadlocks: [][]int{},
+	}
+	var resultMutex sync.Mutex
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := time.Now()
+	var wg sync.WaitGroup
+	for process := 1; process <= processes; process++ {
+		wg.Add(1)
+		go func(process int) {
+			defer wg.Done()
+			defer banker.Finish(process)
+
+			for _, resource := range []int{process, process%processes + 1} {
+				// Only the end of the run cancels a request, and only after
+				// it waited; any other error is a refusal, not a wait
+				grant, err := banker.Request(ctx, process, resource, 1)
+				abandoned := errors.Is(err, context.Canceled)
+				resultMutex.Lock()
+				if grant.Waited || abandoned {
+					result.Waits++
+				}
+				if abandoned {
+					result.Abandoned = append(result.Abandoned, process)
+				}
+				resultMutex.Unlock()
+				if err != nil {
+					return
+				}
+				time.Sleep(bankerDemoHold)
+			}
+
+			resultMutex.Lock()
+			result.Finished = append(result.Finished, process)
+			resultMutex.Unlock()
+		}(process)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	ticker := time.NewTicker(bankerDemoPoll)
+	defer ticker.Stop()
+	timeout := time.After(bankerDemoTimeout)
+
+	for running := true; running; {
+		select {
+		case <-finished:
+			running = false
+		case <-ticker.C:
+			if cycles := detector.DetectDeadlocks(); len(cycles) > 0 {
+				result.Deadlocks = cycles
+				running = false
+			}
+		case <-timeout:
+			running = false
+		}
+	}
+	cancel()
+	<-finished
+	result.ElapsedMs = time.Since(started).Milliseconds()
+
+	mode := "detection only"
+	if avoidance {
+		mode = "avoidance"
+	}
+	events.Publishf(events.BankerDecision, result, "▸▸▸ Banker demo with %s: %d of %d processes finished, %d requests waited, %d deadlocks found",
+		mode, len(result.Finished), processes, result.Waits, len(result.Deadlocks))
+	return result
+}
@@ -0,0 +1,167 @@
+package miner
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// textbookBanker sets up the classic five-process example with resources
+// A, B and C as 1, 2 and 3
+func textbookBanker(t *testing.T) *Banker {
+	b := NewBanker(map[int]int{1: 10, 2: 5, 3: 7}, nil)
+	claims := []map[int]int{
+		{1: 7, 2: 5, 3: 3},
+		{1: 3, 2: 2, 3: 2},
+		{1: 9, 2: 0, 3: 2},
+		{1: 2, 2: 2, 3: 2},
+		{1: 4, 2: 3, 3: 3},
+	}
+	allocations := []map[int]int{
+		{2: 1},
+		{1: 2},
+		{1: 3, 3: 2},
+		{1: 2, 2: 1, 3: 1},
+		{3: 2},
+	}
+	for process, claim := range claims {
+		if err := b.Declare(process, claim); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for process, allocation := range allocations {
+		for resource, count := range allocation {
+			request(t, b, process, resource, count)
+		}
+	}
+	return b
+}
+
+// request makes a request that must be granted without waiting
+func request(t *testing.T, b *Banker, process, resource, count int) Grant {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	grant, err := b.Request(ctx, process, resource, count)
+	if err != nil {
+		t.Fatalf("process %d requesting %d of resource %d: %v", process, count, resource, err)
+	}
+	return grant
+}
+
+func TestBankerFindsTextbookSafeSequence(t *testing.T) {
+	b := textbookBanker(t)
+
+	state := b.State()
+	if want := map[int]int{1: 3, 2: 3, 3: 2}; !reflect.DeepEqual(state.Available, want) {
+		t.Errorf("available %v, want %v", state.Available, want)
+	}
+	if want := []int{1, 3, 4, 0, 2}; !state.Safe || !reflect.DeepEqual(state.SafeSequence, want) {
+		t.Errorf("safe %v with sequence %v, want safe with %v", state.Safe, state.SafeSequence, want)
+	}
+
+	// P1 asking for (1, 0, 2) keeps the state safe
+	request(t, b, 1, 1, 1)
+	if grant := request(t, b, 1, 3, 2); len(grant.SafeSequence) != 5 {
+		t.Errorf("grant %+v, want a full safe sequence", grant)
+	}
+}
+
+func TestBankerRejectsImpossibleRequests(t *testing.T) {
+	b := textbookBanker(t)
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		name    string
+		process int
+		claims  map[int]int
+		want    error
+	}{
+		{"claim above the instances", 5, map[int]int{1: 11}, ErrClaimTooLarge},
+		{"claim below the allocation", 2, map[int]int{1: 2, 3: 2}, ErrClaimTooLarge},
+		{"unknown resource", 5, map[int]int{9: 1}, ErrUnknownResource},
+	} {
+		if err := b.Declare(test.process, test.claims); !errors.Is(err, test.want) {
+			t.Errorf("%s: declared with %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		name                     string
+		process, resource, count int
+		want                     error
+	}{
+		{"over the claim", 1, 1, 2, ErrExceedsClaim},
+		{"nothing", 1, 1, 0, ErrExceedsClaim},
+		{"undeclared process", 5, 1, 1, ErrUndeclared},
+		{"unknown resource", 1, 9, 1, ErrUnknownResource},
+	} {
+		if _, err := b.Request(ctx, test.process, test.resource, test.count); !errors.Is(err, test.want) {
+			t.Errorf("%s: requested with %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestBankerHoldsBackUnsafeRequest(t *testing.T) {
+	b := textbookBanker(t)
+	request(t, b, 1, 1, 1)
+	request(t, b, 1, 3, 2)
+	before := b.State()
+
+	// (0, 2, 0) for P0 is available, but would leave no process able to finish
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := b.Request(ctx, 0, 2, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unsafe request returned %v, want it to wait until %v", err, context.DeadlineExceeded)
+	}
+	if after := b.State(); !reflect.DeepEqual(after, before) {
+		t.Errorf("state changed by a refused request: %+v, want %+v", after, before)
+	}
+}
+
+func TestBankerGrantsWaitingRequestAfterRelease(t *testing.T) {
+	b := NewBanker(map[int]int{1: 1}, nil)
+	b.Declare(1, map[int]int{1: 1})
+	b.Declare(2, map[int]int{1: 1})
+	request(t, b, 1, 1, 1)
+
+	granted := make(chan Grant)
+	go func() {
+		grant, err := b.Request(context.Background(), 2, 1, 1)
+		if err != nil {
+			t.Error(err)
+		}
+		granted <- grant
+	}()
+
+	select {
+	case grant := <-granted:
+		t.Fatalf("request granted while the resource was held: %+v", grant)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := b.Release(1, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case grant := <-granted:
+		if !grant.Waited {
+			t.Errorf("grant %+v, want it to have waited", grant)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request still waiting after the release")
+	}
+}
+
+func TestBankerDemoAvoidsTheDeadlockDetectionFinds(t *testing.T) {
+	detection := RunBankerDemo(3, false)
+	if len(detection.Deadlocks) == 0 || len(detection.Abandoned) != 3 {
+		t.Errorf("without avoidance %+v, want a deadlock abandoning every process", detection)
+	}
+
+	avoidance := RunBankerDemo(3, true)
+	if len(avoidance.Deadlocks) != 0 || len(avoidance.Finished) != 3 || avoidance.Waits == 0 {
+		t.Errorf("with avoidance %+v, want every process to finish after some waits", avoidance)
+	}
+}
@@ -1,22 +1,43 @@
 package miner
 
 import (
+	"blockchain-visualizer/events"
+	"blockchain-visualizer/locks"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 )
 
-// Resource represents a system resource that can be allocated
+// Resource represents a system resource that can be allocated. A resource
+// with several instances can be held by that many processes at once.
 type Resource struct {
-	ID int
+	ID        int    `json:"id"`
+	Instances int    `json:"instances"`
+	Name      string `json:"name,omitempty"` // Shown in logs and graphs instead of the lock name, if any
 }
 
-// DeadlockDetector implements a simple deadlock detection algorithm
+// DeadlockDetector implements a simple deadlock detection algorithm. It is
+// a locks.Tracker, so once installed with locks.SetTracker it follows the
+// program's instrumented locks by itself; AddAllocation and AddWaitFor stage
+// holds and waits by hand, one unit of a resource per call. Deadlocks it
+// finds are broken according to its RecoveryPolicy.
 type DeadlockDetector struct {
 	// Which process holds which resources
 	allocations map[int][]int
 	// Which process is waiting for which resources
 	waitFor map[int][]int
-	mutex   sync.Mutex
+	// Instances of each resource with more than one
+	instances map[int]int
+	// Names given to resources with AddResource
+	names map[int]string
+	// Processes that may be cancelled to break a deadlock
+	processes    map[int]*process
+	policy       RecoveryPolicy
+	recoveries   []Recovery // Audit log, oldest first
+	nextRecovery int
+	stuck        map[string]bool // Cycles already logged as having no victim
+	mutex        sync.Mutex
 }
 
 // NewDeadlockDetector creates a new deadlock detector
@@ -24,9 +45,32 @@ func NewDeadlockDetector() *DeadlockDetector {
 	return &DeadlockDetector{
 		allocations: make(map[int][]int),
 		waitFor:     make(map[int][]int),
+		instances:   make(map[int]int),
+		names:       make(map[int]string),
+		processes:   make(map[int]*process),
+		policy:      RecoverNone,
 	}
 }
 
+// AddResource sets how many instances of a resource there are, and its name
+// if given. Resources that are not added, such as locks, have one.
+func (d *DeadlockDetector) AddResource(resource Resource) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.instances[resource.ID] = resource.Instances
+	if resource.Name != "" {
+		d.names[resource.ID] = resource.Name
+	}
+}
+
+// instancesOf returns the instances of resource. The caller must hold the mutex.
+func (d *DeadlockDetector) instancesOf(resource int) int {
+	if instances, ok := d.instances[resource]; ok {
+		return instances
+	}
+	return 1
+}
+
 // AddAllocation records that process holds resource
 func (d *DeadlockDetector) AddAllocation(process, resource int) {
 	d.mutex.Lock()
@@ -41,92 +85,315 @@ func (d *DeadlockDetector) AddWaitFor(process, resource int) {
 	d.waitFor[process] = append(d.waitFor[process], resource)
 }
 
-// DetectDeadlocks checks for deadlocks in the system
-func (d *DeadlockDetector) DetectDeadlocks() [][]int {
+// Waiting records that process blocked on resource
+func (d *DeadlockDetector) Waiting(process, resource int) {
+	d.AddWaitFor(process, resource)
+}
+
+// Acquired records that process took resource, which ends its wait for it
+func (d *DeadlockDetector) Acquired(process, resource int) {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
+	removeResource(d.waitFor, process, resource)
+	d.allocations[process] = append(d.allocations[process], resource)
+}
 
-	// Create wait-for graph: process -> processes it's waiting for
-	waitForGraph := make(map[int][]int)
+// Abandoned records that process gave up waiting for resource
+func (d *DeadlockDetector) Abandoned(process, resource int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	removeResource(d.waitFor, process, resource)
+}
 
-	// For each process waiting for resources
-	for process, resources := range d.waitFor {
-		for _, resource := range resources {
-			// Find which processes hold the resource
-			for holder, held := range d.allocations {
-				for _, r := range held {
-					if r == resource && holder != process {
-						// Process is waiting for holder
-						waitForGraph[process] = append(waitForGraph[process], holder)
-						fmt.Printf("▸▸▸ Process %d waits for Process %d (which holds resource %d)\n",
-							process, holder, resource)
-					}
-				}
+// Released records that process gave resource up
+func (d *DeadlockDetector) Released(process, resource int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	removeResource(d.allocations, process, resource)
+}
+
+// Reset forgets every hold, wait and resource, staged or tracked. Locks
+// held at the time reappear once they are next taken. Attached processes
+// and the recovery log are kept.
+func (d *DeadlockDetector) Reset() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.allocations = make(map[int][]int)
+	d.waitFor = make(map[int][]int)
+	d.instances = make(map[int]int)
+	d.names = make(map[int]string)
+	d.stuck = nil
+}
+
+// removeResource removes one occurrence of resource from process's list,
+// dropping the process once it has none left
+func removeResource(lists map[int][]int, process, resource int) {
+	list := lists[process]
+	for i, r := range list {
+		if r == resource {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(lists, process)
+	} else {
+		lists[process] = list
+	}
+}
+
+// resourceName returns the name given with AddResource or, failing that,
+// the lock name of resource if d is the detector tracking the locks, as
+// only its resources are locks. The caller must hold the mutex.
+func (d *DeadlockDetector) resourceName(resource int) (string, bool) {
+	if name, ok := d.names[resource]; ok {
+		return name, true
+	}
+	if t, ok := locks.CurrentTracker().(*DeadlockDetector); !ok || t != d {
+		return "", false
+	}
+	return locks.ResourceName(resource)
+}
+
+// describeResource names a resource for log lines, if it has a name. The
+// caller must hold the mutex.
+func (d *DeadlockDetector) describeResource(resource int) string {
+	if name, ok := d.resourceName(resource); ok {
+		return fmt.Sprintf("resource %d (%s)", resource, name)
+	}
+	return fmt.Sprintf("resource %d", resource)
+}
+
+// DeadlockAnalysis is the outcome of one detection pass. Requests and
+// allocations are counted in units, so resources with several instances
+// are handled: a process waiting for a resource is only deadlocked if no
+// order of finishing the other processes would ever free enough units.
+type DeadlockAnalysis struct {
+	Available  map[int]int         `json:"available"`  // Resource to its free units, negative if readers share a lock
+	Allocation map[int]map[int]int `json:"allocation"` // Process to the units it holds per resource
+	Request    map[int]map[int]int `json:"request"`    // Process to the units it waits for per resource
+	WaitFor    map[int][]int       `json:"waitFor"`    // Process to the processes holding what it waits for
+	Edges      []WaitEdge          `json:"edges"`      // Each edge of WaitFor with the resource behind it
+	Deadlocked []int               `json:"deadlocked"` // Processes that can never finish
+	Sets       [][]int             `json:"sets"`       // Deadlocked processes grouped into strongly connected sets of the wait-for graph
+	Blocked    []int               `json:"blocked"`    // Deadlocked processes in no set, waiting on one
+	Cycles     [][]int             `json:"cycles"`     // Every elementary cycle through deadlocked processes, its first process repeated at the end
+}
+
+// DetectDeadlocks checks for deadlocks in the system and returns every
+// cycle of processes waiting on each other
+func (d *DeadlockDetector) DetectDeadlocks() [][]int {
+	return d.Analyze().Cycles
+}
+
+// Analyze runs the matrix detection algorithm over the current holds and
+// waits, then groups the deadlocked processes into the strongly connected
+// sets of the wait-for graph (Tarjan) and enumerates every cycle among them
+// (Johnson)
+func (d *DeadlockDetector) Analyze() DeadlockAnalysis {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.analyze()
+}
+
+// analyze is Analyze for callers that hold the mutex
+func (d *DeadlockDetector) analyze() DeadlockAnalysis {
+	analysis := DeadlockAnalysis{
+		Available:  make(map[int]int),
+		Allocation: countUnits(d.allocations),
+		Request:    countUnits(d.waitFor),
+	}
+	analysis.WaitFor, analysis.Edges = d.waitForGraph()
+	for resource, units := range d.instances {
+		analysis.Available[resource] = units
+	}
+	for _, counts := range []map[int]map[int]int{analysis.Allocation, analysis.Request} {
+		for _, units := range counts {
+			for resource := range units {
+				analysis.Available[resource] = d.instancesOf(resource)
 			}
 		}
 	}
+	for _, held := range analysis.Allocation {
+		for resource, units := range held {
+			analysis.Available[resource] -= units
+		}
+	}
 
-	// Now detect cycles in the wait-for graph using DFS
-	deadlocks := [][]int{}
-	visited := make(map[int]bool)
+	analysis.Deadlocked = deadlockedProcesses(analysis.Available, analysis.Allocation, analysis.Request)
+	deadlocked := make(map[int]bool, len(analysis.Deadlocked))
+	for _, process := range analysis.Deadlocked {
+		deadlocked[process] = true
+	}
+	graph := inducedSubgraph(analysis.WaitFor, func(process int) bool { return deadlocked[process] })
 
-	for node := range waitForGraph {
-		if !visited[node] {
-			path := []int{}
-			d.findDeadlockCycles(node, waitForGraph, visited, path, &deadlocks)
+	analysis.Sets = [][]int{}
+	inSet := make(map[int]bool)
+	for _, component := range stronglyConnectedComponents(graph) {
+		if len(component) > 1 || hasEdge(graph, component[0], component[0]) {
+			analysis.Sets = append(analysis.Sets, component)
+			for _, process := range component {
+				inSet[process] = true
+			}
+		}
+	}
+	analysis.Blocked = []int{}
+	for _, process := range analysis.Deadlocked {
+		if !inSet[process] {
+			analysis.Blocked = append(analysis.Blocked, process)
 		}
 	}
 
-	return deadlocks
+	analysis.Cycles = elementaryCycles(graph)
+	return analysis
 }
 
-// findDeadlockCycles is a helper function to find cycles in the wait-for graph
-func (d *DeadlockDetector) findDeadlockCycles(current int, graph map[int][]int, visited map[int]bool, path []int, cycles *[][]int) {
-	// Mark the current node as visited
-	visited[current] = true
-	path = append(path, current)
-
-	// Check all adjacent vertices
-	for _, neighbor := range graph[current] {
-		// Check if neighbor is already in path (cycle found)
-		for i, node := range path {
-			if node == neighbor {
-				// Found a cycle
-				cycle := append([]int{}, path[i:]...)
-				cycle = append(cycle, neighbor)
-				*cycles = append(*cycles, cycle)
-				fmt.Printf("▸▸▸ Cycle detected: ")
-				for _, p := range cycle[:len(cycle)-1] {
-					fmt.Printf("%d → ", p)
+// waitForGraph links each waiting process to every other process holding a
+// unit of a resource it waits for, and returns each edge with the first
+// such resource. Neighbors are sorted and listed once. The caller must hold
+// the mutex.
+func (d *DeadlockDetector) waitForGraph() (map[int][]int, []WaitEdge) {
+	graph := make(map[int][]int)
+	edges := []WaitEdge{}
+	for _, process := range sortedKeys(d.waitFor) {
+		linked := make(map[int]bool)
+		for _, resource := range d.waitFor[process] {
+			for _, holder := range sortedKeys(d.allocations) {
+				if holder == process || linked[holder] || indexOf(d.allocations[holder], resource) < 0 {
+					continue
 				}
-				fmt.Printf("%d\n", cycle[len(cycle)-1])
-				return
+				linked[holder] = true
+				graph[process] = append(graph[process], holder)
+				edges = append(edges, WaitEdge{Process: process, Holder: holder, Resource: resource})
+			}
+		}
+		sort.Ints(graph[process])
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Process != edges[j].Process {
+			return edges[i].Process < edges[j].Process
+		}
+		return edges[i].Holder < edges[j].Holder
+	})
+	return graph, edges
+}
+
+// deadlockedProcesses is the detection algorithm for resources with several
+// units: starting from the free units, repeatedly let a process whose
+// request fits finish and reclaim what it holds. Processes that never get
+// to finish are deadlocked.
+func deadlockedProcesses(available map[int]int, allocation, request map[int]map[int]int) []int {
+	work := make(map[int]int, len(available))
+	for resource, units := range available {
+		work[resource] = units
+	}
+	pending := make(map[int]bool)
+	for process := range allocation {
+		pending[process] = true
+	}
+	for process := range request {
+		pending[process] = true
+	}
+
+	for progressed := true; progressed; {
+		progressed = false
+		for process := range pending {
+			if !fits(request[process], work) {
+				continue
 			}
+			for resource, units := range allocation[process] {
+				work[resource] += units
+			}
+			delete(pending, process)
+			progressed = true
 		}
+	}
+
+	deadlocked := make([]int, 0, len(pending))
+	for process := range pending {
+		deadlocked = append(deadlocked, process)
+	}
+	sort.Ints(deadlocked)
+	return deadlocked
+}
 
-		// If neighbor hasn't been visited yet
-		if !visited[neighbor] {
-			d.findDeadlockCycles(neighbor, graph, visited, path, cycles)
+// countUnits turns per-process lists of resources into unit counts
+func countUnits(lists map[int][]int) map[int]map[int]int {
+	counts := make(map[int]map[int]int, len(lists))
+	for process, resources := range lists {
+		counts[process] = make(map[int]int)
+		for _, resource := range resources {
+			counts[process][resource]++
 		}
 	}
+	return counts
+}
+
+// sortedKeys returns the processes of a per-process map in ascending order
+func sortedKeys(lists map[int][]int) []int {
+	keys := make([]int, 0, len(lists))
+	for key := range lists {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// WaitEdge is the payload published for each edge of the wait-for graph
+type WaitEdge struct {
+	Process  int `json:"process"`
+	Holder   int `json:"holder"`
+	Resource int `json:"resource"`
+}
+
+// DeadlockEvent is the payload published with detected cycles
+type DeadlockEvent struct {
+	Deadlocks [][]int `json:"deadlocks"`
+}
+
+// formatCycle renders a cycle of process IDs joined by sep
+func formatCycle(cycle []int, sep string) string {
+	parts := make([]string, len(cycle))
+	for i, p := range cycle {
+		parts[i] = fmt.Sprint(p)
+	}
+	return strings.Join(parts, sep)
 }
 
+// PrintDeadlocks runs detection, publishes the results to the event bus and
+// applies the recovery policy to any deadlocks found. Analyze and Graphs
+// return the same results without publishing them.
 func (d *DeadlockDetector) PrintDeadlocks() {
-	fmt.Println("▸▸▸ DEADLOCK DETECTION CHECK ▸▸▸")
-	deadlocks := d.DetectDeadlocks()
+	events.Publish(events.DeadlockCheck, "▸▸▸ DEADLOCK DETECTION CHECK ▸▸▸", nil)
+	d.mutex.Lock()
+	analysis := d.analyze()
+	for _, edge := range analysis.Edges {
+		events.Publishf(events.DeadlockCheck, edge, "▸▸▸ Process %d waits for Process %d (which holds %s)",
+			edge.Process, edge.Holder, d.describeResource(edge.Resource))
+	}
+	d.mutex.Unlock()
+	deadlocks := analysis.Cycles
+	for _, cycle := range deadlocks {
+		events.Publishf(events.DeadlockCheck, DeadlockEvent{Deadlocks: [][]int{cycle}},
+			"▸▸▸ Cycle detected: %s", formatCycle(cycle, " → "))
+	}
 
 	if len(deadlocks) == 0 {
-		fmt.Println("▸▸▸ No deadlocks detected in the system ▸▸▸")
+		events.Publish(events.DeadlockCheck, "▸▸▸ No deadlocks detected in the system ▸▸▸", DeadlockEvent{Deadlocks: deadlocks})
 		return
 	}
 
-	fmt.Printf("▸▸▸ ALERT: Detected %d deadlocks in the system! ▸▸▸\n", len(deadlocks))
+	lines := []string{fmt.Sprintf("▸▸▸ ALERT: Detected %d deadlocks in the system! ▸▸▸", len(deadlocks))}
 	for i, cycle := range deadlocks {
-		fmt.Printf("▸▸▸ Deadlock #%d: Process", i+1)
-		for _, process := range cycle[:len(cycle)-1] {
-			fmt.Printf(" %d →", process)
-		}
-		fmt.Printf(" %d\n", cycle[len(cycle)-1])
+		lines = append(lines, fmt.Sprintf("▸▸▸ Deadlock #%d: Process %s", i+1, formatCycle(cycle, " → ")))
+	}
+	for i, set := range analysis.Sets {
+		lines = append(lines, fmt.Sprintf("▸▸▸ Deadlocked set #%d: Processes %s", i+1, formatCycle(set, ", ")))
+	}
+	if len(analysis.Blocked) > 0 {
+		lines = append(lines, fmt.Sprintf("▸▸▸ Blocked behind them: Processes %s", formatCycle(analysis.Blocked, ", ")))
 	}
-	fmt.Println("▸▸▸ END DEADLOCK DETECTION ▸▸▸")
+	lines = append(lines, "▸▸▸ END DEADLOCK DETECTION ▸▸▸")
+	events.Publish(events.DeadlockAlert, strings.Join(lines, "\n"), DeadlockEvent{Deadlocks: deadlocks})
+	d.Recover(deadlocks)
 }
@@ -0,0 +1,266 @@
+package miner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kinds of graph nodes
+const (
+	NodeProcess  = "process"
+	NodeResource = "resource"
+)
+
+// Kinds of graph edges
+const (
+	EdgeRequest    = "request"    // Process to a resource it waits for
+	EdgeAssignment = "assignment" // Resource to a process holding it
+	EdgeWait       = "wait"       // Process to a process holding what it waits for
+)
+
+// GraphNode is a process or resource in an exported graph
+type GraphNode struct {
+	ID         string `json:"id"` // "P" or "R" followed by the process or resource ID
+	Kind       string `json:"kind"`
+	Number     int    `json:"number"`
+	Label      string `json:"label"`
+	Instances  int    `json:"instances,omitempty"` // Resources only
+	Deadlocked bool   `json:"deadlocked"`
+	InCycle    bool   `json:"inCycle"`
+}
+
+// GraphEdge is an edge of an exported graph
+type GraphEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Kind     string `json:"kind"`
+	Units    int    `json:"units,omitempty"`    // Request and assignment edges
+	Resource int    `json:"resource,omitempty"` // Wait edges
+	InCycle  bool   `json:"inCycle"`
+}
+
+// Graph is a graph of the detector's state, ready to be drawn
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// DeadlockGraphs is the detector's state as a resource-allocation graph and
+// as the wait-for graph derived from it, with the nodes and edges of every
+// cycle marked
+type DeadlockGraphs struct {
+	ResourceAllocation Graph   `json:"resourceAllocation"`
+	WaitFor            Graph   `json:"waitFor"`
+	Cycles             [][]int `json:"cycles"`
+	Deadlocked         []int   `json:"deadlocked"`
+}
+
+// processNode and resourceNode return the node IDs used in exported graphs
+func processNode(process int) string   { return fmt.Sprintf("P%d", process) }
+func resourceNode(resource int) string { return fmt.Sprintf("R%d", resource) }
+
+// Graphs runs detection and exports the current state as graphs
+func (d *DeadlockDetector) Graphs() DeadlockGraphs {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	analysis := d.analyze()
+	deadlocked := make(map[int]bool, len(analysis.Deadlocked))
+	for _, process := range analysis.Deadlocked {
+		deadlocked[process] = true
+	}
+
+	// Mark each cycle's processes, the wait edges between them and the
+	// resources behind those edges
+	behind := make(map[[2]int]int, len(analysis.Edges))
+	for _, edge := range analysis.Edges {
+		behind[[2]int{edge.Process, edge.Holder}] = edge.Resource
+	}
+	cycleProcesses := make(map[int]bool)
+	cycleWaits := make(map[[2]int]bool)
+	cycleResources := make(map[int]bool)
+	cycleRequests := make(map[[2]int]bool)    // Process, resource
+	cycleAssignments := make(map[[2]int]bool) // Resource, process
+	for _, cycle := range analysis.Cycles {
+		for i := 0; i < len(cycle)-1; i++ {
+			waiter, holder := cycle[i], cycle[i+1]
+			resource := behind[[2]int{waiter, holder}]
+			cycleProcesses[waiter] = true
+			cycleWaits[[2]int{waiter, holder}] = true
+			cycleResources[resource] = true
+			cycleRequests[[2]int{waiter, resource}] = true
+			cycleAssignments[[2]int{resource, holder}] = true
+		}
+	}
+
+	processes := make(map[int]bool)
+	resources := make(map[int]bool)
+	for resource := range analysis.Available {
+		resources[resource] = true
+	}
+	for _, counts := range []map[int]map[int]int{analysis.Allocation, analysis.Request} {
+		for process, units := range counts {
+			processes[process] = true
+			for resource := range units {
+				resources[resource] = true
+			}
+		}
+	}
+
+	processNodes := []GraphNode{}
+	for _, process := range sortedSet(processes) {
+		label := processNode(process)
+		if p, ok := d.processes[process]; ok {
+			label += " " + p.name
+		}
+		processNodes = append(processNodes, GraphNode{
+			ID:         processNode(process),
+			Kind:       NodeProcess,
+			Number:     process,
+			Label:      label,
+			Deadlocked: deadlocked[process],
+			InCycle:    cycleProcesses[process],
+		})
+	}
+	resourceNodes := []GraphNode{}
+	for _, resource := range sortedSet(resources) {
+		label := resourceNode(resource)
+		if name, ok := d.resourceName(resource); ok {
+			label += " " + name
+		}
+		resourceNodes = append(resourceNodes, GraphNode{
+			ID:        resourceNode(resource),
+			Kind:      NodeResource,
+			Number:    resource,
+			Label:     label,
+			Instances: d.instancesOf(resource),
+			InCycle:   cycleResources[resource],
+		})
+	}
+
+	allocation := Graph{Nodes: append(append([]GraphNode{}, processNodes...), resourceNodes...), Edges: []GraphEdge{}}
+	for _, process := range sortedProcesses(analysis.Request) {
+		for _, resource := range sortedResources(analysis.Request[process]) {
+			allocation.Edges = append(allocation.Edges, GraphEdge{
+				From:    processNode(process),
+				To:      resourceNode(resource),
+				Kind:    EdgeRequest,
+				Units:   analysis.Request[process][resource],
+				InCycle: cycleRequests[[2]int{process, resource}],
+			})
+		}
+	}
+	for _, process := range sortedProcesses(analysis.Allocation) {
+		for _, resource := range sortedResources(analysis.Allocation[process]) {
+			allocation.Edges = append(allocation.Edges, GraphEdge{
+				From:    resourceNode(resource),
+				To:      processNode(process),
+				Kind:    EdgeAssignment,
+				Units:   analysis.Allocation[process][resource],
+				InCycle: cycleAssignments[[2]int{resource, process}],
+			})
+		}
+	}
+
+	waitFor := Graph{Nodes: processNodes, Edges: []GraphEdge{}}
+	for _, edge := range analysis.Edges {
+		waitFor.Edges = append(waitFor.Edges, GraphEdge{
+			From:     processNode(edge.Process),
+			To:       processNode(edge.Holder),
+			Kind:     EdgeWait,
+			Resource: edge.Resource,
+			InCycle:  cycleWaits[[2]int{edge.Process, edge.Holder}],
+		})
+	}
+
+	return DeadlockGraphs{
+		ResourceAllocation: allocation,
+		WaitFor:            waitFor,
+		Cycles:             analysis.Cycles,
+		Deadlocked:         analysis.Deadlocked,
+	}
+}
+
+// DOT renders the graph in Graphviz DOT. Processes are circles and
+// resources boxes; cycles are drawn in red and other deadlocked processes
+// in orange.
+func (g Graph) DOT(name string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(name))
+	b.WriteString("  rankdir=LR;\n")
+	for _, node := range g.Nodes {
+		attributes := []string{"label=" + dotQuote(node.Label)}
+		if node.Kind == NodeResource {
+			attributes = append(attributes, "shape=box")
+			if node.Instances > 1 {
+				attributes[0] = "label=" + dotQuote(fmt.Sprintf("%s (%d instances)", node.Label, node.Instances))
+			}
+		} else {
+			attributes = append(attributes, "shape=circle")
+		}
+		if node.InCycle {
+			attributes = append(attributes, "color=red", "fontcolor=red", "penwidth=2")
+		} else if node.Deadlocked {
+			attributes = append(attributes, "color=orange")
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(node.ID), strings.Join(attributes, ", "))
+	}
+	for _, edge := range g.Edges {
+		attributes := []string{}
+		switch {
+		case edge.Kind == EdgeRequest:
+			attributes = append(attributes, "style=dashed")
+		case edge.Kind == EdgeWait && edge.Resource != 0:
+			attributes = append(attributes, "label="+dotQuote(resourceNode(edge.Resource)))
+		}
+		if edge.Units > 1 {
+			attributes = append(attributes, "label="+dotQuote(fmt.Sprint(edge.Units)))
+		}
+		if edge.InCycle {
+			attributes = append(attributes, "color=red", "penwidth=2")
+		}
+		fmt.Fprintf(&b, "  %s -> %s", dotQuote(edge.From), dotQuote(edge.To))
+		if len(attributes) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attributes, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotQuote quotes s as a DOT string
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// sortedSet returns the members of a set in ascending order
+func sortedSet(set map[int]bool) []int {
+	members := make([]int, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	sort.Ints(members)
+	return members
+}
+
+// sortedProcesses returns the processes of a per-process count map in ascending order
+func sortedProcesses(counts map[int]map[int]int) []int {
+	processes := make([]int, 0, len(counts))
+	for process := range counts {
+		processes = append(processes, process)
+	}
+	sort.Ints(processes)
+	return processes
+}
+
+// sortedResources returns the resources of a count map in ascending order
+func sortedResources(units map[int]int) []int {
+	resources := make([]int, 0, len(units))
+	for resource := range units {
+		resources = append(resources, resource)
+	}
+	sort.Ints(resources)
+	return resources
+}
@@ -0,0 +1,209 @@
+package miner
+
+import (
+	"blockchain-visualizer/locks"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// waitForEachOther stages single-instance resources where process p holds
+// resource p, and each edge p → q makes p wait for q's resource
+func waitForEachOther(edges [][2]int) *DeadlockDetector {
+	d := NewDeadlockDetector()
+	held := make(map[int]bool)
+	for _, edge := range edges {
+		for _, p := range edge {
+			if !held[p] {
+				d.AddAllocation(p, p)
+				held[p] = true
+			}
+		}
+		d.AddWaitFor(edge[0], edge[1])
+	}
+	return d
+}
+
+func TestDetectsOverlappingCycles(t *testing.T) {
+	// Two cycles sharing process 2, which the old depth-first search
+	// stopped at after finding the first
+	d := waitForEachOther([][2]int{{1, 2}, {2, 1}, {2, 3}, {3, 2}})
+	analysis := d.Analyze()
+
+	if want := [][]int{{1, 2, 1}, {2, 3, 2}}; !reflect.DeepEqual(analysis.Cycles, want) {
+		t.Errorf("cycles %v, want %v", analysis.Cycles, want)
+	}
+	if want := [][]int{{1, 2, 3}}; !reflect.DeepEqual(analysis.Sets, want) {
+		t.Errorf("sets %v, want %v", analysis.Sets, want)
+	}
+}
+
+func TestDetectsNestedCycles(t *testing.T) {
+	// 1 → 2 → 1 lies inside 1 → 2 → 3 → 1
+	d := waitForEachOther([][2]int{{1, 2}, {2, 3}, {3, 1}, {2, 1}})
+
+	if got, want := d.DetectDeadlocks(), [][]int{{1, 2, 1}, {1, 2, 3, 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cycles %v, want %v", got, want)
+	}
+}
+
+func TestDetectsEveryCycleOfACompleteGraph(t *testing.T) {
+	// Three processes all waiting on each other have three 2-cycles and two 3-cycles
+	d := waitForEachOther([][2]int{{1, 2}, {1, 3}, {2, 1}, {2, 3}, {3, 1}, {3, 2}})
+
+	if got := d.DetectDeadlocks(); len(got) != 5 {
+		t.Errorf("found %d cycles %v, want 5", len(got), got)
+	}
+}
+
+func TestReportsSeparateDeadlockedSets(t *testing.T) {
+	// Two independent deadlocks, and process 5 stuck behind the first
+	d := waitForEachOther([][2]int{{1, 2}, {2, 1}, {3, 4}, {4, 3}, {5, 1}})
+	analysis := d.Analyze()
+
+	if want := [][]int{{1, 2}, {3, 4}}; !reflect.DeepEqual(analysis.Sets, want) {
+		t.Errorf("sets %v, want %v", analysis.Sets, want)
+	}
+	if want := []int{5}; !reflect.DeepEqual(analysis.Blocked, want) {
+		t.Errorf("blocked %v, want %v", analysis.Blocked, want)
+	}
+	if want := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(analysis.Deadlocked, want) {
+		t.Errorf("deadlocked %v, want %v", analysis.Deadlocked, want)
+	}
+}
+
+func TestCycleThroughMultiInstanceResourcesIsNotDeadlock(t *testing.T) {
+	// Processes 1 and 3 wait on each other, but 2 and 4 hold the other
+	// instances and can finish, which frees enough for both
+	d := NewDeadlockDetector()
+	d.AddResource(Resource{ID: 1, Instances: 2})
+	d.AddResource(Resource{ID: 2, Instances: 2})
+	d.AddAllocation(1, 2)
+	d.AddWaitFor(1, 1)
+	d.AddAllocation(2, 1)
+	d.AddAllocation(3, 1)
+	d.AddWaitFor(3, 2)
+	d.AddAllocation(4, 2)
+	analysis := d.Analyze()
+
+	if len(analysis.Deadlocked) != 0 || len(analysis.Cycles) != 0 {
+		t.Errorf("deadlocked %v with cycles %v, want none", analysis.Deadlocked, analysis.Cycles)
+	}
+	if !hasEdge(analysis.WaitFor, 1, 3) || !hasEdge(analysis.WaitFor, 3, 1) {
+		t.Errorf("wait-for graph %v is missing the cycle between 1 and 3", analysis.WaitFor)
+	}
+}
+
+// stageMatrix stages unit counts per process for resources 1, 2 and 3
+func stageMatrix(d *DeadlockDetector, allocation, request [][3]int) {
+	for process := range allocation {
+		for resource := 0; resource < 3; resource++ {
+			for i := 0; i < allocation[process][resource]; i++ {
+				d.AddAllocation(process+1, resource+1)
+			}
+			for i := 0; i < request[process][resource]; i++ {
+				d.AddWaitFor(process+1, resource+1)
+			}
+		}
+	}
+}
+
+func TestMatrixDetection(t *testing.T) {
+	// The detection example from Silberschatz, Galvin and Gagne, with
+	// processes numbered from 1
+	allocation := [][3]int{{0, 1, 0}, {2, 0, 0}, {3, 0, 3}, {2, 1, 1}, {0, 0, 2}}
+	request := [][3]int{{0, 0, 0}, {2, 0, 2}, {0, 0, 0}, {1, 0, 0}, {0, 0, 2}}
+	newDetector := func() *DeadlockDetector {
+		d := NewDeadlockDetector()
+		d.AddResource(Resource{ID: 1, Instances: 7})
+		d.AddResource(Resource{ID: 2, Instances: 2})
+		d.AddResource(Resource{ID: 3, Instances: 6})
+		return d
+	}
+
+	d := newDetector()
+	stageMatrix(d, allocation, request)
+	if analysis := d.Analyze(); len(analysis.Deadlocked) != 0 {
+		t.Fatalf("deadlocked %v, want none", analysis.Deadlocked)
+	}
+
+	// One more instance of the third resource for process 3 deadlocks
+	// everyone but process 1, which waits for nothing
+	request[2][2] = 1
+	d = newDetector()
+	stageMatrix(d, allocation, request)
+	analysis := d.Analyze()
+	if want := []int{2, 3, 4, 5}; !reflect.DeepEqual(analysis.Deadlocked, want) {
+		t.Errorf("deadlocked %v, want %v", analysis.Deadlocked, want)
+	}
+	if want := map[int]int{1: 0, 2: 0, 3: 0}; !reflect.DeepEqual(analysis.Available, want) {
+		t.Errorf("available %v, want %v", analysis.Available, want)
+	}
+}
+
+func TestReleasedWaitsLeaveTheGraph(t *testing.T) {
+	d := waitForEachOther([][2]int{{1, 2}, {2, 1}})
+	d.Abandoned(2, 1)
+
+	if got := d.DetectDeadlocks(); len(got) != 0 {
+		t.Errorf("cycles %v after the wait was abandoned, want none", got)
+	}
+}
+
+func TestGraphsMarkCycles(t *testing.T) {
+	// Processes 1 and 2 wait on each other and 3 waits on 1 from outside the cycle
+	d := waitForEachOther([][2]int{{1, 2}, {2, 1}, {3, 1}})
+	d.AddResource(Resource{ID: 1, Instances: 1, Name: "ledger"})
+	graphs := d.Graphs()
+
+	inCycle := make(map[string]bool)
+	for _, node := range graphs.ResourceAllocation.Nodes {
+		inCycle[node.ID] = node.InCycle
+	}
+	if want := map[string]bool{"P1": true, "P2": true, "P3": false, "R1": true, "R2": true, "R3": false}; !reflect.DeepEqual(inCycle, want) {
+		t.Errorf("nodes in cycle %v, want %v", inCycle, want)
+	}
+
+	cycleEdges := []string{}
+	for _, edge := range graphs.ResourceAllocation.Edges {
+		if edge.InCycle {
+			cycleEdges = append(cycleEdges, edge.From+"→"+edge.To)
+		}
+	}
+	if want := []string{"P1→R2", "P2→R1", "R1→P1", "R2→P2"}; !reflect.DeepEqual(cycleEdges, want) {
+		t.Errorf("edges in cycle %v, want %v", cycleEdges, want)
+	}
+	if n := len(graphs.WaitFor.Edges); n != 3 {
+		t.Errorf("wait-for graph has %d edges, want 3", n)
+	}
+
+	dot := graphs.ResourceAllocation.DOT("scenario")
+	for _, line := range []string{`"R1" [label="R1 ledger", shape=box, color=red`, `"P3" -> "R1" [style=dashed];`} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("DOT output is missing %s:\n%s", line, dot)
+		}
+	}
+}
+
+func TestOnlyTheTrackingDetectorNamesLocks(t *testing.T) {
+	live := NewDeadlockDetector()
+	locks.SetTracker(live)
+	defer locks.SetTracker(nil)
+	lock := locks.NewMutex("blockchain")
+
+	// A scenario reusing the lock's ID is a different resource
+	scenario := NewDeadlockDetector()
+	for _, test := range []struct {
+		detector *DeadlockDetector
+		want     string
+	}{
+		{live, resourceNode(lock.ID()) + " blockchain"},
+		{scenario, resourceNode(lock.ID())},
+	} {
+		test.detector.AddAllocation(1, lock.ID())
+		nodes := test.detector.Graphs().ResourceAllocation.Nodes
+		if len(nodes) != 2 || nodes[1].Label != test.want {
+			t.Errorf("nodes %+v, want resource labelled %q", nodes, test.want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package miner
+
+import "sort"
+
+// graphNodes returns every node of graph, including those only reached by
+// an edge, in ascending order
+func graphNodes(graph map[int][]int) []int {
+	seen := make(map[int]bool)
+	for node, neighbors := range graph {
+		seen[node] = true
+		for _, neighbor := range neighbors {
+			seen[neighbor] = true
+		}
+	}
+	nodes := make([]int, 0, len(seen))
+	for node := range seen {
+		nodes = append(nodes, node)
+	}
+	sort.Ints(nodes)
+	return nodes
+}
+
+// inducedSubgraph keeps the edges of graph between nodes for which keep is true
+func inducedSubgraph(graph map[int][]int, keep func(int) bool) map[int][]int {
+	subgraph := make(map[int][]int)
+	for node, neighbors := range graph {
+		if !keep(node) {
+			continue
+		}
+		for _, neighbor := range neighbors {
+			if keep(neighbor) {
+				subgraph[node] = append(subgraph[node], neighbor)
+			}
+		}
+	}
+	return subgraph
+}
+
+// tarjan holds the state of Tarjan's strongly connected components algorithm
+type tarjan struct {
+	graph      map[int][]int
+	index      map[int]int
+	lowlink    map[int]int
+	onStack    map[int]bool
+	stack      []int
+	next       int
+	components [][]int
+}
+
+// stronglyConnectedComponents returns the strongly connected components of
+// graph using Tarjan's algorithm. Each component is sorted, and components
+// are ordered by their smallest node.
+func stronglyConnectedComponents(graph map[int][]int) [][]int {
+	t := &tarjan{
+		graph:   graph,
+		index:   make(map[int]int),
+		lowlink: make(map[int]int),
+		onStack: make(map[int]bool),
+	}
+	for _, node := range graphNodes(graph) {
+		if _, visited := t.index[node]; !visited {
+			t.connect(node)
+		}
+	}
+
+	for _, component := range t.components {
+		sort.Ints(component)
+	}
+	sort.Slice(t.components, func(i, j int) bool {
+		return t.components[i][0] < t.components[j][0]
+	})
+	return t.components
+}
+
+// connect visits node and pops its component once node is found to be its root
+func (t *tarjan) connect(node int) {
+	t.index[node] = t.next
+	t.lowlink[node] = t.next
+	t.next++
+	t.stack = append(t.stack, node)
+	t.onStack[node] = true
+
+	for _, neighbor := range t.graph[node] {
+		if _, visited := t.index[neighbor]; !visited {
+			t.connect(neighbor)
+			if t.lowlink[neighbor] < t.lowlink[node] {
+				t.lowlink[node] = t.lowlink[neighbor]
+			}
+		} else if t.onStack[neighbor] && t.index[neighbor] < t.lowlink[node] {
+			t.lowlink[node] = t.index[neighbor]
+		}
+	}
+
+	if t.lowlink[node] != t.index[node] {
+		return
+	}
+	component := []int{}
+	for {
+		top := t.stack[len(t.stack)-1]
+		t.stack = t.stack[:len(t.stack)-1]
+		t.onStack[top] = false
+		component = append(component, top)
+		if top == node {
+			break
+		}
+	}
+	t.components = append(t.components, component)
+}
+
+// johnson holds the state of Johnson's elementary circuit search from one start node
+type johnson struct {
+	graph    map[int][]int
+	start    int
+	blocked  map[int]bool
+	blockers map[int]map[int]bool // Node to the nodes to unblock along with it
+	path     []int
+	cycles   [][]int
+}
+
+// elementaryCycles returns every elementary cycle of graph using Johnson's
+// algorithm. Each cycle starts at its smallest node and repeats it at the
+// end, and cycles are ordered by their start node and then by the order
+// they were found in.
+func elementaryCycles(graph map[int][]int) [][]int {
+	cycles := [][]int{}
+	for _, start := range graphNodes(graph) {
+		// Only look for cycles whose smallest node is start, within its
+		// component of the graph left after removing smaller nodes
+		remaining := inducedSubgraph(graph, func(node int) bool { return node >= start })
+		var component []int
+		for _, c := range stronglyConnectedComponents(remaining) {
+			if c[0] == start {
+				component = c
+				break
+			}
+		}
+		if len(component) < 2 && !hasEdge(graph, start, start) {
+			continue
+		}
+		members := make(map[int]bool, len(component))
+		for _, node := range component {
+			members[node] = true
+		}
+
+		j := &johnson{
+			graph:    inducedSubgraph(remaining, func(node int) bool { return members[node] }),
+			start:    start,
+			blocked:  make(map[int]bool),
+			blockers: make(map[int]map[int]bool),
+		}
+		j.circuit(start)
+		cycles = append(cycles, j.cycles...)
+	}
+	return cycles
+}
+
+// circuit extends the path through node and reports whether a cycle back
+// to the start was found through it
+func (j *johnson) circuit(node int) bool {
+	found := false
+	j.path = append(j.path, node)
+	j.blocked[node] = true
+
+	for _, neighbor := range j.graph[node] {
+		if neighbor == j.start {
+			cycle := append(append([]int{}, j.path...), j.start)
+			j.cycles = append(j.cycles, cycle)
+			found = true
+		} else if !j.blocked[neighbor] && j.circuit(neighbor) {
+			found = true
+		}
+	}
+
+	if found {
+		j.unblock(node)
+	} else {
+		for _, neighbor := range j.graph[node] {
+			if j.blockers[neighbor] == nil {
+				j.blockers[neighbor] = make(map[int]bool)
+			}
+			j.blockers[neighbor][node] = true
+		}
+	}
+	j.path = j.path[:len(j.path)-1]
+	return found
+}
+
+// unblock frees node, and the nodes blocked on it, to be visited again
+func (j *johnson) unblock(node int) {
+	j.blocked[node] = false
+	for blocker := range j.blockers[node] {
+		delete(j.blockers[node], blocker)
+		if j.blocked[blocker] {
+			j.unblock(blocker)
+		}
+	}
+}
+
+// hasEdge reports whether graph has an edge from one node to another
+func hasEdge(graph map[int][]int, from, to int) bool {
+	for _, neighbor := range graph[from] {
+		if neighbor == to {
+			return true
+		}
+	}
+	return false
+}
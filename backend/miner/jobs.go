@@ -0,0 +1,136 @@
+package miner
+
+import (
+	bc "blockchain-visualizer/blockchain"
+	"blockchain-visualizer/events"
+	"blockchain-visualizer/locks"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrJobRunning is returned when a mining job is submitted while another is running
+var ErrJobRunning = errors.New("a mining job is already running")
+
+// JobStatus is the state of an asynchronous mining job
+type JobStatus string
+
+const (
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// maxJobHistory is how many finished jobs are remembered
+const maxJobHistory = 100
+
+// Job is one StartMining round run in the background
+type Job struct {
+	ID           string    `json:"id"`
+	Status       JobStatus `json:"status"`
+	Miners       int       `json:"miners"`
+	MinerAddress string    `json:"minerAddress"`
+	SubmittedAt  int64     `json:"submittedAt"`
+	FinishedAt   int64     `json:"finishedAt,omitempty"`
+	Block        *bc.Block `json:"block,omitempty"`
+	OnMainChain  bool      `json:"onMainChain,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Jobs runs mining rounds in the background and remembers their outcome
+type Jobs struct {
+	blockchain *bc.Blockchain
+	jobs       map[string]*Job
+	finished   []string // IDs of finished jobs, oldest first
+	running    string   // ID of the running job, empty when idle
+	nextID     int
+	mutex      *locks.Mutex
+}
+
+// NewJobs creates an empty job registry for blockchain
+func NewJobs(blockchain *bc.Blockchain) *Jobs {
+	return &Jobs{
+		blockchain: blockchain,
+		jobs:       make(map[string]*Job),
+		mutex:      locks.NewMutex("mining jobs"),
+	}
+}
+
+// Submit starts mining a block paying minerAddress with numMiners and
+// returns the job without waiting for it. Every job can keep numMiners
+// workers hashing for up to MiningTimeout, so only one runs at a time and
+// a job submitted meanwhile is refused with ErrJobRunning. Chains that are
+// not secured by proof of work are refused with bc.ErrWrongConsensus.
+func (j *Jobs) Submit(minerAddress string, numMiners int) (Job, error) {
+	if j.blockchain.Params.Engine().Name() != bc.ConsensusPoW {
+		return Job{}, bc.ErrWrongConsensus
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.running != "" {
+		return Job{}, fmt.Errorf("%w: %s", ErrJobRunning, j.running)
+	}
+	j.nextID++
+	job := &Job{
+		ID:           fmt.Sprintf("job-%d", j.nextID),
+		Status:       JobRunning,
+		Miners:       numMiners,
+		MinerAddress: minerAddress,
+		SubmittedAt:  time.Now().Unix(),
+	}
+	j.jobs[job.ID] = job
+	j.running = job.ID
+	go j.run(job)
+
+	return *job, nil
+}
+
+// Get returns the job with the given ID
+func (j *Jobs) Get(id string) (Job, bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	job, ok := j.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// run mines the job's block and records the outcome
+func (j *Jobs) run(job *Job) {
+	p := attach("mining job " + job.ID)
+	defer p.detach()
+
+	block, err := startMining(p, j.blockchain, job.MinerAddress, job.Miners)
+	onMainChain := err == nil && j.blockchain.IsOnMainChain(block.Hash)
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.running = ""
+	job.FinishedAt = time.Now().Unix()
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = JobDone
+		job.Block = block
+		job.OnMainChain = onMainChain
+	}
+
+	// Forget the oldest finished jobs once the history is full
+	j.finished = append(j.finished, job.ID)
+	for len(j.finished) > maxJobHistory {
+		delete(j.jobs, j.finished[0])
+		j.finished = j.finished[1:]
+	}
+
+	if err != nil {
+		events.Publishf(events.MiningJob, *job, "▶ Mining job %s failed: %v", job.ID, err)
+	} else {
+		events.Publishf(events.MiningJob, *job, "▶ Mining job %s finished with block %d", job.ID, block.Index)
+	}
+}
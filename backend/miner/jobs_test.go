@@ -0,0 +1,48 @@
+package miner
+
+import (
+	bc "blockchain-visualizer/blockchain"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitForJob polls until the job with the given ID is no longer running
+func waitForJob(t *testing.T, jobs *Jobs, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(MiningTimeout + 5*time.Second)
+	for {
+		job, ok := jobs.Get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.Status != JobRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still running", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobsRunOneAtATime(t *testing.T) {
+	jobs := NewJobs(bc.NewBlockchain())
+
+	first, err := jobs.Submit("miner", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jobs.Submit("miner", 2); !errors.Is(err, ErrJobRunning) {
+		t.Fatalf("second job submitted with %v while the first was running, want %v", err, ErrJobRunning)
+	}
+
+	if job := waitForJob(t, jobs, first.ID); job.Status != JobDone {
+		t.Fatalf("first job %+v, want it done", job)
+	}
+	next, err := jobs.Submit("miner", 2)
+	if err != nil {
+		t.Fatalf("job refused after the first finished: %v", err)
+	}
+	waitForJob(t, jobs, next.ID)
+}
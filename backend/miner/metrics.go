@@ -0,0 +1,86 @@
+package miner
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// WriteMetrics writes the mining telemetry in the Prometheus text exposition format
+func WriteMetrics(w io.Writer) {
+	stats := Stats()
+
+	metric(w, "miner_num_cpu", "gauge", "Logical CPUs available to the miners.")
+	sample(w, "miner_num_cpu", "", float64(stats.NumCPU))
+
+	metric(w, "miner_hashes_total", "counter", "Hashes computed by each miner slot.")
+	for _, worker := range stats.Workers {
+		sample(w, "miner_hashes_total", minerLabel(worker.MinerID), float64(worker.Hashes))
+	}
+	metric(w, "miner_hash_rate", "gauge", "Hashes per second of each miner slot over its last round.")
+	for _, worker := range stats.Workers {
+		sample(w, "miner_hash_rate", minerLabel(worker.MinerID), worker.HashesPerSecond)
+	}
+	metric(w, "miner_blocks_found_total", "counter", "Blocks solved by each miner slot.")
+	for _, worker := range stats.Workers {
+		sample(w, "miner_blocks_found_total", minerLabel(worker.MinerID), float64(worker.BlocksFound))
+	}
+	metric(w, "miner_wasted_hashes_total", "counter", "Estimated hashes each miner slot computed after being told to stop.")
+	for _, worker := range stats.Workers {
+		sample(w, "miner_wasted_hashes_total", minerLabel(worker.MinerID), float64(worker.WastedHashes))
+	}
+
+	metric(w, "miner_rounds_total", "counter", "Mining rounds by outcome.")
+	outcomes := make([]string, 0, len(stats.Outcomes))
+	for outcome := range stats.Outcomes {
+		outcomes = append(outcomes, outcome)
+	}
+	sort.Strings(outcomes)
+	for _, outcome := range outcomes {
+		sample(w, "miner_rounds_total", fmt.Sprintf(`outcome=%q`, outcome), float64(stats.Outcomes[outcome]))
+	}
+
+	metric(w, "miner_time_to_solution_seconds", "summary", "Time from starting a round to solving its block.")
+	sample(w, "miner_time_to_solution_seconds_sum", "", stats.AverageTimeToSolutionMs*float64(stats.Solved)/1000)
+	sample(w, "miner_time_to_solution_seconds_count", "", float64(stats.Solved))
+
+	metric(w, "miner_scaling_hash_rate", "gauge", "Aggregate hashes per second of rounds mined with the given number of miners.")
+	for _, scaling := range stats.Scaling {
+		sample(w, "miner_scaling_hash_rate", fmt.Sprintf(`miners="%d"`, scaling.Miners), scaling.HashesPerSecond)
+	}
+	metric(w, "miner_scaling_speedup", "gauge", "Aggregate hash rate relative to a single miner.")
+	for _, scaling := range stats.Scaling {
+		if scaling.Speedup > 0 {
+			sample(w, "miner_scaling_speedup", fmt.Sprintf(`miners="%d"`, scaling.Miners), scaling.Speedup)
+		}
+	}
+
+	metric(w, "miner_shares_total", "counter", "Solved blocks by what became of them.")
+	sample(w, "miner_shares_total", `result="accepted"`, float64(stats.Shares.Accepted))
+	sample(w, "miner_shares_total", `result="side_branch"`, float64(stats.Shares.SideBranch))
+	sample(w, "miner_shares_total", `result="stale"`, float64(stats.Shares.Stale))
+	sample(w, "miner_shares_total", `result="rejected"`, float64(stats.Shares.Rejected))
+	metric(w, "miner_restarts_total", "counter", "Rounds abandoned because the chain tip moved.")
+	sample(w, "miner_restarts_total", "", float64(stats.Shares.Restarts))
+	metric(w, "miner_stale_hashes_total", "counter", "Hashes spent on templates that went stale.")
+	sample(w, "miner_stale_hashes_total", "", float64(stats.Shares.StaleHashes))
+}
+
+// metric writes the HELP and TYPE lines that introduce a metric family
+func metric(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one sample line, with labels given as `key="value"` pairs
+func sample(w io.Writer, name, labels string, value float64) {
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s %s\n", name, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// minerLabel labels a sample with a miner slot
+func minerLabel(minerID int) string {
+	return fmt.Sprintf(`miner="%d"`, minerID)
+}
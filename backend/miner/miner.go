@@ -2,86 +2,337 @@ package miner
 
 import (
 	bc "blockchain-visualizer/blockchain"
+	"blockchain-visualizer/events"
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Miner mines a new block
-func Miner(blockchain *bc.Blockchain, transactions []bc.Transaction, difficulty int,
-	wg *sync.WaitGroup, resultChan chan *bc.Block, stopChan <-chan struct{}, minerID int, doneChan chan<- int) {
+var (
+	// ErrMiningTimedOut is returned when no miner solves the block in time
+	ErrMiningTimedOut = errors.New("mining timed out")
+	// ErrMiningInterrupted is returned when a round is abandoned before a block is found
+	ErrMiningInterrupted = errors.New("mining interrupted")
+	// ErrStaleTemplate is returned when the tip moves while its template is being mined
+	ErrStaleTemplate = errors.New("chain tip changed during mining")
+	// ErrNoMiners is returned when a round is asked to run with fewer than one miner
+	ErrNoMiners = errors.New("at least one miner is required")
+)
+
+// MiningTimeout bounds a single StartMining round
+const MiningTimeout = 10 * time.Second
+
+// MaxNonce bounds the nonce space that is split between concurrent miners
+const MaxNonce int64 = 1 << 32
+
+// nonceSpace returns the size of the nonce space, which is MaxNonce unless
+// a block's int Nonce is too narrow for it, as on 32-bit platforms
+func nonceSpace() int {
+	space := MaxNonce
+	if space > math.MaxInt {
+		space = math.MaxInt
+	}
+	return int(space)
+}
+
+// NonceRange is the half-open slice [Start, End) of the nonce space searched by one miner
+type NonceRange struct {
+	Start int
+	End   int
+}
+
+// PartitionNonces splits the nonce space into numMiners disjoint ranges.
+// Fewer than one miner is treated as one.
+func PartitionNonces(numMiners int) []NonceRange {
+	if numMiners < 1 {
+		numMiners = 1
+	}
+	space := nonceSpace()
+	ranges := make([]NonceRange, numMiners)
+	size := space / numMiners
+	for i := 0; i < numMiners; i++ {
+		ranges[i] = NonceRange{Start: i * size, End: (i + 1) * size}
+	}
+	// Give any remainder to the last miner so the whole space is covered
+	ranges[numMiners-1].End = space
+	return ranges
+}
+
+// sampleInterval is how many hashes a miner computes between telemetry checks
+const sampleInterval = 50000
+
+// samplePeriod is the minimum time between hash-rate events from one miner
+const samplePeriod = 500 * time.Millisecond
+
+// MinerEvent is the payload published for per-miner events
+type MinerEvent struct {
+	MinerID         int     `json:"minerId"`
+	Nonce           int     `json:"nonce"`
+	ExtraNonce      int     `json:"extraNonce"`
+	RangeStart      int     `json:"rangeStart"`
+	RangeEnd        int     `json:"rangeEnd"`
+	Hashes          int     `json:"hashes"`
+	HashesPerSecond float64 `json:"hashesPerSecond,omitempty"`
+	BlockHash       string  `json:"blockHash,omitempty"`
+}
+
+// MiningEvent is the payload published when a mining round starts or finishes
+type MiningEvent struct {
+	Miners       int       `json:"miners"`
+	Difficulty   int       `json:"difficulty"`
+	Transactions int       `json:"transactions"`
+	Bytes        int       `json:"bytes"`
+	Fees         float64   `json:"fees"`
+	Block        *bc.Block `json:"block,omitempty"`
+	TimedOut     bool      `json:"timedOut,omitempty"`
+	Interrupted  bool      `json:"interrupted,omitempty"`
+	Stale        bool      `json:"stale,omitempty"`
+	Hashes       int       `json:"hashes,omitempty"`
+}
+
+// Miner mines its own copy of the template block with pow, searching only the
+// nonces in its assigned range. When it stops it reports how far it got on doneChan.
+func Miner(pow bc.ProofOfWork, template *bc.Block, wg *sync.WaitGroup, resultChan chan *bc.Block, stopChan <-chan struct{},
+	minerID int, nonces NonceRange, doneChan chan<- MinerEvent) {
 	defer wg.Done()
 
-	lastBlock := blockchain.GetLatestBlock()
-	newBlock := bc.NewBlock(lastBlock.Index+1, lastBlock.Hash, transactions)
+	newBlock := *template
+	newBlock.Nonce = nonces.Start
+	target := bc.TargetBytes(pow.Target(newBlock.Difficulty))
+	hasher := bc.NewNonceHasher(pow, &newBlock)
+
+	hashes := 0
+	lastSampleHashes := 0
+	lastSample := time.Now()
+	snapshot := func() MinerEvent {
+		return MinerEvent{
+			MinerID:    minerID,
+			Nonce:      newBlock.Nonce,
+			ExtraNonce: newBlock.ExtraNonce,
+			RangeStart: nonces.Start,
+			RangeEnd:   nonces.End,
+			Hashes:     hashes,
+		}
+	}
+
+	events.Publishf(events.MinerStarted, snapshot(), "◆ Miner %d started on nonces [%d, %d)",
+		minerID, nonces.Start, nonces.End)
 
 	// Mining loop
 	for {
 		// Check for stop signal
 		select {
 		case <-stopChan:
-			fmt.Printf("◆ Miner %d stopped\n", minerID)
-			doneChan <- minerID // Signal that this miner has stopped
+			stopped := snapshot()
+			events.Publishf(events.MinerStopped, stopped, "◆ Miner %d stopped", minerID)
+			doneChan <- stopped // Signal that this miner has stopped
 			return
 		default:
 			// Continue mining
 		}
 
 		// Try to mine the block
-		newBlock.Hash = newBlock.CalculateHash()
-		if newBlock.IsValidHash() {
-			fmt.Printf("◆ Miner %d found valid block with nonce: %d\n", minerID, newBlock.Nonce)
-			fmt.Println("")
-			resultChan <- newBlock
-			doneChan <- minerID // Signal that this miner has stopped
+		sum := hasher.Sum(newBlock.Nonce)
+		hashes++
+		if bc.MeetsTarget(sum, target) {
+			newBlock.Hash = hex.EncodeToString(sum)
+			found := snapshot()
+			found.BlockHash = newBlock.Hash
+			events.Publishf(events.WinnerFound, found, "◆ Miner %d found valid block with nonce: %d (extra nonce: %d)",
+				minerID, newBlock.Nonce, newBlock.ExtraNonce)
+			// Another miner may have won at the same time, so don't block on the result
+			select {
+			case resultChan <- &newBlock:
+			case <-stopChan:
+			}
+			stopped := snapshot()
+			stopped.BlockHash = newBlock.Hash
+			events.Publishf(events.MinerStopped, stopped, "◆ Miner %d stopped", minerID)
+			doneChan <- stopped // Signal that this miner has stopped
 			return
 		}
 
+		// Periodically publish hash rate and nonce progress
+		if hashes%sampleInterval == 0 {
+			if elapsed := time.Since(lastSample); elapsed >= samplePeriod {
+				sample := snapshot()
+				sample.HashesPerSecond = float64(hashes-lastSampleHashes) / elapsed.Seconds()
+				events.Publishf(events.HashRate, sample, "◆ Miner %d hashing at %.0f H/s", minerID, sample.HashesPerSecond)
+				events.Publishf(events.NonceProgress, sample, "◆ Miner %d at nonce %d", minerID, newBlock.Nonce)
+				lastSample = time.Now()
+				lastSampleHashes = hashes
+			}
+		}
+
 		newBlock.Nonce++
+		if newBlock.Nonce >= nonces.End {
+			// Range exhausted: roll the extra nonce and timestamp to get a fresh search space
+			newBlock.ExtraNonce++
+			newBlock.Timestamp = time.Now().Unix()
+			newBlock.Nonce = nonces.Start
+			hasher = bc.NewNonceHasher(pow, &newBlock)
+			events.Publishf(events.NonceProgress, snapshot(), "◆ Miner %d exhausted its nonce range, rolling extra nonce to %d",
+				minerID, newBlock.ExtraNonce)
+		}
 	}
 }
 
-// StartMining starts multiple miners concurrently
-func StartMining(blockchain *bc.Blockchain, transactions []bc.Transaction, difficulty int, numMiners int) *bc.Block {
-	fmt.Printf("▶ Started mining with %d concurrent miners\n", numMiners)
+// StartMining builds a block template paying minerAddress, mines it with
+// multiple concurrent miners each searching a disjoint nonce range, and adds
+// the solved block to the chain. Whenever the tip moves during mining the
+// round is restarted on a fresh template. It returns ErrMiningTimedOut if no
+// miner finds a block in time, or the chain's error if the block is rejected.
+func StartMining(blockchain *bc.Blockchain, minerAddress string, numMiners int) (*bc.Block, error) {
+	p := attach("mining round")
+	defer p.detach()
+	return startMining(p, blockchain, minerAddress, numMiners)
+}
+
+// startMining is StartMining run as the attached process p
+func startMining(p *attachedProcess, blockchain *bc.Blockchain, minerAddress string, numMiners int) (*bc.Block, error) {
+	if blockchain.Params.Engine().Name() != bc.ConsensusPoW {
+		return nil, bc.ErrWrongConsensus
+	}
+	if numMiners < 1 {
+		return nil, ErrNoMiners
+	}
+	deadline := time.Now().Add(MiningTimeout)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, ErrMiningTimedOut
+		}
+
+		template, err := newTemplate(p, blockchain, minerAddress)
+		if err != nil {
+			return nil, err
+		}
+		block, err := mineTemplate(p, blockchain, template, numMiners, remaining, nil)
+		// Keep the transactions reserved until the block is accepted or abandoned
+		blockchain.ReleaseTemplate(template)
+		if !errors.Is(err, ErrStaleTemplate) {
+			return block, err
+		}
+	}
+}
+
+// MineTemplate mines template with numMiners concurrent miners and adds the
+// solved block to the chain. The round is aborted with ErrStaleTemplate as
+// soon as the tip moves, since the block could then only join a side branch;
+// a solution found after that is discarded as a stale share. A zero timeout
+// mines until a block is found or interrupt is closed, in which case it
+// returns ErrMiningInterrupted. The caller owns the template and must
+// release it.
+func MineTemplate(blockchain *bc.Blockchain, template *bc.BlockTemplate, numMiners int,
+	timeout time.Duration, interrupt <-chan struct{}) (*bc.Block, error) {
+	p := attach("mining round")
+	defer p.detach()
+	return mineTemplate(p, blockchain, template, numMiners, timeout, interrupt)
+}
+
+// newTemplate builds a block template for the attached process p
+func newTemplate(p *attachedProcess, blockchain *bc.Blockchain, minerAddress string) (*bc.BlockTemplate, error) {
+	var template *bc.BlockTemplate
+	err := p.step("build template", func(ctx context.Context) (err error) {
+		template, err = blockchain.NewBlockTemplateContext(ctx, minerAddress)
+		return err
+	})
+	return template, err
+}
+
+// mineTemplate is MineTemplate run as the attached process p
+func mineTemplate(p *attachedProcess, blockchain *bc.Blockchain, template *bc.BlockTemplate, numMiners int,
+	timeout time.Duration, interrupt <-chan struct{}) (*bc.Block, error) {
+	if blockchain.Params.Engine().Name() != bc.ConsensusPoW {
+		return nil, bc.ErrWrongConsensus
+	}
+	if numMiners < 1 {
+		return nil, ErrNoMiners
+	}
+	difficulty := template.Block.Difficulty
+	result := MiningEvent{
+		Miners:       numMiners,
+		Difficulty:   difficulty,
+		Transactions: len(template.Reservation.Transactions),
+		Bytes:        template.Bytes,
+		Fees:         template.Fees,
+	}
+	events.Publishf(events.MiningStarted, result,
+		"▶ Started mining block %d with %d concurrent miners at difficulty %d (%d transactions, %.4f in fees)",
+		template.Block.Index, numMiners, difficulty, result.Transactions, result.Fees)
 
 	var wg sync.WaitGroup
 	resultChan := make(chan *bc.Block, 1)
 	stopChan := make(chan struct{})
-	doneChan := make(chan int, numMiners) // Channel to track terminated miners
+	doneChan := make(chan MinerEvent, numMiners) // Channel to track terminated miners
 
 	spanningTree := NewSpanningTree(numMiners)
-	timeout := time.After(10 * time.Second)
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timeoutChan = time.After(timeout)
+	}
 
-	// Start all miners
-	for i := 0; i < numMiners; i++ {
+	// Start all miners, each on its own slice of the nonce space
+	started := time.Now()
+	for i, nonces := range PartitionNonces(numMiners) {
 		wg.Add(1)
-		go Miner(blockchain, transactions, difficulty, &wg, resultChan, stopChan, i, doneChan)
+		go func(minerID int, nonces NonceRange) {
+			// Attached workers are named in the detector's graphs. They
+			// take no locks while hashing, so recovery never picks one.
+			worker := attach(fmt.Sprintf("miner worker %d", minerID))
+			defer worker.detach()
+			Miner(template.PoW, template.Block, &wg, resultChan, stopChan, minerID, nonces, doneChan)
+		}(i, nonces)
 	}
 
-	// Wait for result or timeout
+	// Wait for result, tip change, timeout or interruption
 	var validBlock *bc.Block
+	var solvedAt time.Time
 
 	select {
 	case block := <-resultChan:
 		validBlock = block
-		close(stopChan) // Signal all miners to stop
+		solvedAt = time.Now()
 
-	case <-timeout:
-		fmt.Println("▶ Mining timed out after 10 seconds")
-		close(stopChan) // Signal all miners to stop on timeout
+	case <-template.Stale:
+		result.Stale = true
+		events.Publishf(events.MiningFinished, result, "▶ Chain tip moved past block %d's parent, abandoning the template",
+			template.Block.Index)
+
+	case <-timeoutChan:
+		result.TimedOut = true
+		events.Publishf(events.MiningFinished, result, "▶ Mining timed out after %s", timeout.Round(time.Millisecond))
+
+	case <-interrupt:
+		result.Interrupted = true
+		events.Publish(events.MiningFinished, "▶ Mining interrupted, abandoning the block template", result)
 	}
+	stoppedAt := time.Now()
+	close(stopChan) // Signal all miners to stop
 
 	// Wait for all miners to terminate and mark them in the spanning tree
+	reports := make([]workerReport, 0, numMiners)
 	terminatedCount := 0
 	timeoutLoop := false
 	for terminatedCount < numMiners && !timeoutLoop {
 		select {
-		case minerID := <-doneChan:
-			spanningTree.MarkNodeTerminated(minerID)
+		case stopped := <-doneChan:
+			spanningTree.MarkNodeTerminated(stopped.MinerID)
+			result.Hashes += stopped.Hashes
+			reports = append(reports, workerReport{
+				MinerID: stopped.MinerID,
+				Hashes:  stopped.Hashes,
+				Found:   validBlock != nil && stopped.BlockHash == validBlock.Hash,
+				Exited:  time.Now(),
+			})
 			terminatedCount++
 		case <-time.After(5 * time.Second):
-			fmt.Println("▶ Timed out waiting for miners to terminate")
+			events.Publish(events.Termination, "▶ Timed out waiting for miners to terminate", nil)
 			timeoutLoop = true
 		}
 	}
@@ -89,11 +340,63 @@ func StartMining(blockchain *bc.Blockchain, transactions []bc.Transaction, diffi
 	// Run termination detection algorithm
 	allTerminated := spanningTree.DetectTermination()
 	if allTerminated {
-		fmt.Println("▶ All miners have successfully terminated")
+		events.Publish(events.Termination, "▶ All miners have successfully terminated", nil)
 	} else {
-		fmt.Println("▶ Some miners did not terminate properly")
+		events.Publish(events.Termination, "▶ Some miners did not terminate properly", nil)
 	}
 
 	wg.Wait()
-	return validBlock
+	atomic.AddUint64(&shares.Hashes, uint64(result.Hashes))
+
+	// A block solved just as the tip moved is a stale share: submitting it
+	// would only add a side branch at an index the chain already has
+	if validBlock != nil {
+		select {
+		case <-template.Stale:
+			result.Stale = true
+			atomic.AddUint64(&shares.Stale, 1)
+			events.Publishf(events.StaleShare, Shares(), "▶ Discarding stale block %d (%s), the chain tip moved while it was being mined",
+				validBlock.Index, validBlock.Hash)
+		default:
+		}
+	}
+
+	round := RoundStats{Miners: numMiners, Difficulty: difficulty, Outcome: OutcomeSolved}
+	switch {
+	case result.Stale:
+		round.Outcome = OutcomeStale
+	case result.Interrupted:
+		round.Outcome = OutcomeInterrupted
+	case validBlock == nil:
+		round.Outcome = OutcomeTimedOut
+	}
+	telemetry.record(round, started, solvedAt, stoppedAt, reports)
+
+	switch {
+	case result.Stale:
+		atomic.AddUint64(&shares.Restarts, 1)
+		atomic.AddUint64(&shares.StaleHashes, uint64(result.Hashes))
+		return nil, ErrStaleTemplate
+	case result.Interrupted:
+		return nil, ErrMiningInterrupted
+	case validBlock == nil:
+		return nil, ErrMiningTimedOut
+	}
+	result.Block = validBlock
+	events.Publishf(events.MiningFinished, result, "▶ Mining finished, block %d solved", validBlock.Index)
+
+	// Its transactions leave the mempool only if it ends up on the main chain
+	err := p.step("submit block", func(ctx context.Context) error {
+		return blockchain.AddMinedBlockContext(ctx, validBlock)
+	})
+	if err != nil {
+		atomic.AddUint64(&shares.Rejected, 1)
+		return nil, err
+	}
+	if blockchain.IsOnMainChain(validBlock.Hash) {
+		atomic.AddUint64(&shares.Accepted, 1)
+	} else {
+		atomic.AddUint64(&shares.SideBranch, 1)
+	}
+	return validBlock, nil
 }
@@ -0,0 +1,73 @@
+package miner
+
+import (
+	bc "blockchain-visualizer/blockchain"
+	"blockchain-visualizer/events"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPartitionNoncesCoversTheSpace(t *testing.T) {
+	for _, miners := range []int{-1, 0, 1, 3, 7} {
+		ranges := PartitionNonces(miners)
+		want := miners
+		if want < 1 {
+			want = 1
+		}
+		if len(ranges) != want {
+			t.Errorf("%d miners got %d ranges, want %d", miners, len(ranges), want)
+			continue
+		}
+		next := 0
+		for _, nonces := range ranges {
+			if nonces.Start != next || nonces.End <= nonces.Start {
+				t.Errorf("%d miners: range %+v does not follow %d", miners, nonces, next)
+			}
+			next = nonces.End
+		}
+		if next != nonceSpace() {
+			t.Errorf("%d miners: ranges end at %d, want %d", miners, next, nonceSpace())
+		}
+	}
+}
+
+// benchmarkMiner runs Miner over b.N nonces of a block with the given
+// header version. The target can never be met, so the miner hashes the
+// whole range and is stopped once it reports rolling its extra nonce.
+func benchmarkMiner(b *testing.B, version uint32) {
+	coinbase := bc.NewCoinbaseTransaction("91829a68de5060ab7281baeb154a55829a679f0f", 1, 0)
+	template := bc.NewBlock(1, strings.Repeat("0", 64), []bc.Transaction{coinbase}, 64)
+	template.Version = version
+	pow, err := bc.ProofOfWorkByName(bc.PoWSHA256)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	progress, unsubscribe := events.Default.Subscribe(64)
+	defer unsubscribe()
+	var wg sync.WaitGroup
+	stopChan := make(chan struct{})
+	doneChan := make(chan MinerEvent, 1)
+	wg.Add(1)
+
+	b.ResetTimer()
+	go Miner(pow, template, &wg, make(chan *bc.Block), stopChan, 0, NonceRange{Start: 0, End: b.N}, doneChan)
+	for event := range progress {
+		if sample, ok := event.Data.(MinerEvent); ok && event.Type == events.NonceProgress && sample.ExtraNonce > 0 {
+			break
+		}
+	}
+	close(stopChan)
+	wg.Wait()
+	b.StopTimer()
+	<-doneChan
+}
+
+func BenchmarkMinerLegacyHeader(b *testing.B) {
+	benchmarkMiner(b, bc.HeaderVersionLegacy)
+}
+
+func BenchmarkMinerBinaryHeader(b *testing.B) {
+	benchmarkMiner(b, bc.HeaderVersionBinary)
+}
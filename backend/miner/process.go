@@ -0,0 +1,168 @@
+package miner
+
+import (
+	"blockchain-visualizer/events"
+	"blockchain-visualizer/locks"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// recoveryBackoff is how long a deadlock victim waits before retrying, so
+// the processes it gave way to can take its locks first
+const recoveryBackoff = 50 * time.Millisecond
+
+// ErrAborted is returned by a step whose process was aborted to break a deadlock
+var ErrAborted = errors.New("aborted to break a deadlock")
+
+// attachedProcess is a goroutine attached to the deadlock detector that
+// tracks the locks, so the recovery policy can pick it as a victim. When no
+// detector is tracking the locks its context is never cancelled.
+type attachedProcess struct {
+	detector *DeadlockDetector
+	ctx      context.Context
+	detach   func()
+	held     []heldLock // Locks taken with lock in the running step, oldest first
+	stage    int        // Index of the running stage of the step
+}
+
+// heldLock is a lock taken by a step and the stage that took it
+type heldLock struct {
+	mutex *locks.Mutex
+	stage int
+}
+
+// stage is one part of a step. It starts at a checkpoint named by its
+// label, which a rollback can return the process to.
+type stage struct {
+	label string
+	run   func() error
+}
+
+// attach attaches the calling goroutine as name to the deadlock detector
+// tracking the locks, if any. detach must be called when the goroutine is done.
+func attach(name string) *attachedProcess {
+	detector, ok := locks.CurrentTracker().(*DeadlockDetector)
+	if !ok {
+		return &attachedProcess{ctx: context.Background(), detach: func() {}}
+	}
+	ctx, detach := detector.Attach(context.Background(), name)
+	return &attachedProcess{detector: detector, ctx: ctx, detach: detach}
+}
+
+// step runs fn as a step of a single stage called label. fn must wait for
+// locks with the context it is given and give up every lock it took before
+// it returns.
+func (p *attachedProcess) step(label string, fn func(ctx context.Context) error) error {
+	return p.stages(stage{label, func() error { return fn(p.ctx) }})
+}
+
+// stages runs a step made of stages in order, each from a checkpoint of its
+// own. Stages take locks with lock, which keeps them until the step ends,
+// and pass on the error of a wait cancelled by a recovery. The recovery
+// then decides how the step goes on:
+//
+//   - abort_youngest gives up every lock and ends the step with ErrAborted
+//   - preempt is handled within lock; if the contested lock was not taken
+//     with lock, the stage gave it up itself and is run again
+//   - rollback gives up the locks taken since the checkpoint and runs its
+//     stage again, or starts the step over if the recovery says so
+func (p *attachedProcess) stages(stages ...stage) error {
+	defer p.release(0)
+	for p.stage = 0; p.stage < len(stages); {
+		if p.detector != nil {
+			p.detector.Checkpoint(stages[p.stage].label)
+		}
+		err := stages[p.stage].run()
+		if err == nil {
+			p.stage++
+			continue
+		}
+		recovery, ok := p.recovery()
+		if !ok {
+			return err
+		}
+
+		switch recovery.Policy {
+		case RecoverPreempt, RecoverRollback:
+			if recovery.Checkpoint == "" && recovery.Policy == RecoverRollback {
+				p.stage = 0
+			}
+			p.release(p.stage)
+			p.acknowledge(recovery, fmt.Sprintf("went back to checkpoint %q", stages[p.stage].label))
+			time.Sleep(recoveryBackoff)
+		default:
+			p.release(0)
+			p.acknowledge(recovery, "was aborted")
+			return fmt.Errorf("%w (recovery #%d)", ErrAborted, recovery.ID)
+		}
+	}
+	return nil
+}
+
+// lock takes m for the running stage, waiting with the process's context.
+// A preempt recovery naming a lock the step holds is handled here: only
+// that lock is given up, and once it is taken back the wait for m starts
+// over, so the stage is not run again. Other recoveries are returned to
+// stages as the error of the wait.
+func (p *attachedProcess) lock(m *locks.Mutex) error {
+	for {
+		err := m.LockContext(p.ctx)
+		if err == nil {
+			p.held = append(p.held, heldLock{mutex: m, stage: p.stage})
+			return nil
+		}
+		recovery, ok := p.recovery()
+		if !ok || recovery.Policy != RecoverPreempt {
+			return err
+		}
+		i := p.holding(recovery.Resource)
+		if i < 0 {
+			return err
+		}
+
+		preempted := p.held[i].mutex
+		preempted.Unlock()
+		p.acknowledge(recovery, fmt.Sprintf("gave up lock %d", recovery.Resource))
+		if err := preempted.LockContext(p.ctx); err != nil {
+			p.held = append(p.held[:i], p.held[i+1:]...)
+			return err
+		}
+	}
+}
+
+// holding returns the position of the lock with the given resource ID in
+// the locks the step holds, or -1
+func (p *attachedProcess) holding(resource int) int {
+	for i, held := range p.held {
+		if held.mutex.ID() == resource {
+			return i
+		}
+	}
+	return -1
+}
+
+// release gives up the locks taken from stage from on, newest first
+func (p *attachedProcess) release(from int) {
+	for len(p.held) > 0 && p.held[len(p.held)-1].stage >= from {
+		p.held[len(p.held)-1].mutex.Unlock()
+		p.held = p.held[:len(p.held)-1]
+	}
+}
+
+// recovery returns the recovery that cancelled the process, if one did
+func (p *attachedProcess) recovery() (Recovery, bool) {
+	if p.detector == nil || p.ctx.Err() == nil {
+		return Recovery{}, false
+	}
+	return p.detector.RecoveryFor(p.ctx)
+}
+
+// acknowledge tells the detector the process did what recovery asked, and
+// the process carries on with a fresh context
+func (p *attachedProcess) acknowledge(recovery Recovery, action string) {
+	p.ctx = p.detector.Recovered(p.ctx)
+	events.Publishf(events.DeadlockRecovery, recovery, "▸▸▸ Process %d (%s) %s for recovery #%d",
+		recovery.Victim, recovery.VictimName, action, recovery.ID)
+}
@@ -0,0 +1,378 @@
+package miner
+
+import (
+	"blockchain-visualizer/events"
+	"blockchain-visualizer/locks"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RecoveryPolicy decides what the detector does about the deadlocks it finds
+type RecoveryPolicy string
+
+const (
+	// RecoverNone only reports deadlocks
+	RecoverNone RecoveryPolicy = "none"
+	// RecoverAbortYoungest aborts the most recently attached process in the
+	// cycle, which gives up its locks and ends its step with ErrAborted
+	RecoverAbortYoungest RecoveryPolicy = "abort_youngest"
+	// RecoverPreempt makes the youngest process give up the resource its
+	// predecessor in the cycle waits for
+	RecoverPreempt RecoveryPolicy = "preempt"
+	// RecoverRollback sends the youngest process back to its last checkpoint,
+	// giving up every resource it took since, or back to its start if the
+	// checkpoint already held the resource the cycle waits on
+	RecoverRollback RecoveryPolicy = "rollback"
+)
+
+// ErrUnknownRecoveryPolicy is returned for a recovery policy name that does not exist
+var ErrUnknownRecoveryPolicy = errors.New("unknown deadlock recovery policy")
+
+// RecoveryPolicies lists the policies in the order they are documented
+func RecoveryPolicies() []RecoveryPolicy {
+	return []RecoveryPolicy{RecoverNone, RecoverAbortYoungest, RecoverPreempt, RecoverRollback}
+}
+
+// ParseRecoveryPolicy returns the policy with the given name
+func ParseRecoveryPolicy(name string) (RecoveryPolicy, error) {
+	for _, policy := range RecoveryPolicies() {
+		if string(policy) == name {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownRecoveryPolicy, name)
+}
+
+// Recovery outcomes
+const (
+	RecoveryCancelled = "cancelled" // The victim's context was cancelled
+	RecoveryNoVictim  = "no_victim" // No process in the cycle is attached, so none could be cancelled
+)
+
+// maxRecoveryLog is how many recovery actions the audit log keeps
+const maxRecoveryLog = 100
+
+// Recovery is one action taken to break a deadlock. It is kept in the audit
+// log and handed to the victim through RecoveryFor.
+type Recovery struct {
+	ID         int            `json:"id"`
+	Time       int64          `json:"time"`
+	Policy     RecoveryPolicy `json:"policy"`
+	Cycle      []int          `json:"cycle"`
+	Victim     int            `json:"victim,omitempty"`
+	VictimName string         `json:"victimName,omitempty"`
+	Resource   int            `json:"resource,omitempty"`   // Resource the victim must give up, for preempt
+	Checkpoint string         `json:"checkpoint,omitempty"` // Checkpoint the victim returns to for rollback, empty for its start
+	Release    []int          `json:"release,omitempty"`    // Resources taken since the checkpoint, for rollback
+	Outcome    string         `json:"outcome"`
+	Message    string         `json:"message"`
+}
+
+// process is a goroutine attached to the detector, which makes it eligible
+// as a recovery victim
+type process struct {
+	id         int
+	name       string
+	attached   time.Time
+	parent     context.Context
+	cancel     context.CancelFunc
+	checkpoint string
+	held       []int     // Resources held when the checkpoint was taken
+	recovery   *Recovery // Set once the process is chosen as a victim
+}
+
+// processKey is the context key under which Attach stores the process ID
+type processKey struct{}
+
+// Attach registers the calling goroutine as a process the recovery policy
+// may cancel. It must be called on the goroutine that takes the locks. The
+// returned context is cancelled when the process is chosen as a victim; the
+// process should then give up its locks as RecoveryFor describes. detach
+// unregisters the process and must be called when it is done.
+func (d *DeadlockDetector) Attach(parent context.Context, name string) (ctx context.Context, detach func()) {
+	id := locks.Process()
+	ctx, cancel := context.WithCancel(context.WithValue(parent, processKey{}, id))
+	p := &process{id: id, name: name, attached: time.Now(), parent: parent, cancel: cancel}
+
+	d.mutex.Lock()
+	d.processes[id] = p
+	d.mutex.Unlock()
+
+	return ctx, func() {
+		d.mutex.Lock()
+		if d.processes[id] == p {
+			delete(d.processes, id)
+		}
+		stop := p.cancel
+		d.mutex.Unlock()
+		stop()
+	}
+}
+
+// Recovered tells the detector that the process ctx belongs to has given up
+// the locks its recovery asked for. The process can be picked as a victim
+// again, keeping the age it was attached with, and carries on with the
+// returned context.
+func (d *DeadlockDetector) Recovered(ctx context.Context) context.Context {
+	id, ok := ctx.Value(processKey{}).(int)
+	if !ok {
+		return ctx
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	p, ok := d.processes[id]
+	if !ok || p.recovery == nil {
+		return ctx
+	}
+	p.recovery = nil
+	ctx, p.cancel = context.WithCancel(context.WithValue(p.parent, processKey{}, id))
+	return ctx
+}
+
+// Checkpoint records a point the calling attached process can roll back to,
+// together with the resources it holds there
+func (d *DeadlockDetector) Checkpoint(label string) {
+	id := locks.Process()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if p, ok := d.processes[id]; ok {
+		p.checkpoint = label
+		p.held = append([]int{}, d.allocations[id]...)
+	}
+}
+
+// RecoveryFor returns the recovery that cancelled the process ctx belongs to
+func (d *DeadlockDetector) RecoveryFor(ctx context.Context) (Recovery, bool) {
+	id, ok := ctx.Value(processKey{}).(int)
+	if !ok {
+		return Recovery{}, false
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	p, ok := d.processes[id]
+	if !ok || p.recovery == nil {
+		return Recovery{}, false
+	}
+	return *p.recovery, true
+}
+
+// SetRecoveryPolicy changes what is done about deadlocks found from now on
+func (d *DeadlockDetector) SetRecoveryPolicy(policy RecoveryPolicy) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.policy = policy
+}
+
+// RecoveryPolicy returns the current recovery policy
+func (d *DeadlockDetector) RecoveryPolicy() RecoveryPolicy {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.policy
+}
+
+// Recoveries returns the audit log of recovery actions, oldest first
+func (d *DeadlockDetector) Recoveries() []Recovery {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]Recovery{}, d.recoveries...)
+}
+
+// Recover applies the recovery policy to each cycle found by
+// DetectDeadlocks. Cycles that already have a victim on its way out, or
+// that resolved since they were found, are left alone, and a cycle with no
+// process to cancel, such as one staged by hand, is only logged once.
+func (d *DeadlockDetector) Recover(deadlocks [][]int) []Recovery {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.policy == RecoverNone {
+		return nil
+	}
+
+	recoveries := []Recovery{}
+	stuck := make(map[string]bool)
+	for _, cycle := range deadlocks {
+		// Cycles repeat their first process at the end
+		members := cycle[:len(cycle)-1]
+		if d.recovering(members) || !d.stillDeadlocked(members) {
+			continue
+		}
+		key := formatCycle(cycle, ",")
+		if d.stuck[key] && d.youngest(members, false) == nil {
+			stuck[key] = true
+			continue
+		}
+		recovery := d.recoverCycle(members)
+		if recovery.Outcome == RecoveryNoVictim {
+			stuck[key] = true
+		}
+		recoveries = append(recoveries, recovery)
+	}
+	d.stuck = stuck
+	return recoveries
+}
+
+// recovering reports whether a process in the cycle has already been chosen
+// as a victim. The caller must hold the mutex.
+func (d *DeadlockDetector) recovering(members []int) bool {
+	for _, id := range members {
+		if p, ok := d.processes[id]; ok && p.recovery != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// stillDeadlocked reports whether each member still waits for a resource
+// the next member holds. The caller must hold the mutex.
+func (d *DeadlockDetector) stillDeadlocked(members []int) bool {
+	for i, id := range members {
+		if d.blockingResource(id, members[(i+1)%len(members)]) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// blockingResource returns a resource waiter waits for that holder holds,
+// or 0 if there is none. The caller must hold the mutex.
+func (d *DeadlockDetector) blockingResource(waiter, holder int) int {
+	for _, wanted := range d.waitFor[waiter] {
+		for _, held := range d.allocations[holder] {
+			if wanted == held {
+				return wanted
+			}
+		}
+	}
+	return 0
+}
+
+// recoverCycle picks a victim in the cycle, cancels it and logs the action.
+// The caller must hold the mutex.
+func (d *DeadlockDetector) recoverCycle(members []int) Recovery {
+	d.nextRecovery++
+	recovery := Recovery{
+		ID:     d.nextRecovery,
+		Time:   time.Now().Unix(),
+		Policy: d.policy,
+		Cycle:  append(append([]int{}, members...), members[0]),
+	}
+	cycle := formatCycle(recovery.Cycle, " → ")
+
+	victim := d.youngest(members, d.policy == RecoverRollback)
+	if victim == nil {
+		recovery.Outcome = RecoveryNoVictim
+		recovery.Message = fmt.Sprintf("▸▸▸ Recovery #%d: no attached process in %s to cancel", recovery.ID, cycle)
+		d.logRecovery(recovery)
+		return recovery
+	}
+	recovery.Victim = victim.id
+	recovery.VictimName = victim.name
+	recovery.Outcome = RecoveryCancelled
+
+	// The process waiting on the victim is the one giving up a resource unblocks
+	position := indexOf(members, victim.id)
+	waiter := members[(position+len(members)-1)%len(members)]
+	contested := d.blockingResource(waiter, victim.id)
+
+	switch d.policy {
+	case RecoverAbortYoungest:
+		recovery.Message = fmt.Sprintf("▸▸▸ Recovery #%d: aborted process %d (%s) to break %s",
+			recovery.ID, victim.id, victim.name, cycle)
+	case RecoverPreempt:
+		recovery.Resource = contested
+		recovery.Message = fmt.Sprintf("▸▸▸ Recovery #%d: preempted %s from process %d (%s) for process %d to break %s",
+			recovery.ID, d.describeResource(recovery.Resource), victim.id, victim.name, waiter, cycle)
+	case RecoverRollback:
+		recovery.Checkpoint = victim.checkpoint
+		recovery.Release = subtractResources(d.allocations[victim.id], victim.held)
+		if indexOf(recovery.Release, contested) < 0 {
+			// The checkpoint already held the contested resource, so only
+			// starting over breaks the cycle
+			recovery.Checkpoint = ""
+			recovery.Release = append([]int{}, d.allocations[victim.id]...)
+		}
+		target := fmt.Sprintf("checkpoint %q", recovery.Checkpoint)
+		if recovery.Checkpoint == "" {
+			target = "its start"
+		}
+		recovery.Message = fmt.Sprintf("▸▸▸ Recovery #%d: rolled process %d (%s) back to %s, releasing resources %v, to break %s",
+			recovery.ID, victim.id, victim.name, target, recovery.Release, cycle)
+	}
+
+	victim.recovery = &recovery
+	victim.cancel()
+	d.logRecovery(recovery)
+	return recovery
+}
+
+// youngest returns the most recently attached process among members,
+// preferring processes with a checkpoint if checkpointed is set, or nil if
+// none is attached. The caller must hold the mutex.
+func (d *DeadlockDetector) youngest(members []int, checkpointed bool) *process {
+	candidates := []*process{}
+	for _, id := range members {
+		if p, ok := d.processes[id]; ok {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if checkpointed && (a.checkpoint != "") != (b.checkpoint != "") {
+			return a.checkpoint != ""
+		}
+		if !a.attached.Equal(b.attached) {
+			return a.attached.After(b.attached)
+		}
+		return a.id > b.id
+	})
+	return candidates[0]
+}
+
+// logRecovery appends to the audit log and publishes the action. The
+// caller must hold the mutex.
+func (d *DeadlockDetector) logRecovery(recovery Recovery) {
+	d.recoveries = append(d.recoveries, recovery)
+	if len(d.recoveries) > maxRecoveryLog {
+		d.recoveries = d.recoveries[len(d.recoveries)-maxRecoveryLog:]
+	}
+	events.Publish(events.DeadlockRecovery, recovery.Message, recovery)
+}
+
+// indexOf returns the position of id in ids, or -1
+func indexOf(ids []int, id int) int {
+	for i, v := range ids {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// subtractResources returns the resources in held that are not accounted
+// for by an equal entry in kept
+func subtractResources(held, kept []int) []int {
+	remaining := append([]int{}, kept...)
+	released := []int{}
+	for _, r := range held {
+		if i := indexOf(remaining, r); i >= 0 {
+			remaining = append(remaining[:i], remaining[i+1:]...)
+			continue
+		}
+		released = append(released, r)
+	}
+	return released
+}
@@ -0,0 +1,163 @@
+package miner
+
+import (
+	"blockchain-visualizer/locks"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// outcome is how a process started by deadlockTwoProcesses ended
+type outcome struct {
+	err  error
+	runs map[string]int // Times each stage was run
+}
+
+// deadlockTwoProcesses starts two attached processes, first and then the
+// younger second. Each takes a lock of its own in a "setup" stage, then in
+// a "take locks" stage takes one of two shared locks and waits for the
+// other. It returns the shared locks first and second take first once the
+// detector sees them deadlocked. Each process reports on its channel when
+// its step ends.
+func deadlockTwoProcesses(t *testing.T, d *DeadlockDetector) (a, b *locks.Mutex, first, second chan outcome) {
+	a, b = locks.NewMutex("a"), locks.NewMutex("b")
+	var holding sync.WaitGroup
+	holding.Add(2)
+	attached := make(chan struct{})
+
+	run := func(name string, own, other *locks.Mutex, done chan<- outcome) {
+		p := attach(name)
+		defer p.detach()
+		attached <- struct{}{}
+
+		private := locks.NewMutex(name)
+		runs := make(map[string]int)
+		err := p.stages(
+			stage{"setup", func() error {
+				runs["setup"]++
+				return p.lock(private)
+			}},
+			stage{"take locks", func() error {
+				runs["take locks"]++
+				if err := p.lock(own); err != nil {
+					return err
+				}
+				if runs["take locks"] == 1 {
+					holding.Done()
+					holding.Wait()
+				}
+				return p.lock(other)
+			}},
+		)
+		done <- outcome{err: err, runs: runs}
+	}
+	first, second = make(chan outcome, 1), make(chan outcome, 1)
+	go run("first", a, b, first)
+	<-attached
+	go run("second", b, a, second)
+	<-attached
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(d.DetectDeadlocks()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("processes never deadlocked")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return a, b, first, second
+}
+
+// finished waits for a process started by deadlockTwoProcesses to end
+func finished(t *testing.T, name string, done <-chan outcome) outcome {
+	t.Helper()
+	select {
+	case result := <-done:
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s still deadlocked after recovery", name)
+		return outcome{}
+	}
+}
+
+func TestRecoveryBreaksDeadlock(t *testing.T) {
+	once := map[string]int{"setup": 1, "take locks": 1}
+	for _, test := range []struct {
+		policy RecoveryPolicy
+		err    error          // What the victim's step returns
+		runs   map[string]int // How often the victim ran each stage
+	}{
+		// The victim ends with an error and runs nothing again
+		{RecoverAbortYoungest, ErrAborted, once},
+		// The victim gives up one lock for a while and is never sent back
+		{RecoverPreempt, nil, once},
+		// The victim runs the stage from its last checkpoint again, keeping
+		// the lock taken before it
+		{RecoverRollback, nil, map[string]int{"setup": 1, "take locks": 2}},
+	} {
+		t.Run(string(test.policy), func(t *testing.T) {
+			d := NewDeadlockDetector()
+			d.SetRecoveryPolicy(test.policy)
+			locks.SetTracker(d)
+			defer locks.SetTracker(nil)
+
+			_, b, first, second := deadlockTwoProcesses(t, d)
+			recoveries := d.Recover(d.DetectDeadlocks())
+			if len(recoveries) != 1 || recoveries[0].Outcome != RecoveryCancelled {
+				t.Fatalf("recoveries %+v, want one that cancelled a victim", recoveries)
+			}
+			recovery := recoveries[0]
+			if recovery.VictimName != "second" {
+				t.Errorf("victim %q, want the youngest process", recovery.VictimName)
+			}
+			switch test.policy {
+			case RecoverPreempt:
+				if recovery.Resource != b.ID() {
+					t.Errorf("preempted resource %d, want %d, the lock first waits for", recovery.Resource, b.ID())
+				}
+			case RecoverRollback:
+				if recovery.Checkpoint != "take locks" || !reflect.DeepEqual(recovery.Release, []int{b.ID()}) {
+					t.Errorf("rolled back to %q releasing %v, want checkpoint %q releasing %d",
+						recovery.Checkpoint, recovery.Release, "take locks", b.ID())
+				}
+			}
+
+			if result := finished(t, "first", first); result.err != nil || !reflect.DeepEqual(result.runs, once) {
+				t.Errorf("first ended with %v after running %v, want it to finish after running %v",
+					result.err, result.runs, once)
+			}
+			result := finished(t, "second", second)
+			if !errors.Is(result.err, test.err) {
+				t.Errorf("victim ended with %v, want %v", result.err, test.err)
+			}
+			if !reflect.DeepEqual(result.runs, test.runs) {
+				t.Errorf("victim ran %v, want %v", result.runs, test.runs)
+			}
+			if cycles := d.DetectDeadlocks(); len(cycles) != 0 {
+				t.Errorf("cycles %v remain after recovery", cycles)
+			}
+		})
+	}
+}
+
+func TestRecoverNoneLeavesDeadlock(t *testing.T) {
+	d := NewDeadlockDetector()
+	d.SetRecoveryPolicy(RecoverNone)
+	locks.SetTracker(d)
+	defer locks.SetTracker(nil)
+
+	_, _, first, second := deadlockTwoProcesses(t, d)
+	if recoveries := d.Recover(d.DetectDeadlocks()); len(recoveries) != 0 {
+		t.Errorf("recoveries %+v with policy none", recoveries)
+	}
+	if len(d.DetectDeadlocks()) == 0 {
+		t.Error("deadlock resolved without recovery")
+	}
+
+	// Free the processes so they do not outlive the test
+	d.SetRecoveryPolicy(RecoverAbortYoungest)
+	d.Recover(d.DetectDeadlocks())
+	<-first
+	<-second
+}
@@ -0,0 +1,338 @@
+package miner
+
+import (
+	bc "blockchain-visualizer/blockchain"
+	"blockchain-visualizer/events"
+	"blockchain-visualizer/locks"
+	"errors"
+	"time"
+)
+
+// ServiceState is the lifecycle state of the background miner
+type ServiceState string
+
+const (
+	ServiceStopped ServiceState = "stopped"
+	ServiceRunning ServiceState = "running"
+	ServicePaused  ServiceState = "paused"
+)
+
+// templateRefreshInterval is how often the background miner picks up
+// transactions that arrived since its template was built. A new tip
+// rebuilds the template immediately, as MineTemplate aborts stale rounds.
+const templateRefreshInterval = 2 * time.Second
+
+// ErrServiceStopped is returned when resuming a miner service that is not started
+var ErrServiceStopped = errors.New("miner service is stopped")
+
+// TemplateInfo describes the block template the background miner is working on
+type TemplateInfo struct {
+	Height       int     `json:"height"`
+	ParentHash   string  `json:"parentHash"`
+	Difficulty   int     `json:"difficulty"`
+	Transactions int     `json:"transactions"`
+	Fees         float64 `json:"fees"`
+	BuiltAt      int64   `json:"builtAt"`
+}
+
+// ServiceStatus is a snapshot of the background miner
+type ServiceStatus struct {
+	State         ServiceState  `json:"state"`
+	Miners        int           `json:"miners"`
+	MinerAddress  string        `json:"minerAddress,omitempty"`
+	StartedAt     int64         `json:"startedAt,omitempty"`
+	Rounds        int           `json:"rounds"`
+	BlocksMined   int           `json:"blocksMined"`
+	Rebuilds      int           `json:"rebuilds"` // Rounds abandoned for a fresher template
+	Rejected      int           `json:"rejected"` // Solved blocks the chain refused
+	LastBlockHash string        `json:"lastBlockHash,omitempty"`
+	LastError     string        `json:"lastError,omitempty"`
+	Template      *TemplateInfo `json:"template,omitempty"`
+	Shares        ShareStats    `json:"shares"` // Every mining round on this node, not only the service's
+}
+
+// Service mines continuously in the background. Each round mines a fresh
+// block template; the round is abandoned and the template rebuilt when the
+// tip changes or new transactions arrive.
+type Service struct {
+	blockchain *bc.Blockchain
+	status     ServiceStatus
+	pending    bool          // Transactions arrived since the template was built
+	interrupt  chan struct{} // Closed to abandon the current round
+	wake       chan struct{} // Closed to wake a paused loop
+	stop       chan struct{}
+	done       chan struct{}
+	mutex      *locks.Mutex
+}
+
+// NewService creates a stopped miner service that mines with numMiners by default
+func NewService(blockchain *bc.Blockchain, numMiners int) *Service {
+	return &Service{
+		blockchain: blockchain,
+		status:     ServiceStatus{State: ServiceStopped, Miners: numMiners},
+		mutex:      locks.NewMutex("miner service"),
+	}
+}
+
+// Start begins mining with numMiners, paying minerAddress. A paused service
+// is resumed with the new settings; a running one is left as it is. Chains
+// that are not secured by proof of work are refused with bc.ErrWrongConsensus,
+// and fewer than one miner with ErrNoMiners.
+func (s *Service) Start(minerAddress string, numMiners int) (ServiceStatus, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.blockchain.Params.Engine().Name() != bc.ConsensusPoW {
+		return s.snapshot(), bc.ErrWrongConsensus
+	}
+	if numMiners < 1 {
+		return s.snapshot(), ErrNoMiners
+	}
+
+	switch s.status.State {
+	case ServiceRunning:
+		return s.snapshot(), nil
+	case ServicePaused:
+		s.status.MinerAddress = minerAddress
+		s.status.Miners = numMiners
+		s.resume()
+		return s.snapshot(), nil
+	}
+
+	s.status = ServiceStatus{
+		State:        ServiceRunning,
+		Miners:       numMiners,
+		MinerAddress: minerAddress,
+		StartedAt:    time.Now().Unix(),
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.watch(s.stop)
+	go s.run(s.stop, s.done)
+
+	events.Publishf(events.MinerService, s.snapshot(), "▶ Background miner started with %d miners paying %s",
+		numMiners, minerAddress)
+	return s.snapshot(), nil
+}
+
+// Pause abandons the current round and waits for Resume or Start
+func (s *Service) Pause() ServiceStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.status.State == ServiceRunning {
+		s.status.State = ServicePaused
+		s.status.Template = nil
+		s.wake = make(chan struct{})
+		s.abandon()
+		events.Publish(events.MinerService, "▶ Background miner paused", s.snapshot())
+	}
+	return s.snapshot()
+}
+
+// Resume continues a paused service
+func (s *Service) Resume() (ServiceStatus, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.status.State == ServiceStopped {
+		return s.snapshot(), ErrServiceStopped
+	}
+	if s.status.State == ServicePaused {
+		s.resume()
+	}
+	return s.snapshot(), nil
+}
+
+// Stop abandons the current round and waits for the mining loop to exit
+func (s *Service) Stop() ServiceStatus {
+	s.mutex.Lock()
+	if s.status.State == ServiceStopped {
+		defer s.mutex.Unlock()
+		return s.snapshot()
+	}
+	s.status.State = ServiceStopped
+	s.status.Template = nil
+	close(s.stop)
+	s.abandon()
+	done := s.done
+	s.mutex.Unlock()
+
+	<-done
+	status := s.Status()
+	events.Publish(events.MinerService, "▶ Background miner stopped", status)
+	return status
+}
+
+// Status returns a snapshot of the service
+func (s *Service) Status() ServiceStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.snapshot()
+}
+
+// snapshot copies the status. The caller must hold the mutex.
+func (s *Service) snapshot() ServiceStatus {
+	status := s.status
+	status.Shares = Shares()
+	if status.Template != nil {
+		template := *status.Template
+		status.Template = &template
+	}
+	return status
+}
+
+// resume wakes a paused loop. The caller must hold the mutex.
+func (s *Service) resume() {
+	s.status.State = ServiceRunning
+	close(s.wake)
+	events.Publish(events.MinerService, "▶ Background miner resumed", s.snapshot())
+}
+
+// abandon interrupts the current round, if any. The caller must hold the mutex.
+func (s *Service) abandon() {
+	if s.interrupt != nil {
+		close(s.interrupt)
+		s.interrupt = nil
+	}
+}
+
+// rebuild abandons the current round so the loop mines a fresh template
+func (s *Service) rebuild(reason string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.interrupt == nil {
+		return
+	}
+	s.abandon()
+	s.pending = false
+	s.status.Rebuilds++
+	events.Publishf(events.MinerService, s.snapshot(), "▶ Background miner rebuilding its template: %s", reason)
+}
+
+// watch rebuilds the template, at most every templateRefreshInterval, when
+// new transactions arrive
+func (s *Service) watch(stop <-chan struct{}) {
+	feed, unsubscribe := events.Default.Subscribe(64)
+	defer unsubscribe()
+	ticker := time.NewTicker(templateRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case event := <-feed:
+			if event.Type == events.TransactionAdded {
+				s.mutex.Lock()
+				s.pending = true
+				s.mutex.Unlock()
+			}
+
+		case <-ticker.C:
+			s.mutex.Lock()
+			pending := s.pending
+			s.mutex.Unlock()
+			if pending {
+				s.rebuild("new transactions arrived")
+			}
+		}
+	}
+}
+
+// run mines one template after another until stop is closed. The loop is
+// attached to the deadlock detector, so a recovery can make it back off
+// from the chain and retry.
+func (s *Service) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	p := attach("miner service")
+	defer p.detach()
+
+	for {
+		if !s.waitUntilRunning(stop) {
+			return
+		}
+
+		s.mutex.Lock()
+		minerAddress := s.status.MinerAddress
+		s.mutex.Unlock()
+		template, err := newTemplate(p, s.blockchain, minerAddress)
+		if err != nil {
+			s.mutex.Lock()
+			s.status.LastError = err.Error()
+			s.mutex.Unlock()
+			continue
+		}
+
+		// Paused or stopped while the template was built
+		s.mutex.Lock()
+		if s.status.State != ServiceRunning || s.stop != stop {
+			s.mutex.Unlock()
+			s.blockchain.ReleaseTemplate(template)
+			continue
+		}
+		interrupt := make(chan struct{})
+		s.interrupt = interrupt
+		s.pending = false
+		s.status.Rounds++
+		s.status.Template = &TemplateInfo{
+			Height:       template.Block.Index,
+			ParentHash:   template.Block.PreviousHash,
+			Difficulty:   template.Block.Difficulty,
+			Transactions: len(template.Reservation.Transactions),
+			Fees:         template.Fees,
+			BuiltAt:      time.Now().Unix(),
+		}
+		numMiners := s.status.Miners
+		s.mutex.Unlock()
+
+		block, err := mineTemplate(p, s.blockchain, template, numMiners, 0, interrupt)
+		s.blockchain.ReleaseTemplate(template)
+
+		s.mutex.Lock()
+		if s.interrupt == interrupt {
+			s.interrupt = nil
+		}
+		switch {
+		case err == nil:
+			s.status.BlocksMined++
+			s.status.LastBlockHash = block.Hash
+		case errors.Is(err, ErrMiningInterrupted):
+		case errors.Is(err, ErrStaleTemplate):
+			s.status.Rebuilds++
+		default:
+			s.status.Rejected++
+			s.status.LastError = err.Error()
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// waitUntilRunning blocks while the service is paused. It returns false
+// once the service is stopped.
+func (s *Service) waitUntilRunning(stop <-chan struct{}) bool {
+	for {
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+
+		s.mutex.Lock()
+		state := s.status.State
+		wake := s.wake
+		s.mutex.Unlock()
+
+		if state == ServiceRunning {
+			return true
+		}
+		select {
+		case <-wake:
+		case <-stop:
+			return false
+		}
+	}
+}
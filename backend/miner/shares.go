@@ -0,0 +1,32 @@
+package miner
+
+import "sync/atomic"
+
+// ShareStats counts the outcome of every mining round on this node. A share
+// is a solved block; it is stale when the tip moved before it could be
+// submitted, so it would only have landed on a side branch.
+type ShareStats struct {
+	Accepted    uint64 `json:"accepted"`    // Shares that joined the main chain
+	SideBranch  uint64 `json:"sideBranch"`  // Shares the chain kept off the main chain because the tip moved during submission
+	Stale       uint64 `json:"stale"`       // Shares discarded because the tip had already moved
+	Rejected    uint64 `json:"rejected"`    // Shares the chain refused
+	Restarts    uint64 `json:"restarts"`    // Rounds abandoned because the tip moved
+	Hashes      uint64 `json:"hashes"`      // Every hash computed
+	StaleHashes uint64 `json:"staleHashes"` // Hashes spent on templates that went stale
+}
+
+// shares is updated atomically by every mining round
+var shares ShareStats
+
+// Shares returns a snapshot of the share statistics
+func Shares() ShareStats {
+	return ShareStats{
+		Accepted:    atomic.LoadUint64(&shares.Accepted),
+		SideBranch:  atomic.LoadUint64(&shares.SideBranch),
+		Stale:       atomic.LoadUint64(&shares.Stale),
+		Rejected:    atomic.LoadUint64(&shares.Rejected),
+		Restarts:    atomic.LoadUint64(&shares.Restarts),
+		Hashes:      atomic.LoadUint64(&shares.Hashes),
+		StaleHashes: atomic.LoadUint64(&shares.StaleHashes),
+	}
+}
@@ -0,0 +1,238 @@
+package miner
+
+import (
+	"blockchain-visualizer/locks"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// Round outcomes recorded in RoundStats
+const (
+	OutcomeSolved      = "solved"
+	OutcomeStale       = "stale"
+	OutcomeTimedOut    = "timed_out"
+	OutcomeInterrupted = "interrupted"
+)
+
+// maxRecentRounds is how many rounds MinerStats keeps in detail
+const maxRecentRounds = 50
+
+// WorkerStats accumulates the work of one miner slot. The miner with ID i in
+// every round counts towards slot i, so slots above the smallest miner count
+// used see fewer rounds.
+type WorkerStats struct {
+	MinerID         int     `json:"minerId"`
+	Rounds          int     `json:"rounds"`
+	Hashes          uint64  `json:"hashes"`
+	HashingSeconds  float64 `json:"hashingSeconds"`
+	HashesPerSecond float64 `json:"hashesPerSecond"` // Over the slot's last round
+	BlocksFound     int     `json:"blocksFound"`
+	WastedHashes    uint64  `json:"wastedHashes"`  // Estimated hashes computed after the stop signal
+	StopLatencyMs   float64 `json:"stopLatencyMs"` // How long the slot took to stop in its last round
+}
+
+// RoundStats describes one MineTemplate round
+type RoundStats struct {
+	Miners           int     `json:"miners"`
+	Difficulty       int     `json:"difficulty"`
+	Outcome          string  `json:"outcome"`
+	Hashes           uint64  `json:"hashes"`
+	DurationMs       float64 `json:"durationMs"`
+	TimeToSolutionMs float64 `json:"timeToSolutionMs,omitempty"`
+	HashesPerSecond  float64 `json:"hashesPerSecond"`
+	WastedHashes     uint64  `json:"wastedHashes"`
+	FinishedAt       int64   `json:"finishedAt"`
+}
+
+// ScalingSample aggregates every round mined with the same number of miners,
+// for charting how the hash rate scales with concurrency
+type ScalingSample struct {
+	Miners          int     `json:"miners"`
+	Rounds          int     `json:"rounds"`
+	Hashes          uint64  `json:"hashes"`
+	Seconds         float64 `json:"seconds"`
+	HashesPerSecond float64 `json:"hashesPerSecond"`
+	Speedup         float64 `json:"speedup,omitempty"` // Relative to a single miner, once one has been measured
+}
+
+// MinerStats is a snapshot of the hash-rate telemetry
+type MinerStats struct {
+	NumCPU                  int             `json:"numCpu"`
+	Rounds                  int             `json:"rounds"`
+	Solved                  int             `json:"solved"`
+	Hashes                  uint64          `json:"hashes"`
+	WastedHashes            uint64          `json:"wastedHashes"`
+	AverageTimeToSolutionMs float64         `json:"averageTimeToSolutionMs"`
+	Outcomes                map[string]int  `json:"outcomes"`
+	Workers                 []WorkerStats   `json:"workers"`
+	Scaling                 []ScalingSample `json:"scaling"`
+	Recent                  []RoundStats    `json:"recent"` // Newest last
+	Shares                  ShareStats      `json:"shares"`
+}
+
+// workerReport is what the round coordinator learns about one miner
+type workerReport struct {
+	MinerID int
+	Hashes  int
+	Found   bool
+	Exited  time.Time
+}
+
+// Telemetry aggregates the per-worker and per-round statistics of every
+// mining round on this node
+type Telemetry struct {
+	workers          map[int]*WorkerStats
+	scaling          map[int]*ScalingSample
+	outcomes         map[string]int
+	recent           []RoundStats
+	rounds           int
+	solved           int
+	hashes           uint64
+	wasted           uint64
+	timeToSolutionMs float64 // Sum over solved rounds
+	mutex            *locks.Mutex
+}
+
+// telemetry is fed by MineTemplate
+var telemetry = NewTelemetry()
+
+// NewTelemetry creates an empty aggregator
+func NewTelemetry() *Telemetry {
+	return &Telemetry{
+		workers:  make(map[int]*WorkerStats),
+		scaling:  make(map[int]*ScalingSample),
+		outcomes: make(map[string]int),
+		mutex:    locks.NewMutex("miner telemetry"),
+	}
+}
+
+// Stats returns a snapshot of the hash-rate telemetry of every round so far
+func Stats() MinerStats {
+	return telemetry.Snapshot()
+}
+
+// record folds one finished round into the statistics. started is when the
+// miners were launched, solvedAt when the winning block arrived (zero if
+// none did) and stoppedAt when the miners were told to stop.
+func (t *Telemetry) record(round RoundStats, started, solvedAt, stoppedAt time.Time, reports []workerReport) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	finished := stoppedAt
+	for _, report := range reports {
+		worker, ok := t.workers[report.MinerID]
+		if !ok {
+			worker = &WorkerStats{MinerID: report.MinerID}
+			t.workers[report.MinerID] = worker
+		}
+
+		elapsed := report.Exited.Sub(started).Seconds()
+		rate := 0.0
+		if elapsed > 0 {
+			rate = float64(report.Hashes) / elapsed
+		}
+		// The miner checks for the stop signal between hashes, so whatever
+		// it hashed while it was slow to notice is wasted
+		latency := report.Exited.Sub(stoppedAt)
+		if latency < 0 {
+			latency = 0
+		}
+		wasted := uint64(latency.Seconds() * rate)
+
+		worker.Rounds++
+		worker.Hashes += uint64(report.Hashes)
+		worker.HashingSeconds += elapsed
+		worker.HashesPerSecond = rate
+		worker.WastedHashes += wasted
+		worker.StopLatencyMs = float64(latency) / float64(time.Millisecond)
+		if report.Found {
+			worker.BlocksFound++
+		}
+
+		round.Hashes += uint64(report.Hashes)
+		round.WastedHashes += wasted
+		if report.Exited.After(finished) {
+			finished = report.Exited
+		}
+	}
+
+	duration := finished.Sub(started)
+	round.DurationMs = float64(duration) / float64(time.Millisecond)
+	if duration > 0 {
+		round.HashesPerSecond = float64(round.Hashes) / duration.Seconds()
+	}
+	if !solvedAt.IsZero() {
+		round.TimeToSolutionMs = float64(solvedAt.Sub(started)) / float64(time.Millisecond)
+	}
+	round.FinishedAt = finished.Unix()
+
+	t.rounds++
+	t.hashes += round.Hashes
+	t.wasted += round.WastedHashes
+	t.outcomes[round.Outcome]++
+	if round.Outcome == OutcomeSolved {
+		t.solved++
+		t.timeToSolutionMs += round.TimeToSolutionMs
+	}
+
+	scaling, ok := t.scaling[round.Miners]
+	if !ok {
+		scaling = &ScalingSample{Miners: round.Miners}
+		t.scaling[round.Miners] = scaling
+	}
+	scaling.Rounds++
+	scaling.Hashes += round.Hashes
+	scaling.Seconds += duration.Seconds()
+
+	t.recent = append(t.recent, round)
+	if len(t.recent) > maxRecentRounds {
+		t.recent = t.recent[len(t.recent)-maxRecentRounds:]
+	}
+}
+
+// Snapshot copies the aggregated statistics
+func (t *Telemetry) Snapshot() MinerStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stats := MinerStats{
+		NumCPU:       runtime.NumCPU(),
+		Rounds:       t.rounds,
+		Solved:       t.solved,
+		Hashes:       t.hashes,
+		WastedHashes: t.wasted,
+		Outcomes:     make(map[string]int, len(t.outcomes)),
+		Workers:      make([]WorkerStats, 0, len(t.workers)),
+		Scaling:      make([]ScalingSample, 0, len(t.scaling)),
+		Recent:       append([]RoundStats{}, t.recent...),
+		Shares:       Shares(),
+	}
+	if t.solved > 0 {
+		stats.AverageTimeToSolutionMs = t.timeToSolutionMs / float64(t.solved)
+	}
+	for outcome, count := range t.outcomes {
+		stats.Outcomes[outcome] = count
+	}
+	for _, worker := range t.workers {
+		stats.Workers = append(stats.Workers, *worker)
+	}
+	sort.Slice(stats.Workers, func(i, j int) bool { return stats.Workers[i].MinerID < stats.Workers[j].MinerID })
+
+	baseline := 0.0
+	if single, ok := t.scaling[1]; ok && single.Seconds > 0 {
+		baseline = float64(single.Hashes) / single.Seconds
+	}
+	for _, scaling := range t.scaling {
+		scaled := *scaling
+		if scaled.Seconds > 0 {
+			scaled.HashesPerSecond = float64(scaled.Hashes) / scaled.Seconds
+		}
+		if baseline > 0 {
+			scaled.Speedup = scaled.HashesPerSecond / baseline
+		}
+		stats.Scaling = append(stats.Scaling, scaled)
+	}
+	sort.Slice(stats.Scaling, func(i, j int) bool { return stats.Scaling[i].Miners < stats.Scaling[j].Miners })
+	return stats
+}
@@ -1,6 +1,7 @@
 package miner
 
 import (
+    "blockchain-visualizer/events"
     "fmt"
     "sync"
 )
@@ -31,7 +32,7 @@ type SpanningTree struct {
 
 // NewSpanningTree creates a new spanning tree with n nodes
 func NewSpanningTree(n int) *SpanningTree {
-    fmt.Println("➤ Created spanning tree with", n, "nodes")
+    events.Publishf(events.Termination, TokenEvent{Nodes: n}, "➤ Created spanning tree with %d nodes", n)
 
     root := &Node{
         ID:       0,
@@ -92,8 +93,7 @@ func (st *SpanningTree) MarkNodeTerminated(nodeID int) {
 
 // DetectTermination initiates termination detection algorithm
 func (st *SpanningTree) DetectTermination() bool {
-	fmt.Println("")
-    fmt.Println("➤ Starting termination detection...")
+    events.Publish(events.Termination, "➤ Starting termination detection...", TokenEvent{Nodes: st.NodeCount})
     
     // Initialize all nodes to white
     st.initializeColors(st.Root)
@@ -101,12 +101,11 @@ func (st *SpanningTree) DetectTermination() bool {
     // Send white token down the tree
     terminated := st.sendToken(st.Root)
     
+    result := TokenEvent{Nodes: st.NodeCount, Terminated: terminated}
     if terminated {
-		fmt.Println("")
-        fmt.Println("➤ Termination detection completed: All processes have terminated")
+        events.Publish(events.Termination, "➤ Termination detection completed: All processes have terminated", result)
     } else {
-		fmt.Println("")
-        fmt.Println("➤ Termination detection completed: Some processes still active")
+        events.Publish(events.Termination, "➤ Termination detection completed: Some processes still active", result)
     }
     
     return terminated
@@ -132,11 +131,13 @@ func (st *SpanningTree) sendToken(node *Node) bool {
     node.mutex.Unlock()
     
     if active {
-        fmt.Printf("➤ Node %d is still active, termination not complete\n", node.ID)
+        events.Publishf(events.TokenHop, TokenEvent{NodeID: node.ID, Active: true},
+            "➤ Node %d is still active, termination not complete", node.ID)
         return false
     }
     
-    fmt.Printf("➤ Sending token to node %d\n", node.ID)
+    events.Publishf(events.TokenHop, TokenEvent{NodeID: node.ID, ParentID: parentID(node)},
+        "➤ Sending token to node %d", node.ID)
     
     // Token color remains white unless a black node is found
     tokenColor := White
@@ -163,8 +164,8 @@ func (st *SpanningTree) sendToken(node *Node) bool {
     node.Color = tokenColor
     node.mutex.Unlock()
     
-    fmt.Printf("➤ Node %d processed token: was %v, now %v\n", 
-        node.ID, colorToString(prevColor), colorToString(tokenColor))
+    events.Publishf(events.TokenHop, TokenEvent{NodeID: node.ID, ParentID: parentID(node), Color: colorToString(tokenColor)},
+        "➤ Node %d processed token: was %v, now %v", node.ID, colorToString(prevColor), colorToString(tokenColor))
     
     // If this is the root and token is white, termination is detected
     if node.Parent == nil && tokenColor == White {
@@ -174,6 +175,24 @@ func (st *SpanningTree) sendToken(node *Node) bool {
     return true
 }
 
+// TokenEvent is the payload published for spanning tree events
+type TokenEvent struct {
+    Nodes      int    `json:"nodes,omitempty"`
+    NodeID     int    `json:"nodeId"`
+    ParentID   int    `json:"parentId"` // -1 for the root
+    Active     bool   `json:"active,omitempty"`
+    Color      string `json:"color,omitempty"`
+    Terminated bool   `json:"terminated,omitempty"`
+}
+
+// parentID returns the ID of the node's parent, or -1 for the root
+func parentID(node *Node) int {
+    if node.Parent == nil {
+        return -1
+    }
+    return node.Parent.ID
+}
+
 // Helper function to convert color to string
 func colorToString(c Color) string {
     if c == White {
@@ -1,28 +1,330 @@
 package network
 
 import (
-    "blockchain-visualizer/blockchain"
-    "fmt"
-    "time"
+	"blockchain-visualizer/blockchain"
+	"blockchain-visualizer/events"
+	"blockchain-visualizer/locks"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
 )
 
-// Network simulates a peer-to-peer network
+// syncInterval is how often the node re-handshakes with every peer to catch up on missed gossip
+const syncInterval = 10 * time.Second
+
+// syncPage is how many blocks Sync asks a peer for at a time, the most /chain serves
+const syncPage = 1000
+
+// NodeHeader carries the sender's URL on gossip requests so the receiver can sync back
+const NodeHeader = "X-Node-URL"
+
+// ErrGenesisMismatch is returned when a peer runs a different chain
+var ErrGenesisMismatch = errors.New("peer has a different genesis block")
+
+// Handshake is exchanged when two nodes connect so each learns the other's chain height
+type Handshake struct {
+	NodeURL     string   `json:"nodeUrl"`
+	Height      int      `json:"height"`
+	TipHash     string   `json:"tipHash"`
+	TotalWork   string   `json:"totalWork"`
+	GenesisHash string   `json:"genesisHash"`
+	Peers       []string `json:"peers"`
+}
+
+// Peer is what this node knows about another node
+type Peer struct {
+	URL       string `json:"url"`
+	Height    int    `json:"height"`
+	TipHash   string `json:"tipHash"`
+	TotalWork string `json:"totalWork"`
+	LastSeen  int64  `json:"lastSeen"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Network connects this node to its peers over HTTP and gossips blocks and transactions
 type Network struct {
-    Nodes []string
+	Self       string
+	blockchain *blockchain.Blockchain
+	peers      map[string]*Peer
+	client     *http.Client
+	mutex      *locks.Mutex
+}
+
+// NewNetwork creates a network node reachable at self
+func NewNetwork(self string, bc *blockchain.Blockchain) *Network {
+	return &Network{
+		Self:       strings.TrimSuffix(self, "/"),
+		blockchain: bc,
+		peers:      make(map[string]*Peer),
+		client:     &http.Client{Timeout: 5 * time.Second},
+		mutex:      locks.NewMutex("peer network"),
+	}
+}
+
+// Start connects to the bootstrap peers, gossips locally appended blocks and
+// transactions, and periodically resyncs until stopChan is closed
+func (n *Network) Start(bootstrap []string, stopChan <-chan struct{}) {
+	stream, unsubscribe := events.Default.Subscribe(256)
+
+	go func() {
+		defer unsubscribe()
+		ticker := time.NewTicker(syncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case event := <-stream:
+				switch event.Type {
+				case events.BlockAppended:
+					n.broadcast("/p2p/blocks", event.Data)
+				case events.TransactionAdded:
+					n.broadcast("/p2p/transactions", event.Data)
+				}
+			case <-ticker.C:
+				for _, peer := range n.PeerURLs() {
+					go n.Connect(peer)
+				}
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+
+	for _, peer := range bootstrap {
+		go n.Connect(peer)
+	}
+}
+
+// LocalHandshake describes this node's chain
+func (n *Network) LocalHandshake() Handshake {
+	genesis, _ := n.blockchain.GetBlock(0)
+	tip := n.blockchain.GetLatestBlock()
+	return Handshake{
+		NodeURL:     n.Self,
+		Height:      tip.Index,
+		TipHash:     tip.Hash,
+		TotalWork:   n.blockchain.TotalWork().String(),
+		GenesisHash: genesis.Hash,
+		Peers:       n.PeerURLs(),
+	}
+}
+
+// PeerURLs returns the URLs of all known peers in sorted order
+func (n *Network) PeerURLs() []string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	urls := make([]string, 0, len(n.peers))
+	for url := range n.peers {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// Peers returns a snapshot of all known peers
+func (n *Network) Peers() []Peer {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	peers := make([]Peer, 0, len(n.peers))
+	for _, peer := range n.peers {
+		peers = append(peers, *peer)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].URL < peers[j].URL })
+	return peers
+}
+
+// HandleHandshake records a peer that connected to us, syncs from it if it
+// is ahead, and returns our own handshake
+func (n *Network) HandleHandshake(remote Handshake) (Handshake, error) {
+	local := n.LocalHandshake()
+	if remote.GenesisHash != local.GenesisHash {
+		return local, ErrGenesisMismatch
+	}
+	n.learn(remote)
+	return local, nil
 }
 
-// NewNetwork creates a new network
-func NewNetwork() Network {
-    return Network{
-        Nodes: []string{"node1", "node2", "node3"},
-    }
+// Connect handshakes with peer, learning its height and its own peers
+func (n *Network) Connect(peer string) error {
+	peer = strings.TrimSuffix(peer, "/")
+	if peer == n.Self {
+		return nil
+	}
+
+	var remote Handshake
+	err := n.post(peer, "/p2p/handshake", n.LocalHandshake(), &remote)
+	if err == nil && remote.GenesisHash != n.LocalHandshake().GenesisHash {
+		err = ErrGenesisMismatch
+	}
+	if err != nil {
+		n.recordError(peer, err)
+		return err
+	}
+
+	remote.NodeURL = peer
+	n.learn(remote)
+	return nil
+}
+
+// learn stores a peer's handshake, connects to any peers it knows that we
+// don't, and syncs from it if its chain has more work
+func (n *Network) learn(remote Handshake) {
+	if remote.NodeURL == "" || remote.NodeURL == n.Self {
+		return
+	}
+
+	n.mutex.Lock()
+	_, known := n.peers[remote.NodeURL]
+	n.peers[remote.NodeURL] = &Peer{
+		URL:       remote.NodeURL,
+		Height:    remote.Height,
+		TipHash:   remote.TipHash,
+		TotalWork: remote.TotalWork,
+		LastSeen:  time.Now().Unix(),
+	}
+	unknown := []string{}
+	for _, url := range remote.Peers {
+		if _, ok := n.peers[url]; !ok && url != n.Self {
+			unknown = append(unknown, url)
+		}
+	}
+	n.mutex.Unlock()
+
+	if !known {
+		events.Publishf(events.PeerConnected, remote, "⇄ Connected to peer %s at height %d", remote.NodeURL, remote.Height)
+	}
+	for _, url := range unknown {
+		go n.Connect(url)
+	}
+	remoteWork, ok := new(big.Int).SetString(remote.TotalWork, 10)
+	if ok && remoteWork.Cmp(n.blockchain.TotalWork()) > 0 && !n.blockchain.HasBlock(remote.TipHash) {
+		go n.Sync(remote.NodeURL)
+	}
+}
+
+// recordError remembers the last failure talking to a peer
+func (n *Network) recordError(peer string, err error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if p, ok := n.peers[peer]; ok {
+		p.LastError = err.Error()
+	}
+}
+
+// Sync downloads the peer's main chain a page at a time and adds every
+// block we don't have, which reorganizes onto it if it has more work than ours
+func (n *Network) Sync(peer string) error {
+	added := 0
+	for from, length := 0, 1; from < length; {
+		page, err := n.fetchChainPage(peer, from)
+		if err != nil {
+			n.recordError(peer, err)
+			return err
+		}
+		if len(page.Chain) == 0 {
+			break
+		}
+		for _, block := range page.Chain {
+			if n.blockchain.HasBlock(block.Hash) {
+				continue
+			}
+			if err := n.ReceiveBlock(block); err != nil {
+				events.Publishf(events.PeerSync, nil, "⇄ Stopped syncing from %s at block %d: %v", peer, block.Index, err)
+				return err
+			}
+			added++
+		}
+		from, length = page.From+len(page.Chain), page.Length
+	}
+	if added > 0 {
+		events.Publishf(events.PeerSync, nil, "⇄ Synced %d blocks from %s", added, peer)
+	}
+	return nil
+}
+
+// chainPage is the part of a /chain response Sync reads
+type chainPage struct {
+	Chain  []*blockchain.Block `json:"chain"`
+	From   int                 `json:"from"`
+	Length int                 `json:"length"`
 }
 
-// BroadcastBlock broadcasts a mined block to all nodes
-func (n Network) BroadcastBlock(block *blockchain.Block) {
-    for _, node := range n.Nodes {
-        fmt.Printf("Broadcasting block to %s...\n", node)
-        time.Sleep(500 * time.Millisecond) // Simulate network delay
-    }
-    fmt.Println("Block broadcast complete!")
-}
\ No newline at end of file
+// fetchChainPage fetches up to syncPage of the peer's main chain blocks starting at index from
+func (n *Network) fetchChainPage(peer string, from int) (chainPage, error) {
+	var page chainPage
+	resp, err := n.client.Get(fmt.Sprintf("%s/chain?from=%d&limit=%d", peer, from, syncPage))
+	if err != nil {
+		return page, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return page, fmt.Errorf("%s/chain responded with %s", peer, resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	return page, err
+}
+
+// ReceiveBlock adds a block gossiped by a peer to the block tree. Blocks we
+// already have are ignored, which is what stops gossip from looping.
+func (n *Network) ReceiveBlock(block *blockchain.Block) error {
+	err := n.blockchain.AddMinedBlock(block)
+	if errors.Is(err, blockchain.ErrKnownBlock) {
+		return nil
+	}
+	return err
+}
+
+// ReceiveTransaction adds a gossiped transaction to the mempool. Duplicates
+// are rejected by the chain, which is what stops gossip from looping.
+func (n *Network) ReceiveTransaction(tx blockchain.Transaction) error {
+	return n.blockchain.AddTransaction(tx)
+}
+
+// broadcast posts payload to every peer in the background
+func (n *Network) broadcast(path string, payload interface{}) {
+	for _, peer := range n.PeerURLs() {
+		go func(peer string) {
+			if err := n.post(peer, path, payload, nil); err != nil {
+				n.recordError(peer, err)
+			}
+		}(peer)
+	}
+}
+
+// post sends payload as JSON to peer and decodes the response into out if given
+func (n *Network) post(peer, path string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, peer+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(NodeHeader, n.Self)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s%s responded with %s", peer, path, resp.Status)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package validator
+
+import (
+	bc "blockchain-visualizer/blockchain"
+	"blockchain-visualizer/events"
+	"blockchain-visualizer/locks"
+	"blockchain-visualizer/wallet"
+	"time"
+)
+
+// State is the lifecycle state of the validator service
+type State string
+
+const (
+	Stopped State = "stopped"
+	Running State = "running"
+)
+
+// SlotEvent is the payload published for every slot the service checks
+type SlotEvent struct {
+	Slot     int       `json:"slot"`
+	Leader   string    `json:"leader,omitempty"` // Empty while nobody has staked
+	Proposer string    `json:"proposer,omitempty"`
+	Local    bool      `json:"local"` // The proposer's key is held by this node
+	Block    *bc.Block `json:"block,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Stake    float64   `json:"stake"` // The proposer's stake
+}
+
+// Status is a snapshot of the validator service
+type Status struct {
+	State         State              `json:"state"`
+	StartedAt     int64              `json:"startedAt,omitempty"`
+	Slot          int                `json:"slot"`             // Last slot checked
+	Leader        string             `json:"leader,omitempty"` // Leader of that slot
+	Proposed      int                `json:"proposed"`         // Blocks this node proposed and the chain accepted
+	Rejected      int                `json:"rejected"`         // Proposals the chain refused
+	RemoteSlots   int                `json:"remoteSlots"`      // Slots led by validators whose keys are elsewhere
+	LastBlockHash string             `json:"lastBlockHash,omitempty"`
+	LastError     string             `json:"lastError,omitempty"`
+	Stakes        map[string]float64 `json:"stakes"`
+}
+
+// Service proposes proof-of-stake blocks. At the start of every slot it
+// works out the slot leader on the current tip and, if the leader's wallet
+// is in the keystore, signs a block template as that validator. While
+// nobody has staked, the keystore's primary wallet proposes every block.
+type Service struct {
+	blockchain *bc.Blockchain
+	keystore   *wallet.Keystore
+	status     Status
+	stop       chan struct{}
+	done       chan struct{}
+	mutex      *locks.Mutex
+}
+
+// NewService creates a stopped validator service signing with the wallets in keystore
+func NewService(blockchain *bc.Blockchain, keystore *wallet.Keystore) *Service {
+	return &Service{
+		blockchain: blockchain,
+		keystore:   keystore,
+		status:     Status{State: Stopped},
+		mutex:      locks.NewMutex("validator service"),
+	}
+}
+
+// Start begins proposing blocks. Chains that are not secured by proof of
+// stake are refused with bc.ErrWrongConsensus.
+func (s *Service) Start() (Status, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.blockchain.Params.Engine().Name() != bc.ConsensusPoS {
+		return s.snapshot(), bc.ErrWrongConsensus
+	}
+	if s.status.State == Running {
+		return s.snapshot(), nil
+	}
+
+	s.status = Status{State: Running, StartedAt: time.Now().Unix(), Slot: -1}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+
+	events.Publish(events.ValidatorService, "⚖ Validator started", s.snapshot())
+	return s.snapshot(), nil
+}
+
+// Stop waits for the current proposal, if any, and stops the service
+func (s *Service) Stop() Status {
+	s.mutex.Lock()
+	if s.status.State == Stopped {
+		defer s.mutex.Unlock()
+		return s.snapshot()
+	}
+	s.status.State = Stopped
+	close(s.stop)
+	done := s.done
+	s.mutex.Unlock()
+
+	<-done
+	status := s.Status()
+	events.Publish(events.ValidatorService, "⚖ Validator stopped", status)
+	return status
+}
+
+// Status returns a snapshot of the service
+func (s *Service) Status() Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.snapshot()
+}
+
+// snapshot copies the status. The caller must hold the mutex.
+func (s *Service) snapshot() Status {
+	status := s.status
+	status.Stakes = s.blockchain.Stakes()
+	return status
+}
+
+// run checks every slot until stop is closed
+func (s *Service) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	for {
+		slot := s.blockchain.CurrentSlot()
+		s.propose(slot)
+
+		// Sleep until the next slot starts
+		duration := s.blockchain.Params.SlotDuration
+		if duration <= 0 {
+			duration = bc.DefaultSlotDuration
+		}
+		next := time.Unix(bc.GenesisTimestamp+int64(slot+1)*duration, 0)
+		select {
+		case <-time.After(time.Until(next)):
+		case <-stop:
+			return
+		}
+	}
+}
+
+// propose signs and submits the block for slot if this node holds the leader's key
+func (s *Service) propose(slot int) {
+	if s.blockchain.GetLatestBlock().Slot >= slot {
+		// A block for this slot arrived before the service checked it
+		return
+	}
+
+	leader, parentHash := s.blockchain.SlotLeader(slot)
+	result := SlotEvent{Slot: slot, Leader: leader, Proposer: leader}
+	if leader == "" {
+		// Nobody has staked yet, so any validator may propose
+		primary, err := s.keystore.Primary()
+		if err != nil {
+			result.Error = err.Error()
+			s.finish(result, err)
+			return
+		}
+		result.Proposer = primary
+	}
+	result.Stake = s.blockchain.Stakes()[result.Proposer]
+
+	signer, ok := s.keystore.Get(result.Proposer)
+	if !ok {
+		s.finish(result, nil)
+		events.Publishf(events.SlotLeader, result, "⚖ Slot %d belongs to %s, whose key is not on this node", slot, leader)
+		return
+	}
+	result.Local = true
+
+	template := s.blockchain.NewBlockTemplate(result.Proposer)
+	defer s.blockchain.ReleaseTemplate(template)
+	block := template.Block
+	if block.PreviousHash != parentHash || block.Slot != slot {
+		// The tip moved or the slot ended while the template was built
+		s.finish(result, nil)
+		return
+	}
+
+	err := block.Sign(signer.PrivateKey)
+	if err == nil {
+		err = s.blockchain.AddMinedBlock(block)
+	}
+	if err == nil {
+		result.Block = block
+	} else {
+		result.Error = err.Error()
+	}
+	s.finish(result, err)
+
+	if err != nil {
+		events.Publishf(events.SlotLeader, result, "⚖ Slot %d proposal by %s rejected: %v", slot, result.Proposer, err)
+	} else {
+		events.Publishf(events.SlotLeader, result, "⚖ Slot %d: %s proposed block %d with stake %.2f",
+			slot, result.Proposer, block.Index, result.Stake)
+	}
+}
+
+// finish records the outcome of a slot
+func (s *Service) finish(result SlotEvent, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.status.Slot = result.Slot
+	s.status.Leader = result.Leader
+	switch {
+	case err != nil:
+		s.status.Rejected++
+		s.status.LastError = err.Error()
+	case result.Block != nil:
+		s.status.Proposed++
+		s.status.LastBlockHash = result.Block.Hash
+	case !result.Local:
+		s.status.RemoteSlots++
+	}
+}
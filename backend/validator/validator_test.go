@@ -0,0 +1,34 @@
+package validator
+
+import (
+	bc "blockchain-visualizer/blockchain"
+	"blockchain-visualizer/locks"
+	"blockchain-visualizer/wallet"
+	"testing"
+)
+
+// lockTracker counts the acquisitions reported for each resource
+type lockTracker struct {
+	acquired map[int]int
+}
+
+func (l *lockTracker) Waiting(process, resource int)   {}
+func (l *lockTracker) Acquired(process, resource int)  { l.acquired[resource]++ }
+func (l *lockTracker) Abandoned(process, resource int) {}
+func (l *lockTracker) Released(process, resource int)  {}
+
+func TestServiceLockIsTracked(t *testing.T) {
+	service := NewService(bc.NewBlockchainWithParams(bc.NewProofOfStakeParams()), wallet.NewKeystore())
+	tracker := &lockTracker{acquired: make(map[int]int)}
+	locks.SetTracker(tracker)
+	defer locks.SetTracker(nil)
+
+	service.Status()
+
+	if name, _ := locks.ResourceName(service.mutex.ID()); name != "validator service" {
+		t.Errorf("service lock is named %q, want %q", name, "validator service")
+	}
+	if tracker.acquired[service.mutex.ID()] != 1 {
+		t.Errorf("tracker saw %d acquisitions of the service lock, want 1", tracker.acquired[service.mutex.ID()])
+	}
+}
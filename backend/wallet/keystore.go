@@ -0,0 +1,180 @@
+package wallet
+
+import (
+	"blockchain-visualizer/locks"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ErrUnauthorized is returned when a request to sign with a stored wallet
+// does not carry the keystore's signing token
+var ErrUnauthorized = errors.New("signing with a server-held wallet requires the wallet token")
+
+// Keystore holds the wallets whose private keys live on this server, so the
+// API can sign transactions on their behalf for clients that present the
+// signing token
+type Keystore struct {
+	wallets map[string]Wallet
+	primary string // Address that receives mining rewards by default
+	path    string // File the keys are saved to, empty for memory only
+	token   string // Token clients present to have the server sign, empty to refuse
+	mutex   *locks.Mutex
+}
+
+// keystoreFile is the on-disk form of a keystore
+type keystoreFile struct {
+	Primary string   `json:"primary"`
+	Keys    []string `json:"keys"` // PEM encoded PKCS#1 private keys
+}
+
+// NewKeystore creates an in-memory keystore
+func NewKeystore() *Keystore {
+	return &Keystore{wallets: make(map[string]Wallet), mutex: locks.NewMutex("keystore")}
+}
+
+// OpenKeystore loads a keystore saved at path, creating an empty one if the
+// file does not exist
+func OpenKeystore(path string) (*Keystore, error) {
+	ks := NewKeystore()
+	ks.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file keystoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decode keystore: %w", err)
+	}
+	for _, encoded := range file.Keys {
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil {
+			return nil, fmt.Errorf("decode keystore: invalid PEM key")
+		}
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("decode keystore: %w", err)
+		}
+		w := Wallet{PrivateKey: privateKey, PublicKey: &privateKey.PublicKey}
+		ks.wallets[w.Address()] = w
+	}
+	ks.primary = file.Primary
+	return ks, nil
+}
+
+// SetSigningToken sets the token clients must present before the server
+// signs with one of its wallets. Until it is set, the server signs nothing.
+func (ks *Keystore) SetSigningToken(token string) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	ks.token = token
+}
+
+// Authorize checks a token presented by a client that wants the server to
+// sign on its behalf
+func (ks *Keystore) Authorize(token string) error {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	if ks.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(ks.token)) != 1 {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// Create generates a new wallet and stores it
+func (ks *Keystore) Create() (Wallet, error) {
+	w, err := NewWallet()
+	if err != nil {
+		return Wallet{}, err
+	}
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	ks.wallets[w.Address()] = w
+	if ks.primary == "" {
+		ks.primary = w.Address()
+	}
+	if err := ks.save(); err != nil {
+		delete(ks.wallets, w.Address())
+		return Wallet{}, err
+	}
+	return w, nil
+}
+
+// Get returns the wallet for address if this keystore holds it
+func (ks *Keystore) Get(address string) (Wallet, bool) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	w, ok := ks.wallets[address]
+	return w, ok
+}
+
+// Addresses returns the addresses of all stored wallets in sorted order
+func (ks *Keystore) Addresses() []string {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	addresses := make([]string, 0, len(ks.wallets))
+	for address := range ks.wallets {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// Primary returns the address of the first wallet created, creating one if
+// the keystore is empty. Mining rewards go here unless told otherwise.
+func (ks *Keystore) Primary() (string, error) {
+	ks.mutex.Lock()
+	primary := ks.primary
+	ks.mutex.Unlock()
+
+	if primary != "" {
+		return primary, nil
+	}
+	w, err := ks.Create()
+	if err != nil {
+		return "", err
+	}
+	return w.Address(), nil
+}
+
+// save writes the keystore to its file. The caller must hold the lock.
+func (ks *Keystore) save() error {
+	if ks.path == "" {
+		return nil
+	}
+
+	file := keystoreFile{Primary: ks.primary}
+	for _, w := range ks.wallets {
+		key := pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(w.PrivateKey),
+		})
+		file.Keys = append(file.Keys, string(key))
+	}
+	sort.Strings(file.Keys)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := ks.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ks.path)
+}
@@ -3,9 +3,8 @@ package wallet
 import (
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
-	"encoding/hex"
-	"fmt"
+
+	"blockchain-visualizer/blockchain"
 )
 
 // Wallet represents a user's wallet
@@ -15,43 +14,35 @@ type Wallet struct {
 }
 
 // NewWallet creates a new wallet
-func NewWallet() Wallet {
-	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+func NewWallet() (Wallet, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return Wallet{}, err
+	}
 	return Wallet{
 		PrivateKey: privateKey,
 		PublicKey:  &privateKey.PublicKey,
-	}
+	}, nil
 }
 
-// SignTransaction signs a transaction
-func (w Wallet) SignTransaction(tx Transaction) string {
-	txData := fmt.Sprintf("%s%s%d", tx.Sender, tx.Receiver, tx.Amount)
-	hash := sha256.Sum256([]byte(txData))
-	signature, _ := rsa.SignPKCS1v15(rand.Reader, w.PrivateKey, 0, hash[:])
-	return hex.EncodeToString(signature)
+// Address returns the address derived from the wallet's public key
+func (w Wallet) Address() string {
+	return blockchain.AddressFromPublicKey(w.PublicKey)
 }
 
-// VerifyTransaction verifies a transaction's signature
-func VerifyTransaction(tx Transaction, signature string, publicKey *rsa.PublicKey) bool {
-	txData := fmt.Sprintf("%s%s%d", tx.Sender, tx.Receiver, tx.Amount)
-	hash := sha256.Sum256([]byte(txData))
-	sigBytes, _ := hex.DecodeString(signature)
-	err := rsa.VerifyPKCS1v15(publicKey, 0, hash[:], sigBytes)
-	return err == nil
+// NewTransaction creates a transaction from this wallet and signs it
+func (w Wallet) NewTransaction(recipient string, amount, fee float64) (blockchain.Transaction, error) {
+	tx := blockchain.NewTransactionWithFee(w.Address(), recipient, amount, fee)
+	err := w.SignTransaction(&tx)
+	return tx, err
 }
 
-// Transaction represents a transaction
-type Transaction struct {
-	Sender   string `json:"sender"`
-	Receiver string `json:"receiver"`
-	Amount   int    `json:"amount"`
+// SignTransaction signs a transaction
+func (w Wallet) SignTransaction(tx *blockchain.Transaction) error {
+	return tx.Sign(w.PrivateKey)
 }
 
-// NewTransaction creates a new transaction
-func NewTransaction(sender, receiver string, amount int) Transaction {
-	return Transaction{
-		Sender:   sender,
-		Receiver: receiver,
-		Amount:   amount,
-	}
-}
\ No newline at end of file
+// VerifyTransaction verifies a transaction's signature
+func VerifyTransaction(tx blockchain.Transaction) bool {
+	return tx.VerifySignature() == nil
+}
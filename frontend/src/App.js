@@ -6,6 +6,7 @@ import TransactionsPage from './components/TransactionsPage';
 import TransactionCreator from './components/TransactionCreator';
 import MiningControl from './components/MiningControl';
 import BlockchainValidator from './components/BlockchainValidator';
+import DeadlockGraph from './components/DeadlockGraph';
 import './App.css';
 
 function App() {
@@ -27,6 +28,7 @@ function App() {
             {activeTab === 'blockchain' && <BlockchainDisplay />}
             {activeTab === 'transactions' && <TransactionsPage />}
             {activeTab === 'mining' && <div className="coming-soon">Mining Dashboard Coming Soon</div>}
+            {activeTab === 'deadlocks' && <DeadlockGraph />}
           </div>
         </main>
         
@@ -18,6 +18,13 @@ function BlockchainDisplay() {
           Refresh
         </button>
       </div>
+
+      {/* /chain serves only the latest blocks of a long chain */}
+      {blockchain.chain && blockchain.length > blockchain.chain.length && (
+        <div className="chain-page-note">
+          Showing blocks #{blockchain.from} to #{blockchain.from + blockchain.chain.length - 1} of {blockchain.length}
+        </div>
+      )}
       
       {/* Visual blockchain representation */}
       <div className="blockchain-visualization">
@@ -1,101 +1,172 @@
 import React, { useState } from 'react';
 import { useBlockchain } from '../context/BlockchainContext';
+import { tamperBlock } from '../services/api';
 import '../styles/components/BlockchainValidator.css';
 
+// Fields the tamper simulation can edit
+const TAMPER_FIELDS = [
+  { value: 'amount', label: 'Transaction amount' },
+  { value: 'recipient', label: 'Transaction recipient' },
+  { value: 'removeTransaction', label: 'Remove transaction' },
+  { value: 'nonce', label: 'Nonce' },
+  { value: 'timestamp', label: 'Timestamp' },
+  { value: 'previousHash', label: 'Previous hash' }
+];
+
+// Turn a server validation report into the message and issues shown below
+function describeReport(report) {
+  return {
+    valid: report.valid,
+    message: report.valid
+      ? `Blockchain is valid. All ${report.length} blocks pass every consensus rule.`
+      : `Blockchain validation failed. The chain first diverges at block #${report.firstInvalid}.`,
+    issues: report.failures.map(failure => ({
+      block: failure.index,
+      rule: failure.rule,
+      issue: failure.error
+    }))
+  };
+}
+
 function BlockchainValidator() {
   const { blockchain, isValid, validateBlockchain } = useBlockchain();
   const [validationDetails, setValidationDetails] = useState(null);
-  
-  const runFullValidation = () => {
-    if (!blockchain.chain || blockchain.chain.length <= 1) {
-      setValidationDetails({
-        valid: true,
-        message: 'Blockchain only has one block (Genesis block).',
-        issues: []
-      });
-      return;
+  const [tamper, setTamper] = useState({ index: 1, field: 'amount', value: '', transaction: 0, rehash: false });
+  const [tamperDetails, setTamperDetails] = useState(null);
+  const [tamperError, setTamperError] = useState(null);
+
+  const runFullValidation = async () => {
+    const report = await validateBlockchain();
+    if (report) {
+      setValidationDetails(describeReport(report));
     }
-    
-    const issues = [];
-    let valid = true;
-    
-    // Perform validation checks
-    for (let i = 1; i < blockchain.chain.length; i++) {
-      const currentBlock = blockchain.chain[i];
-      const previousBlock = blockchain.chain[i-1];
-      
-      // Check previous hash
-      if (currentBlock.PreviousHash !== previousBlock.Hash) {
-        issues.push({
-          block: currentBlock.Index,
-          issue: 'Previous hash does not match the hash of the previous block'
-        });
-        valid = false;
-      }
-      
-      // Check hash format (starts with zeros based on difficulty)
-      if (!currentBlock.Hash.startsWith('0'.repeat(currentBlock.Difficulty))) {
-        issues.push({
-          block: currentBlock.Index,
-          issue: `Hash does not meet difficulty requirement of ${currentBlock.Difficulty} leading zeros`
-        });
-        valid = false;
-      }
+  };
+
+  const runTamperSimulation = async (e) => {
+    e.preventDefault();
+    try {
+      const result = await tamperBlock({
+        ...tamper,
+        index: parseInt(tamper.index, 10),
+        transaction: parseInt(tamper.transaction, 10)
+      });
+      setTamperDetails(describeReport(result.report));
+      setTamperError(null);
+    } catch (err) {
+      setTamperDetails(null);
+      setTamperError(err.message);
     }
-    
-    setValidationDetails({
-      valid,
-      message: valid 
-        ? 'Blockchain is valid. All blocks are properly linked and have valid hashes.' 
-        : 'Blockchain validation failed. See issues below.',
-      issues
-    });
-    
-    // Update the global validation state
-    validateBlockchain(blockchain.chain);
   };
-  
+
+  const updateTamper = (field, value) => {
+    setTamper(current => ({ ...current, [field]: value }));
+  };
+
+  const renderDetails = (details, title) => (
+    <div className="validation-details">
+      <h3>{title}</h3>
+      <p className={details.valid ? 'valid-message' : 'invalid-message'}>
+        {details.message}
+      </p>
+
+      {details.issues.length > 0 && (
+        <>
+          <h4>Issues Found:</h4>
+          <ul className="issues-list">
+            {details.issues.map((issue, index) => (
+              <li key={index} className="issue-item">
+                <span className="issue-block">Block #{issue.block}:</span>{' '}
+                <span className="issue-rule">{issue.rule}</span> {issue.issue}
+              </li>
+            ))}
+          </ul>
+        </>
+      )}
+    </div>
+  );
+
   return (
     <div className="blockchain-validator">
       <h2>Blockchain Validator</h2>
-      
+
       <div className={`validation-status ${isValid ? 'valid' : 'invalid'}`}>
         <div className="status-indicator"></div>
         <span className="status-text">
           {isValid ? 'Blockchain is currently valid' : 'Blockchain integrity issues detected'}
         </span>
       </div>
-      
-      <button 
-        className="validate-button" 
+
+      <button
+        className="validate-button"
         onClick={runFullValidation}
       >
         Run Full Validation
       </button>
-      
-      {validationDetails && (
-        <div className="validation-details">
-          <h3>Validation Results</h3>
-          <p className={validationDetails.valid ? 'valid-message' : 'invalid-message'}>
-            {validationDetails.message}
-          </p>
-          
-          {validationDetails.issues.length > 0 && (
-            <>
-              <h4>Issues Found:</h4>
-              <ul className="issues-list">
-                {validationDetails.issues.map((issue, index) => (
-                  <li key={index} className="issue-item">
-                    <span className="issue-block">Block #{issue.block}:</span> {issue.issue}
-                  </li>
-                ))}
-              </ul>
-            </>
-          )}
+
+      {validationDetails && renderDetails(validationDetails, 'Validation Results')}
+
+      <form className="tamper-form" onSubmit={runTamperSimulation}>
+        <h3>Tamper Simulation</h3>
+        <p className="tamper-hint">
+          Edit a block in a copy of the chain to see how validation catches it. The real chain is not changed.
+        </p>
+        <div className="tamper-row">
+          <label>
+            Block
+            <input
+              type="number"
+              min="0"
+              max={Math.max(blockchain.length - 1, 0)}
+              value={tamper.index}
+              onChange={(e) => updateTamper('index', e.target.value)}
+            />
+          </label>
+          <label>
+            Field
+            <select value={tamper.field} onChange={(e) => updateTamper('field', e.target.value)}>
+              {TAMPER_FIELDS.map(field => (
+                <option key={field.value} value={field.value}>{field.label}</option>
+              ))}
+            </select>
+          </label>
         </div>
-      )}
+        <div className="tamper-row">
+          <label>
+            Transaction
+            <input
+              type="number"
+              min="0"
+              value={tamper.transaction}
+              onChange={(e) => updateTamper('transaction', e.target.value)}
+            />
+          </label>
+          <label>
+            New value
+            <input
+              type="text"
+              value={tamper.value}
+              disabled={tamper.field === 'removeTransaction'}
+              onChange={(e) => updateTamper('value', e.target.value)}
+            />
+          </label>
+        </div>
+        <label className="tamper-rehash">
+          <input
+            type="checkbox"
+            checked={tamper.rehash}
+            onChange={(e) => updateTamper('rehash', e.target.checked)}
+          />
+          Recompute the block hash like a forger would
+        </label>
+        <button type="submit" className="validate-button">
+          Simulate Tampering
+        </button>
+      </form>
+
+      {tamperError && <p className="invalid-message">{tamperError}</p>}
+      {tamperDetails && renderDetails(tamperDetails, 'Tampered Chain')}
     </div>
   );
 }
 
-export default BlockchainValidator;
\ No newline at end of file
+export default BlockchainValidator;
@@ -0,0 +1,321 @@
+import React, { useState, useEffect } from 'react';
+import { fetchDeadlockGraph, injectDeadlockScenario, resetDeadlockScenario } from '../services/api';
+import '../styles/components/DeadlockGraph.css';
+
+const WIDTH = 640;
+const HEIGHT = 420;
+const NODE_RADIUS = 22;
+// The detector checks every 5 seconds, so refresh at the same pace
+const REFRESH_INTERVAL = 5000;
+
+// The server's own locks, or the scenario staged below
+const SOURCES = [
+  { value: 'live', label: 'Live locks' },
+  { value: 'scenario', label: 'Staged scenario' }
+];
+
+const GRAPHS = [
+  { value: 'resourceAllocation', label: 'Resource allocation' },
+  { value: 'waitFor', label: 'Wait-for' }
+];
+
+// Scenario steps that can be staged, and the endpoint for each
+const STEPS = [
+  { value: 'allocations', label: 'Process holds resource' },
+  { value: 'waits', label: 'Process waits for resource' },
+  { value: 'releases', label: 'Process releases resource' }
+];
+
+// Two processes each holding the resource the other waits for
+const EXAMPLE_SCENARIO = [
+  ['allocations', { process: 1, resource: 1 }],
+  ['allocations', { process: 2, resource: 2 }],
+  ['waits', { process: 1, resource: 2 }],
+  ['waits', { process: 2, resource: 1 }]
+];
+
+// Place nodes evenly around a circle, starting at the top
+function layout(nodes) {
+  const radius = Math.min(WIDTH, HEIGHT) / 2 - NODE_RADIUS * 2;
+  const positions = {};
+  nodes.forEach((node, index) => {
+    const angle = (2 * Math.PI * index) / nodes.length - Math.PI / 2;
+    positions[node.id] = {
+      x: WIDTH / 2 + radius * Math.cos(angle),
+      y: HEIGHT / 2 + radius * Math.sin(angle)
+    };
+  });
+  return positions;
+}
+
+// Move point distance towards target
+function towards(point, target, distance) {
+  const dx = target.x - point.x;
+  const dy = target.y - point.y;
+  const length = Math.hypot(dx, dy) || 1;
+  return { x: point.x + (dx / length) * distance, y: point.y + (dy / length) * distance };
+}
+
+// Draw an edge as a curve bending to its right, so edges in both directions
+// between two nodes stay apart, stopping at the node borders
+function edgePath(from, to) {
+  const dx = to.x - from.x;
+  const dy = to.y - from.y;
+  const length = Math.hypot(dx, dy) || 1;
+  const control = {
+    x: (from.x + to.x) / 2 - (dy / length) * 25,
+    y: (from.y + to.y) / 2 + (dx / length) * 25
+  };
+  const start = towards(from, control, NODE_RADIUS);
+  const end = towards(to, control, NODE_RADIUS + 4);
+  return {
+    d: `M ${start.x} ${start.y} Q ${control.x} ${control.y} ${end.x} ${end.y}`,
+    label: control
+  };
+}
+
+// Label an edge with its units or the resource behind a wait
+function edgeLabel(edge) {
+  if (edge.kind === 'wait') {
+    return `R${edge.resource}`;
+  }
+  return edge.units > 1 ? `×${edge.units}` : '';
+}
+
+function nodeClass(node) {
+  if (node.inCycle) return 'graph-node in-cycle';
+  if (node.deadlocked) return 'graph-node deadlocked';
+  return 'graph-node';
+}
+
+function DeadlockGraph() {
+  const [graphs, setGraphs] = useState(null);
+  const [source, setSource] = useState('live');
+  const [view, setView] = useState('resourceAllocation');
+  const [step, setStep] = useState({ kind: 'allocations', process: 1, resource: 1, units: 1 });
+  const [resource, setResource] = useState({ id: 1, instances: 1, name: '' });
+  const [error, setError] = useState(null);
+
+  const loadGraphs = async () => {
+    try {
+      setGraphs(await fetchDeadlockGraph(source));
+      setError(null);
+    } catch (err) {
+      setError(err.message);
+    }
+  };
+
+  useEffect(() => {
+    loadGraphs();
+    const interval = setInterval(loadGraphs, REFRESH_INTERVAL);
+    return () => clearInterval(interval);
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [source]);
+
+  // Scenario changes answer with the scenario's graphs, so show that source
+  const runScenario = async (action) => {
+    try {
+      const result = await action();
+      setSource('scenario');
+      setGraphs(result);
+      setError(null);
+    } catch (err) {
+      setError(err.message);
+    }
+  };
+
+  const applyStep = (e) => {
+    e.preventDefault();
+    runScenario(() => injectDeadlockScenario(step.kind, {
+      process: parseInt(step.process, 10),
+      resource: parseInt(step.resource, 10),
+      units: parseInt(step.units, 10)
+    }));
+  };
+
+  const addResource = (e) => {
+    e.preventDefault();
+    runScenario(() => injectDeadlockScenario('resources', {
+      id: parseInt(resource.id, 10),
+      instances: parseInt(resource.instances, 10),
+      name: resource.name
+    }));
+  };
+
+  const loadExample = () => {
+    runScenario(async () => {
+      let result = await resetDeadlockScenario();
+      for (const [kind, body] of EXAMPLE_SCENARIO) {
+        result = await injectDeadlockScenario(kind, body);
+      }
+      return result;
+    });
+  };
+
+  const updateStep = (field, value) => {
+    setStep(current => ({ ...current, [field]: value }));
+  };
+
+  const updateResource = (field, value) => {
+    setResource(current => ({ ...current, [field]: value }));
+  };
+
+  const graph = graphs ? graphs[view] : { nodes: [], edges: [] };
+  const positions = layout(graph.nodes);
+
+  return (
+    <div className="deadlock-page">
+      <div className="deadlock-header">
+        <h2>Deadlock Detection</h2>
+        <div className={`deadlock-count ${graphs && graphs.cycles.length > 0 ? 'found' : ''}`}>
+          {graphs ? `${graphs.cycles.length} cycles, ${graphs.deadlocked.length} deadlocked processes` : 'Loading...'}
+        </div>
+      </div>
+
+      <div className="graph-tabs">
+        {SOURCES.map(option => (
+          <button
+            key={option.value}
+            className={source === option.value ? 'active' : ''}
+            onClick={() => setSource(option.value)}
+          >
+            {option.label}
+          </button>
+        ))}
+      </div>
+
+      <div className="graph-tabs">
+        {GRAPHS.map(option => (
+          <button
+            key={option.value}
+            className={view === option.value ? 'active' : ''}
+            onClick={() => setView(option.value)}
+          >
+            {option.label}
+          </button>
+        ))}
+      </div>
+
+      {error && <p className="deadlock-error">{error}</p>}
+
+      {graph.nodes.length === 0 ? (
+        <div className="no-graph-message">
+          No process holds or waits for a resource right now.
+        </div>
+      ) : (
+        <svg className="deadlock-graph" viewBox={`0 0 ${WIDTH} ${HEIGHT}`}>
+          <defs>
+            <marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto">
+              <path d="M 0 0 L 10 5 L 0 10 z" className="arrow-head" />
+            </marker>
+            <marker id="arrow-cycle" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto">
+              <path d="M 0 0 L 10 5 L 0 10 z" className="arrow-head in-cycle" />
+            </marker>
+          </defs>
+
+          {graph.edges.map((edge, index) => {
+            const path = edgePath(positions[edge.from], positions[edge.to]);
+            return (
+              <g key={index} className={`graph-edge ${edge.kind} ${edge.inCycle ? 'in-cycle' : ''}`}>
+                <path d={path.d} markerEnd={`url(#${edge.inCycle ? 'arrow-cycle' : 'arrow'})`} />
+                <text x={path.label.x} y={path.label.y}>{edgeLabel(edge)}</text>
+              </g>
+            );
+          })}
+
+          {graph.nodes.map(node => {
+            const { x, y } = positions[node.id];
+            const name = node.label.slice(node.id.length).trim();
+            return (
+              <g key={node.id} className={nodeClass(node)}>
+                {node.kind === 'resource' ? (
+                  <rect x={x - NODE_RADIUS} y={y - NODE_RADIUS} width={NODE_RADIUS * 2} height={NODE_RADIUS * 2} rx="4" />
+                ) : (
+                  <circle cx={x} cy={y} r={NODE_RADIUS} />
+                )}
+                <text x={x} y={y + 4} className="node-id">{node.id}</text>
+                {node.instances > 1 && (
+                  <text x={x} y={y + NODE_RADIUS - 4} className="node-instances">×{node.instances}</text>
+                )}
+                {name && <text x={x} y={y + NODE_RADIUS + 14} className="node-name">{name}</text>}
+              </g>
+            );
+          })}
+        </svg>
+      )}
+
+      <div className="graph-legend">
+        <span><span className="legend-swatch process"></span>Process</span>
+        <span><span className="legend-swatch resource"></span>Resource</span>
+        <span><span className="legend-swatch in-cycle"></span>In a cycle</span>
+        <span><span className="legend-swatch deadlocked"></span>Blocked behind a cycle</span>
+      </div>
+
+      {graphs && graphs.cycles.length > 0 && (
+        <ul className="cycle-list">
+          {graphs.cycles.map((cycle, index) => (
+            <li key={index}>Deadlock #{index + 1}: {cycle.map(process => `P${process}`).join(' → ')}</li>
+          ))}
+        </ul>
+      )}
+
+      <div className="scenario-forms">
+        <form className="scenario-form" onSubmit={applyStep}>
+          <h3>Stage a Scenario</h3>
+          <p className="scenario-hint">
+            Scenarios are kept apart from the server's own locks, so any IDs can be used.
+          </p>
+          <label>
+            Step
+            <select value={step.kind} onChange={(e) => updateStep('kind', e.target.value)}>
+              {STEPS.map(option => (
+                <option key={option.value} value={option.value}>{option.label}</option>
+              ))}
+            </select>
+          </label>
+          <div className="scenario-row">
+            <label>
+              Process
+              <input type="number" min="1" value={step.process} onChange={(e) => updateStep('process', e.target.value)} />
+            </label>
+            <label>
+              Resource
+              <input type="number" min="1" value={step.resource} onChange={(e) => updateStep('resource', e.target.value)} />
+            </label>
+            <label>
+              Units
+              <input type="number" min="1" value={step.units} onChange={(e) => updateStep('units', e.target.value)} />
+            </label>
+          </div>
+          <button type="submit" className="scenario-button">Apply</button>
+        </form>
+
+        <form className="scenario-form" onSubmit={addResource}>
+          <h3>Define a Resource</h3>
+          <div className="scenario-row">
+            <label>
+              Resource
+              <input type="number" min="1" value={resource.id} onChange={(e) => updateResource('id', e.target.value)} />
+            </label>
+            <label>
+              Instances
+              <input type="number" min="1" value={resource.instances} onChange={(e) => updateResource('instances', e.target.value)} />
+            </label>
+          </div>
+          <label>
+            Name
+            <input type="text" value={resource.name} onChange={(e) => updateResource('name', e.target.value)} />
+          </label>
+          <button type="submit" className="scenario-button">Save Resource</button>
+        </form>
+      </div>
+
+      <div className="scenario-actions">
+        <button className="scenario-button" onClick={loadExample}>Load Two-Process Deadlock</button>
+        <button className="scenario-button secondary" onClick={() => runScenario(resetDeadlockScenario)}>Reset Scenario</button>
+      </div>
+    </div>
+  );
+}
+
+export default DeadlockGraph;
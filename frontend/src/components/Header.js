@@ -49,6 +49,12 @@ function Header({ activeTab, setActiveTab }) {
           >
             Mining
           </li>
+          <li 
+            className={activeTab === 'deadlocks' ? 'active' : ''}
+            onClick={() => setActiveTab('deadlocks')}
+          >
+            Deadlocks
+          </li>
         </ul>
       </nav>
     </header>
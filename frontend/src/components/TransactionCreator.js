@@ -9,6 +9,7 @@ function TransactionCreator() {
     recipient: '',
     amount: ''
   });
+  const [walletToken, setWalletToken] = useState('');
   const [status, setStatus] = useState(null);
   const [isSubmitting, setIsSubmitting] = useState(false);
   
@@ -40,7 +41,7 @@ function TransactionCreator() {
         sender: formData.sender,
         recipient: formData.recipient,
         amount: parseFloat(formData.amount)
-      });
+      }, walletToken);
       
       if (result.success) {
         setStatus({
@@ -124,6 +125,19 @@ function TransactionCreator() {
           </div>
         </div>
         
+        <div className="form-group">
+          <label htmlFor="walletToken">Wallet Token</label>
+          <input
+            type="password"
+            id="walletToken"
+            name="walletToken"
+            value={walletToken}
+            onChange={(e) => setWalletToken(e.target.value)}
+            disabled={isSubmitting}
+            placeholder="Printed by the server at startup"
+          />
+        </div>
+        
         <button 
           type="submit" 
           disabled={isSubmitting}
@@ -65,9 +65,9 @@ function TransactionsPage() {
               <div className="transaction-block-info">
                 <span className="label">Included in Block:</span>
                 <span className="value">
-                  {blockchain.chain.findIndex(block => 
+                  {blockchain.chain.find(block => 
                     block.Transactions.some(t => t.ID === tx.ID)
-                  )}
+                  ).Index}
                 </span>
               </div>
             </div>
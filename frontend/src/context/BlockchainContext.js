@@ -1,5 +1,5 @@
 import React, { createContext, useState, useContext, useEffect } from 'react';
-import { fetchBlockchain, mineBlock, createTransaction } from '../services/api';
+import { fetchBlockchain, mineBlock, createTransaction, validateChain } from '../services/api';
 
 const BlockchainContext = createContext();
 
@@ -12,6 +12,7 @@ export function BlockchainProvider({ children }) {
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState(null);
   const [isValid, setIsValid] = useState(true);
+  const [validationReport, setValidationReport] = useState(null);
 
   // Load blockchain data
   const loadBlockchain = async () => {
@@ -20,7 +21,7 @@ export function BlockchainProvider({ children }) {
       const data = await fetchBlockchain();
       setBlockchain(data);
       setError(null);
-      validateBlockchain(data.chain);
+      validateBlockchain();
     } catch (err) {
       console.error('Error loading blockchain:', err);
       setError('Failed to load blockchain data');
@@ -44,11 +45,11 @@ export function BlockchainProvider({ children }) {
     }
   };
 
-  // Create a new transaction
-  const handleCreateTransaction = async (transactionData) => {
+  // Create a new transaction, signed by the server if walletToken is given
+  const handleCreateTransaction = async (transactionData, walletToken) => {
     try {
       setLoading(true);
-      await createTransaction(transactionData);
+      await createTransaction(transactionData, walletToken);
       await loadBlockchain();
       return { success: true };
     } catch (err) {
@@ -59,34 +60,17 @@ export function BlockchainProvider({ children }) {
     }
   };
 
-  // Validate the blockchain
-  const validateBlockchain = (chain) => {
-    if (!chain || chain.length <= 1) {
-      setIsValid(true);
-      return;
-    }
-
-    let valid = true;
-    
-    // Simple validation logic - in a real app, this would be more complex
-    for (let i = 1; i < chain.length; i++) {
-      const currentBlock = chain[i];
-      const previousBlock = chain[i-1];
-      
-      // Check if previous hash matches
-      if (currentBlock.PreviousHash !== previousBlock.Hash) {
-        valid = false;
-        break;
-      }
-      
-      // Check block hash format
-      if (!currentBlock.Hash.startsWith('0'.repeat(currentBlock.Difficulty))) {
-        valid = false;
-        break;
-      }
+  // Validate the blockchain on the server, which checks every consensus rule
+  const validateBlockchain = async () => {
+    try {
+      const report = await validateChain();
+      setValidationReport(report);
+      setIsValid(report.valid);
+      return report;
+    } catch (err) {
+      console.error('Validation error:', err);
+      return null;
     }
-    
-    setIsValid(valid);
   };
 
   // Load blockchain on component mount
@@ -100,6 +84,7 @@ export function BlockchainProvider({ children }) {
     loading,
     error,
     isValid,
+    validationReport,
     refreshBlockchain: loadBlockchain,
     mineBlock: handleMineBlock,
     createTransaction: handleCreateTransaction,
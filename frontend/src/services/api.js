@@ -14,14 +14,31 @@ export async function fetchBlockchain() {
   }
 }
 
-// Mine a new block
+// Mine a new block. The server answers with a job right away, so poll it
+// until the block is found or mining fails.
 export async function mineBlock() {
   try {
     const response = await fetch(`${API_URL}/mine`);
+    if (response.status === 409) {
+      // Another mining job is still running
+      throw new Error((await response.text()).trim());
+    }
     if (!response.ok) {
       throw new Error(`Server responded with ${response.status}`);
     }
-    return await response.json();
+    let { job } = await response.json();
+    while (job.status === 'running') {
+      await new Promise(resolve => setTimeout(resolve, 500));
+      const jobResponse = await fetch(`${API_URL}/mine/jobs/${job.id}`);
+      if (!jobResponse.ok) {
+        throw new Error(`Server responded with ${jobResponse.status}`);
+      }
+      job = await jobResponse.json();
+    }
+    if (job.status === 'failed') {
+      throw new Error(job.error);
+    }
+    return job;
   } catch (error) {
     console.error('API error:', error);
     throw new Error(`Failed to mine block: ${error.message}`);
@@ -29,18 +46,24 @@ export async function mineBlock() {
 }
 
 // Create a new transaction
-export async function createTransaction(transactionData) {
+// The wallet token, printed by the server at startup, lets the server sign
+// for a wallet it holds
+export async function createTransaction(transactionData, walletToken) {
   try {
+    const headers = {
+      'Content-Type': 'application/json'
+    };
+    if (walletToken) {
+      headers.Authorization = `Bearer ${walletToken}`;
+    }
     const response = await fetch(`${API_URL}/transactions/new`, {
       method: 'POST',
-      headers: {
-        'Content-Type': 'application/json'
-      },
+      headers,
       body: JSON.stringify(transactionData)
     });
     
     if (!response.ok) {
-      throw new Error(`Server responded with ${response.status}`);
+      throw new Error(await response.text());
     }
     
     return await response.json();
@@ -48,4 +71,96 @@ export async function createTransaction(transactionData) {
     console.error('API error:', error);
     throw new Error(`Failed to create transaction: ${error.message}`);
   }
-}
\ No newline at end of file
+}
+// Validate the chain on the server. The report lists every failing block
+// and the rule it breaks.
+export async function validateChain() {
+  try {
+    const response = await fetch(`${API_URL}/chain/validate`);
+    if (!response.ok) {
+      throw new Error(`Server responded with ${response.status}`);
+    }
+    return await response.json();
+  } catch (error) {
+    console.error('API error:', error);
+    throw new Error(`Failed to validate blockchain: ${error.message}`);
+  }
+}
+
+// Edit a block in a sandbox copy of the chain and validate the copy. The
+// real chain is not changed.
+export async function tamperBlock(tamper) {
+  try {
+    const response = await fetch(`${API_URL}/chain/tamper`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json'
+      },
+      body: JSON.stringify(tamper)
+    });
+
+    if (!response.ok) {
+      throw new Error(await response.text());
+    }
+
+    return await response.json();
+  } catch (error) {
+    console.error('API error:', error);
+    throw new Error(`Failed to simulate tampering: ${error.message}`);
+  }
+}
+
+// Fetch the resource-allocation and wait-for graphs, with the nodes and
+// edges of every cycle marked. source is 'live' for the server's own locks
+// or 'scenario' for the scenario staged by hand.
+export async function fetchDeadlockGraph(source = 'live') {
+  try {
+    const response = await fetch(`${API_URL}/deadlocks/graph?source=${source}`);
+    if (!response.ok) {
+      throw new Error(`Server responded with ${response.status}`);
+    }
+    return await response.json();
+  } catch (error) {
+    console.error('API error:', error);
+    throw new Error(`Failed to fetch deadlock graph: ${error.message}`);
+  }
+}
+
+// Stage part of a deadlock scenario. kind is 'resources' with
+// { id, instances, name }, or 'allocations', 'waits' or 'releases' with
+// { process, resource, units }. The server answers with the scenario's new
+// graphs; the live locks are never touched.
+export async function injectDeadlockScenario(kind, body) {
+  try {
+    const response = await fetch(`${API_URL}/deadlocks/scenario/${kind}`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json'
+      },
+      body: JSON.stringify(body)
+    });
+
+    if (!response.ok) {
+      throw new Error(await response.text());
+    }
+
+    return await response.json();
+  } catch (error) {
+    console.error('API error:', error);
+    throw new Error(`Failed to update deadlock scenario: ${error.message}`);
+  }
+}
+
+// Clear every hold and wait of the staged scenario
+export async function resetDeadlockScenario() {
+  try {
+    const response = await fetch(`${API_URL}/deadlocks/scenario/reset`, { method: 'POST' });
+    if (!response.ok) {
+      throw new Error(`Server responded with ${response.status}`);
+    }
+    return await response.json();
+  } catch (error) {
+    console.error('API error:', error);
+    throw new Error(`Failed to reset deadlock scenario: ${error.message}`);
+  }
+}